@@ -0,0 +1,175 @@
+package arrowio
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func buildSampleDataset() *types.DataSet {
+	key := "key-1"
+	return dataset.New([]*types.DataRow{
+		dataset.DataRow(&key,
+			dataset.StringColumn("Instrument", "ERIC B SS Equity"),
+			dataset.IntColumn("Quantity", 175000),
+			dataset.DoubleColumn("Price", 12.5),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 3, 14, 15, 4, 5, 0, time.UTC)),
+		),
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "AMZN US Equity"),
+			dataset.NilColumn("Quantity"),
+			dataset.DoubleColumn("Price", 3),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 11, 18, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 11, 18, 9, 30, 0, 0, time.UTC)),
+		),
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "AAPL US Equity"),
+			dataset.IntColumn("Quantity", 10),
+			dataset.DoubleColumn("Price", 1.25),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)}},
+			dataset.NilColumn("Created"),
+		),
+	})
+}
+
+func TestWriteStream(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+
+	var buf bytes.Buffer
+	err := WriteStream(&buf, ds, &WriterConfiguration{KeyColumn: "_key", BatchSize: 2})
+	assert.Nil(err)
+
+	reader, err := ipc.NewReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(err)
+	defer reader.Release()
+
+	schema := reader.Schema()
+	assert.Equal(6, len(schema.Fields()))
+	assert.Equal("_key", schema.Field(0).Name)
+	assert.Equal(arrow.PrimitiveTypes.Int32, schema.Field(2).Type)
+	assert.Equal(arrow.FixedWidthTypes.Date32, schema.Field(4).Type)
+	typeName, ok := schema.Field(4).Metadata.GetValue(dataTypeMetadataKey)
+	assert.True(ok)
+	assert.Equal(types.DateType.String(), typeName)
+
+	batches := 0
+	rows := 0
+	for reader.Next() {
+		rec := reader.Record()
+		if batches == 0 {
+			keys := rec.Column(0).(*array.String)
+			assert.Equal("key-1", keys.Value(0))
+			assert.True(keys.IsNull(1))
+			qty := rec.Column(2).(*array.Int32)
+			assert.Equal(int32(175000), qty.Value(0))
+			assert.True(qty.IsNull(1))
+			created := rec.Column(5).(*array.Timestamp)
+			assert.Equal(time.Date(2021, 3, 14, 15, 4, 5, 0, time.UTC).UnixNano(), int64(created.Value(0)))
+		}
+		batches++
+		rows += int(rec.NumRows())
+	}
+	assert.Nil(reader.Err())
+	assert.Equal(2, batches)
+	assert.Equal(3, rows)
+}
+
+func TestWriteFile(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "positions.arrow"))
+	assert.Nil(err)
+	defer f.Close()
+	assert.Nil(WriteFile(f, ds, nil))
+
+	_, err = f.Seek(0, 0)
+	assert.Nil(err)
+	reader, err := ipc.NewFileReader(f)
+	assert.Nil(err)
+	defer reader.Close()
+	assert.Equal(1, reader.NumRecords())
+	rec, err := reader.Record(0)
+	assert.Nil(err)
+	assert.Equal(int64(3), rec.NumRows())
+	assert.Equal("Instrument", rec.ColumnName(0))
+	dates := rec.Column(3).(*array.Date32)
+	assert.Equal(arrow.Date32FromTime(time.Date(2021, 11, 18, 0, 0, 0, 0, time.UTC)), dates.Value(1))
+}
+
+func TestWriteTypeMismatch(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+	ds.Rows[1].GetColumn("Quantity").CellValue = &types.CellValue{DataType: types.StringType, StringValue: "many"}
+	var buf bytes.Buffer
+	err := WriteStream(&buf, ds, nil)
+	assert.EqualError(err, "column “Quantity” has a StringType value but the column type is int32")
+}
+
+func TestWriteTimestampOutOfRange(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+	ds.Rows[0].GetColumn("Created").CellValue.TimestampValue = time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := WriteStream(&buf, ds, nil)
+	assert.EqualError(err, "column “Created” has the time 1600-01-01T00:00:00Z outside the nanosecond timestamp range")
+
+	ds.Rows[0].GetColumn("Created").CellValue.TimestampValue = time.Date(2262, 4, 11, 0, 0, 0, 0, time.UTC)
+	buf.Reset()
+	assert.Nil(WriteStream(&buf, ds, nil))
+}
+
+func TestReadStreamRoundTrip(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+
+	var buf bytes.Buffer
+	assert.Nil(WriteStream(&buf, ds, &WriterConfiguration{KeyColumn: "_key", BatchSize: 2}))
+	read, err := ReadStream(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(err)
+	assert.Len(read.Rows, 3)
+	assert.Len(read.Headers, 5)
+	assert.Equal(types.DateType, read.Headers["Trade Date"].DataType)
+	assert.Equal("key-1", *read.Rows[0].Key)
+	assert.Nil(read.Rows[1].Key)
+	for i, r := range read.Rows {
+		for _, c := range r.Columns {
+			expected := ds.Rows[i].GetColumn(c.ColumnName).CellValue
+			if expected.DataType == types.NilType {
+				assert.Equal(types.NilType, c.CellValue.DataType)
+				continue
+			}
+			assert.True(expected.Equals(c.CellValue), c.ColumnName)
+		}
+	}
+}
+
+func TestReadStreamRoundTripLongs(t *testing.T) {
+	assert := assert2.New(t)
+	ds := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil, dataset.LongColumn("Id", math.MaxInt64)),
+		dataset.DataRow(nil, dataset.LongColumn("Id", math.MinInt64+1)),
+		dataset.DataRow(nil, dataset.LongColumn("Id", 1<<53+1)),
+	})
+
+	var buf bytes.Buffer
+	assert.Nil(WriteStream(&buf, ds, nil))
+	read, err := ReadStream(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(err)
+	assert.Equal(types.LongType, read.Headers["Id"].DataType)
+	for i, r := range read.Rows {
+		assert.Equal(ds.Rows[i].GetColumn("Id").CellValue.LongValue, r.GetColumn("Id").CellValue.LongValue)
+	}
+}
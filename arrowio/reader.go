@@ -0,0 +1,268 @@
+package arrowio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/liminaab/filtrify/types"
+)
+
+type ReaderConfiguration struct {
+	// name of the column holding the row keys - columns written with a KeyColumn are found without it
+	KeyColumn string
+}
+
+var cellDataTypes = map[string]types.CellDataType{
+	types.IntType.String():       types.IntType,
+	types.LongType.String():      types.LongType,
+	types.TimestampType.String(): types.TimestampType,
+	types.StringType.String():    types.StringType,
+	types.DoubleType.String():    types.DoubleType,
+	types.BoolType.String():      types.BoolType,
+	types.NilType.String():       types.NilType,
+	types.ObjectType.String():    types.ObjectType,
+	types.DateType.String():      types.DateType,
+	types.TimeOfDayType.String(): types.TimeOfDayType,
+}
+
+// CellDataType returns the type the field is read as - the type written by this package wins over the arrow type
+func CellDataType(field arrow.Field) (types.CellDataType, error) {
+	if typeName, ok := field.Metadata.GetValue(dataTypeMetadataKey); ok {
+		if t, ok := cellDataTypes[typeName]; ok {
+			return t, nil
+		}
+	}
+	switch field.Type.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.UINT8, arrow.UINT16:
+		return types.IntType, nil
+	case arrow.INT64, arrow.UINT32, arrow.UINT64:
+		return types.LongType, nil
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		return types.DoubleType, nil
+	case arrow.BOOL:
+		return types.BoolType, nil
+	case arrow.STRING, arrow.LARGE_STRING:
+		return types.StringType, nil
+	case arrow.DATE32, arrow.DATE64:
+		return types.DateType, nil
+	case arrow.TIMESTAMP:
+		return types.TimestampType, nil
+	case arrow.TIME32, arrow.TIME64:
+		return types.TimeOfDayType, nil
+	case arrow.NULL:
+		return types.NilType, nil
+	}
+	return types.NilType, fmt.Errorf("column “%s” has unsupported type %s", field.Name, field.Type.String())
+}
+
+// IsRowKeyField tells if the field was written as the row key column
+func IsRowKeyField(field arrow.Field) bool {
+	_, ok := field.Metadata.GetValue(rowKeyMetadataKey)
+	return ok
+}
+
+// ReadStream reads a dataset in the arrow IPC streaming format
+func ReadStream(r io.Reader, config *ReaderConfiguration) (*types.DataSet, error) {
+	reader, err := ipc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+
+	builder, err := NewDataSetBuilder(reader.Schema(), config)
+	if err != nil {
+		return nil, err
+	}
+	for reader.Next() {
+		if err := builder.Append(reader.Record()); err != nil {
+			return nil, err
+		}
+	}
+	if err := reader.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return builder.DataSet(), nil
+}
+
+// ReadFile reads a dataset in the arrow IPC file format
+func ReadFile(r ipc.ReadAtSeeker, config *ReaderConfiguration) (*types.DataSet, error) {
+	reader, err := ipc.NewFileReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	builder, err := NewDataSetBuilder(reader.Schema(), config)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < reader.NumRecords(); i++ {
+		rec, err := reader.Record(i)
+		if err != nil {
+			return nil, err
+		}
+		if err := builder.Append(rec); err != nil {
+			return nil, err
+		}
+	}
+	return builder.DataSet(), nil
+}
+
+// DataSetBuilder collects the rows of record batches that share the same schema
+type DataSetBuilder struct {
+	schema    *arrow.Schema
+	keyIndex  int
+	dataTypes []types.CellDataType
+	headers   types.HeaderMap
+	rows      []*types.DataRow
+}
+
+func NewDataSetBuilder(schema *arrow.Schema, config *ReaderConfiguration) (*DataSetBuilder, error) {
+	if config == nil {
+		config = &ReaderConfiguration{}
+	}
+	b := &DataSetBuilder{
+		schema:    schema,
+		keyIndex:  -1,
+		dataTypes: make([]types.CellDataType, len(schema.Fields())),
+		headers:   make(types.HeaderMap),
+		rows:      make([]*types.DataRow, 0),
+	}
+	order := int64(0)
+	for i, f := range schema.Fields() {
+		if IsRowKeyField(f) || (len(config.KeyColumn) > 0 && f.Name == config.KeyColumn) {
+			b.keyIndex = i
+			continue
+		}
+		dataType, err := CellDataType(f)
+		if err != nil {
+			return nil, err
+		}
+		b.dataTypes[i] = dataType
+		b.headers[f.Name] = &types.Header{ColumnName: f.Name, DataType: dataType, Order: order}
+		order++
+	}
+	return b, nil
+}
+
+func (t *DataSetBuilder) Append(rec arrow.Record) error {
+	if !rec.Schema().Equal(t.schema) {
+		return fmt.Errorf("record schema doesn't match the dataset schema")
+	}
+	numRows := int(rec.NumRows())
+	rows := make([]*types.DataRow, numRows)
+	for i := range rows {
+		rows[i] = &types.DataRow{Columns: make([]*types.DataColumn, 0, len(t.headers))}
+	}
+	for ci, f := range t.schema.Fields() {
+		col := rec.Column(ci)
+		if ci == t.keyIndex {
+			for ri := range rows {
+				if col.IsNull(ri) {
+					continue
+				}
+				key := valueToString(col, ri)
+				rows[ri].Key = &key
+			}
+			continue
+		}
+		for ri := range rows {
+			cell, err := toCell(col, ri, t.dataTypes[ci])
+			if err != nil {
+				return fmt.Errorf("column “%s”: %s", f.Name, err.Error())
+			}
+			rows[ri].Columns = append(rows[ri].Columns, &types.DataColumn{ColumnName: f.Name, CellValue: cell})
+		}
+	}
+	t.rows = append(t.rows, rows...)
+	return nil
+}
+
+func (t *DataSetBuilder) DataSet() *types.DataSet {
+	return &types.DataSet{Rows: t.rows, Headers: t.headers}
+}
+
+func valueToString(col arrow.Array, i int) string {
+	switch arr := col.(type) {
+	case *array.String:
+		return arr.Value(i)
+	case *array.LargeString:
+		return arr.Value(i)
+	}
+	return col.ValueStr(i)
+}
+
+func toCell(col arrow.Array, i int, dataType types.CellDataType) (*types.CellValue, error) {
+	if col.IsNull(i) || dataType == types.NilType {
+		return &types.CellValue{DataType: types.NilType}, nil
+	}
+	switch arr := col.(type) {
+	case *array.Int8:
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(arr.Value(i))}, nil
+	case *array.Int16:
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(arr.Value(i))}, nil
+	case *array.Int32:
+		return &types.CellValue{DataType: types.IntType, IntValue: arr.Value(i)}, nil
+	case *array.Uint8:
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(arr.Value(i))}, nil
+	case *array.Uint16:
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(arr.Value(i))}, nil
+	case *array.Int64:
+		return &types.CellValue{DataType: types.LongType, LongValue: arr.Value(i)}, nil
+	case *array.Uint32:
+		return &types.CellValue{DataType: types.LongType, LongValue: int64(arr.Value(i))}, nil
+	case *array.Uint64:
+		return &types.CellValue{DataType: types.LongType, LongValue: int64(arr.Value(i))}, nil
+	case *array.Float16:
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(arr.Value(i).Float32())}, nil
+	case *array.Float32:
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(arr.Value(i))}, nil
+	case *array.Float64:
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: arr.Value(i)}, nil
+	case *array.Boolean:
+		return &types.CellValue{DataType: types.BoolType, BoolValue: arr.Value(i)}, nil
+	case *array.Date32:
+		return &types.CellValue{DataType: types.DateType, TimestampValue: arr.Value(i).ToTime()}, nil
+	case *array.Date64:
+		return &types.CellValue{DataType: types.DateType, TimestampValue: arr.Value(i).ToTime()}, nil
+	case *array.Timestamp:
+		unit := arr.DataType().(*arrow.TimestampType).Unit
+		ts := arr.Value(i).ToTime(unit)
+		if dataType == types.DateType {
+			return &types.CellValue{DataType: types.DateType, TimestampValue: dateOf(ts)}, nil
+		}
+		return &types.CellValue{DataType: types.TimestampType, TimestampValue: ts}, nil
+	case *array.Time32:
+		unit := arr.DataType().(*arrow.Time32Type).Unit
+		return &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: timeOfDay(time.Duration(arr.Value(i)) * unit.Multiplier())}, nil
+	case *array.Time64:
+		unit := arr.DataType().(*arrow.Time64Type).Unit
+		return &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: timeOfDay(time.Duration(arr.Value(i)) * unit.Multiplier())}, nil
+	case *array.String:
+		return stringCell(arr.Value(i), dataType)
+	case *array.LargeString:
+		return stringCell(arr.Value(i), dataType)
+	}
+	return nil, fmt.Errorf("unsupported type %s", col.DataType().String())
+}
+
+func stringCell(s string, dataType types.CellDataType) (*types.CellValue, error) {
+	if dataType != types.ObjectType {
+		return &types.CellValue{DataType: types.StringType, StringValue: s}, nil
+	}
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return nil, err
+	}
+	return &types.CellValue{DataType: types.ObjectType, ObjectValue: obj}, nil
+}
+
+// timeOfDay uses the same zero date time.Parse gives to a "15:04:05" value
+func timeOfDay(d time.Duration) time.Time {
+	return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(d)
+}
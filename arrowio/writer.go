@@ -0,0 +1,271 @@
+package arrowio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/liminaab/filtrify/types"
+)
+
+// the original CellDataType is kept in the field metadata - some types share the same arrow type
+const dataTypeMetadataKey = "filtrify.type"
+const rowKeyMetadataKey = "filtrify.rowkey"
+
+const defaultBatchSize = 64 * 1024
+
+type WriterConfiguration struct {
+	// name of an extra column holding the row keys - row keys are not written when it is empty
+	KeyColumn string
+	// number of rows per record batch
+	BatchSize int
+}
+
+func DefaultWriterConfiguration() *WriterConfiguration {
+	return &WriterConfiguration{
+		BatchSize: defaultBatchSize,
+	}
+}
+
+func ArrowType(t types.CellDataType) arrow.DataType {
+	switch t {
+	case types.IntType:
+		return arrow.PrimitiveTypes.Int32
+	case types.LongType:
+		return arrow.PrimitiveTypes.Int64
+	case types.DoubleType:
+		return arrow.PrimitiveTypes.Float64
+	case types.BoolType:
+		return arrow.FixedWidthTypes.Boolean
+	case types.DateType:
+		return arrow.FixedWidthTypes.Date32
+	case types.TimestampType:
+		return arrow.FixedWidthTypes.Timestamp_ns
+	case types.TimeOfDayType:
+		return arrow.FixedWidthTypes.Time64ns
+	case types.NilType:
+		return arrow.Null
+	}
+	// strings and objects - objects are written as their JSON text
+	return arrow.BinaryTypes.String
+}
+
+// Schema builds the arrow schema of the dataset from its headers
+func Schema(dataset *types.DataSet, config *WriterConfiguration) *arrow.Schema {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	headers := dataset.OrderedHeaders()
+	fields := make([]arrow.Field, 0, len(headers)+1)
+	if len(config.KeyColumn) > 0 {
+		fields = append(fields, arrow.Field{
+			Name:     config.KeyColumn,
+			Type:     arrow.BinaryTypes.String,
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{rowKeyMetadataKey}, []string{"true"}),
+		})
+	}
+	for _, h := range headers {
+		fields = append(fields, arrow.Field{
+			Name:     h.ColumnName,
+			Type:     ArrowType(h.DataType),
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{dataTypeMetadataKey}, []string{h.DataType.String()}),
+		})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// NewRecords converts the dataset to record batches - the caller must release them
+func NewRecords(dataset *types.DataSet, config *WriterConfiguration, mem memory.Allocator) ([]arrow.Record, error) {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	schema := Schema(dataset, config)
+	records := make([]arrow.Record, 0)
+	err := writeBatches(dataset, config, schema, mem, func(rec arrow.Record) error {
+		rec.Retain()
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		for _, rec := range records {
+			rec.Release()
+		}
+		return nil, err
+	}
+	return records, nil
+}
+
+// EachRecord converts the dataset batch by batch - the record is released after the callback returns
+func EachRecord(dataset *types.DataSet, config *WriterConfiguration, mem memory.Allocator, fn func(arrow.Record) error) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	return writeBatches(dataset, config, Schema(dataset, config), mem, fn)
+}
+
+// WriteStream writes the dataset in the arrow IPC streaming format
+func WriteStream(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	mem := memory.NewGoAllocator()
+	schema := Schema(dataset, config)
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	err := writeBatches(dataset, config, schema, mem, writer.Write)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WriteFile writes the dataset in the arrow IPC file format
+func WriteFile(w io.WriteSeeker, dataset *types.DataSet, config *WriterConfiguration) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	mem := memory.NewGoAllocator()
+	schema := Schema(dataset, config)
+	writer, err := ipc.NewFileWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		return err
+	}
+	err = writeBatches(dataset, config, schema, mem, writer.Write)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeBatches(dataset *types.DataSet, config *WriterConfiguration, schema *arrow.Schema, mem memory.Allocator, write func(arrow.Record) error) error {
+	batchSize := config.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	offset := 0
+	if len(config.KeyColumn) > 0 {
+		offset = 1
+	}
+	for start := 0; start < len(dataset.Rows); start += batchSize {
+		end := start + batchSize
+		if end > len(dataset.Rows) {
+			end = len(dataset.Rows)
+		}
+		for _, r := range dataset.Rows[start:end] {
+			if offset == 1 {
+				keyBuilder := builder.Field(0).(*array.StringBuilder)
+				if r.Key == nil {
+					keyBuilder.AppendNull()
+				} else {
+					keyBuilder.Append(*r.Key)
+				}
+			}
+			colIndex := make(map[string]*types.DataColumn, len(r.Columns))
+			for _, c := range r.Columns {
+				colIndex[c.ColumnName] = c
+			}
+			for i := offset; i < len(schema.Fields()); i++ {
+				field := schema.Field(i)
+				var cell *types.CellValue
+				if c, ok := colIndex[field.Name]; ok {
+					cell = c.CellValue
+				}
+				if err := appendCell(builder.Field(i), field, cell); err != nil {
+					return err
+				}
+			}
+		}
+		rec := builder.NewRecord()
+		err := write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendCell(b array.Builder, field arrow.Field, cell *types.CellValue) error {
+	if cell == nil || cell.DataType == types.NilType {
+		b.AppendNull()
+		return nil
+	}
+	switch builder := b.(type) {
+	case *array.Int32Builder:
+		if cell.DataType != types.IntType {
+			return buildTypeMismatchError(field, cell)
+		}
+		builder.Append(cell.IntValue)
+	case *array.Int64Builder:
+		switch cell.DataType {
+		case types.IntType:
+			builder.Append(int64(cell.IntValue))
+		case types.LongType:
+			builder.Append(cell.LongValue)
+		default:
+			return buildTypeMismatchError(field, cell)
+		}
+	case *array.Float64Builder:
+		if !cell.IsNumeric() {
+			return buildTypeMismatchError(field, cell)
+		}
+		builder.Append(cell.GetNumericVal())
+	case *array.BooleanBuilder:
+		if cell.DataType != types.BoolType {
+			return buildTypeMismatchError(field, cell)
+		}
+		builder.Append(cell.BoolValue)
+	case *array.Date32Builder:
+		if cell.DataType != types.DateType && cell.DataType != types.TimestampType {
+			return buildTypeMismatchError(field, cell)
+		}
+		builder.Append(arrow.Date32FromTime(dateOf(cell.TimestampValue)))
+	case *array.TimestampBuilder:
+		if cell.DataType != types.DateType && cell.DataType != types.TimestampType {
+			return buildTypeMismatchError(field, cell)
+		}
+		if cell.TimestampValue.Before(minTimestamp) || cell.TimestampValue.After(maxTimestamp) {
+			return fmt.Errorf("column “%s” has the time %s outside the nanosecond timestamp range", field.Name, cell.TimestampValue.Format(time.RFC3339))
+		}
+		builder.Append(arrow.Timestamp(cell.TimestampValue.UnixNano()))
+	case *array.Time64Builder:
+		if cell.DataType != types.TimeOfDayType && cell.DataType != types.TimestampType {
+			return buildTypeMismatchError(field, cell)
+		}
+		builder.Append(arrow.Time64(nanosOfDay(cell.TimestampValue)))
+	case *array.StringBuilder:
+		builder.Append(cell.ToString())
+	default:
+		// a null column with a value - the header was wrong
+		return buildTypeMismatchError(field, cell)
+	}
+	return nil
+}
+
+// nanosecond timestamps only reach from 1677 to 2262
+var (
+	minTimestamp = time.Unix(0, math.MinInt64)
+	maxTimestamp = time.Unix(0, math.MaxInt64)
+)
+
+func buildTypeMismatchError(field arrow.Field, cell *types.CellValue) error {
+	return fmt.Errorf("column “%s” has a %s value but the column type is %s", field.Name, cell.DataType.String(), field.Type.String())
+}
+
+// dateOf keeps the calendar day of the time in its own location
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func nanosOfDay(t time.Time) int64 {
+	return int64(t.Hour())*int64(time.Hour) + int64(t.Minute())*int64(time.Minute) + int64(t.Second())*int64(time.Second) + int64(t.Nanosecond())
+}
@@ -3,6 +3,7 @@ package conversion
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,17 @@ const minTimestampValMiliseconds int64 = 946684800000
 // 2100-01-01
 const maxTimestampValMiliseconds int64 = 4102444800000
 
+// ExcelEpochDays is the number of days between the Excel epoch 1899-12-30 and the Unix epoch
+const ExcelEpochDays int64 = 25569
+
+// ExcelDateToTime converts an Excel serial date - days since the Excel epoch with the time of day
+// as the fraction - to a UTC time rounded to milliseconds
+func ExcelDateToTime(serial float64) time.Time {
+	days := math.Floor(serial)
+	millis := math.Round((serial - days) * 86400 * 1000)
+	return time.Unix((int64(days)-ExcelEpochDays)*86400, 0).In(time.UTC).Add(time.Duration(millis) * time.Millisecond)
+}
+
 type ConversionMap map[string]bool
 
 var wellknownFormats = []string{
@@ -429,6 +441,7 @@ func ConvertToTypedData(rawData [][]string, firstLineIsHeader bool, convertDataT
 		typedHeaders[headers[i]] = &types.Header{
 			ColumnName: headers[i],
 			DataType:   cellTypes[i].DataType,
+			Order:      int64(i),
 		}
 	}
 
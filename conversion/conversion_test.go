@@ -4,6 +4,7 @@ import (
 	"github.com/liminaab/filtrify/types"
 	assert2 "github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 var sampleData = [][]string{
@@ -85,3 +86,11 @@ func TestDateConversion2(t *testing.T) {
 		assert.Equal("02/13/2024", col.CellValue.TimestampValue.Format("01/02/2006"))
 	}
 }
+
+func TestExcelDateToTime(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), ExcelDateToTime(25569))
+	// the fraction is the time of day
+	assert.Equal(time.Date(2021, 3, 14, 15, 4, 5, 0, time.UTC), ExcelDateToTime(44269.627835648))
+	assert.Equal(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC), ExcelDateToTime(0))
+}
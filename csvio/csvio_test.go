@@ -0,0 +1,111 @@
+package csvio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const sampleCSV = "Instrument,Quantity,Price,Active,Trade Date\n" +
+	"ERIC B SS Equity,175000,12.5,true,2021-03-14\n" +
+	"\"AMZN, US Equity\",1500,3200.25,false,2021-11-18\n" +
+	"\"ESZ1 \"\"Future\"\"\",-10,4500,,2021-12-16\n"
+
+func TestReadCSV(t *testing.T) {
+	assert := assert2.New(t)
+	ds, err := Read(strings.NewReader(sampleCSV), nil)
+	assert.Nil(err)
+	assert.Len(ds.Rows, 3)
+
+	assert.Equal("AMZN, US Equity", ds.Rows[1].GetColumn("Instrument").CellValue.StringValue)
+	assert.Equal("ESZ1 \"Future\"", ds.Rows[2].GetColumn("Instrument").CellValue.StringValue)
+	assert.Equal(types.IntType, ds.Headers["Quantity"].DataType)
+	assert.Equal(types.DoubleType, ds.Headers["Price"].DataType)
+	assert.Equal(types.BoolType, ds.Headers["Active"].DataType)
+	assert.Equal(types.DateType, ds.Headers["Trade Date"].DataType)
+	assert.Equal(types.NilType, ds.Rows[2].GetColumn("Active").CellValue.DataType)
+	assert.Equal(int64(4), ds.Headers["Trade Date"].Order)
+}
+
+func TestReadTSVWithCommentsAndOffset(t *testing.T) {
+	assert := assert2.New(t)
+	data := "Exported by some system\r\n" +
+		"generated 2021-01-01\r\n" +
+		"name\tnote\r\n" +
+		"# this line is a comment\r\n" +
+		"\r\n" +
+		"first\t'multi\r\nline'\r\n" +
+		"second\t'it''s'\r\n"
+	config := TSVReaderConfiguration()
+	config.Quote = '\''
+	config.Comment = '#'
+	config.HeaderRowOffset = 2
+	ds, err := Read(strings.NewReader(data), config)
+	assert.Nil(err)
+	assert.Len(ds.Rows, 2)
+	assert.Equal("multi\r\nline", ds.Rows[0].GetColumn("note").CellValue.StringValue)
+	assert.Equal("it's", ds.Rows[1].GetColumn("note").CellValue.StringValue)
+}
+
+func TestReadEncodings(t *testing.T) {
+	assert := assert2.New(t)
+	text := "name;city\nJosé;Malmö\n"
+
+	withBOM := append([]byte("\uFEFF"), []byte(text)...)
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(text))
+	assert.Nil(err)
+	windows1252, err := charmap.Windows1252.NewEncoder().Bytes([]byte(text))
+	assert.Nil(err)
+
+	inputs := map[Encoding][]byte{
+		UTF8:        withBOM,
+		UTF16:       utf16,
+		Windows1252: windows1252,
+	}
+	for enc, input := range inputs {
+		config := DefaultReaderConfiguration()
+		config.Delimiter = ';'
+		config.Encoding = enc
+		ds, err := Read(bytes.NewReader(input), config)
+		assert.Nil(err, enc.String())
+		assert.Len(ds.Rows, 1, enc.String())
+		assert.Equal("José", ds.Rows[0].GetColumn("name").CellValue.StringValue, enc.String())
+		assert.Equal("Malmö", ds.Rows[0].GetColumn("city").CellValue.StringValue, enc.String())
+	}
+}
+
+func TestReadUnterminatedQuote(t *testing.T) {
+	assert := assert2.New(t)
+	_, err := Read(strings.NewReader("a,b\n1,\"open\n"), nil)
+	assert.NotNil(err)
+	parseErr, ok := err.(*ParseError)
+	assert.True(ok)
+	assert.Equal(2, parseErr.Line)
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	assert := assert2.New(t)
+	ds, err := Read(strings.NewReader(sampleCSV), nil)
+	assert.Nil(err)
+
+	var buf bytes.Buffer
+	err = Write(&buf, ds, nil)
+	assert.Nil(err)
+	assert.Equal(sampleCSV, buf.String())
+
+	// header order wins over the column order of the rows
+	ds.Headers["Price"].Order = -1
+	buf.Reset()
+	config := TSVWriterConfiguration()
+	config.UseCRLF = true
+	err = Write(&buf, ds, config)
+	assert.Nil(err)
+	lines := strings.Split(buf.String(), "\r\n")
+	assert.Equal("Price\tInstrument\tQuantity\tActive\tTrade Date", lines[0])
+	assert.Equal("4500\t\"ESZ1 \"\"Future\"\"\"\t-10\t\t2021-12-16", lines[3])
+}
@@ -0,0 +1,43 @@
+package csvio
+
+import (
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+type Encoding int
+
+const (
+	// UTF8 is the default encoding - a leading UTF-8 or UTF-16 BOM is detected and removed
+	UTF8 Encoding = iota
+	// UTF16 uses the BOM to find out the byte order and falls back to little endian without one
+	UTF16
+	Windows1252
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "UTF8"
+	case UTF16:
+		return "UTF16"
+	case Windows1252:
+		return "Windows1252"
+	}
+	return "Unknown"
+}
+
+func decodingReader(r io.Reader, e Encoding) io.Reader {
+	switch e {
+	case UTF16:
+		return transform.NewReader(r, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder())
+	case Windows1252:
+		return transform.NewReader(r, charmap.Windows1252.NewDecoder())
+	default:
+		return transform.NewReader(r, unicode.BOMOverride(encoding.Nop.NewDecoder()))
+	}
+}
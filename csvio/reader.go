@@ -0,0 +1,342 @@
+package csvio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/liminaab/filtrify/conversion"
+	"github.com/liminaab/filtrify/types"
+)
+
+type ReaderConfiguration struct {
+	Delimiter rune
+	Quote     rune
+	// lines starting with this character are skipped - zero disables comments
+	Comment  rune
+	Encoding Encoding
+	// number of lines to skip before the header row (or the first data row)
+	HeaderRowOffset   int
+	FirstLineIsHeader bool
+	ConvertDataTypes  bool
+	ConvertNumbers    bool
+	ConversionMap     conversion.ConversionMap
+}
+
+// DefaultReaderConfiguration reads comma separated UTF-8 data with a header row and converts the column types
+func DefaultReaderConfiguration() *ReaderConfiguration {
+	return &ReaderConfiguration{
+		Delimiter:         ',',
+		Quote:             '"',
+		Encoding:          UTF8,
+		FirstLineIsHeader: true,
+		ConvertDataTypes:  true,
+		ConvertNumbers:    true,
+	}
+}
+
+// TSVReaderConfiguration is the same as DefaultReaderConfiguration but tab separated
+func TSVReaderConfiguration() *ReaderConfiguration {
+	config := DefaultReaderConfiguration()
+	config.Delimiter = '\t'
+	return config
+}
+
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("csv parse error on line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var ErrUnterminatedQuote = errors.New("unterminated quoted field")
+
+// RecordReader reads delimited records one by one from the underlying reader
+type RecordReader struct {
+	r       *bufio.Reader
+	config  *ReaderConfiguration
+	line    int
+	skipped bool
+	field   strings.Builder
+}
+
+func NewRecordReader(r io.Reader, config *ReaderConfiguration) (*RecordReader, error) {
+	if config == nil {
+		config = DefaultReaderConfiguration()
+	}
+	if err := validateConfiguration(config.Delimiter, config.Quote); err != nil {
+		return nil, err
+	}
+	if config.Comment == config.Delimiter || (config.Comment != 0 && config.Comment == config.Quote) {
+		return nil, errors.New("comment character can't be the delimiter or the quote character")
+	}
+	if config.HeaderRowOffset < 0 {
+		return nil, errors.New("header row offset can't be negative")
+	}
+	return &RecordReader{
+		r:      bufio.NewReader(decodingReader(r, config.Encoding)),
+		config: config,
+	}, nil
+}
+
+func validateConfiguration(delimiter rune, quote rune) error {
+	if delimiter == 0 || delimiter == '\r' || delimiter == '\n' {
+		return errors.New("invalid delimiter")
+	}
+	if quote == 0 || quote == '\r' || quote == '\n' {
+		return errors.New("invalid quote character")
+	}
+	if delimiter == quote {
+		return errors.New("delimiter and quote character can't be the same")
+	}
+	return nil
+}
+
+// Read returns the next record - blank lines and comments are skipped. It returns io.EOF at the end of the input
+func (t *RecordReader) Read() ([]string, error) {
+	if !t.skipped {
+		t.skipped = true
+		for i := 0; i < t.config.HeaderRowOffset; i++ {
+			if err := t.skipLine(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for {
+		record, err := t.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			return record, nil
+		}
+	}
+}
+
+// ReadAll reads all of the remaining records
+func (t *RecordReader) ReadAll() ([][]string, error) {
+	records := make([][]string, 0, 1000)
+	for {
+		record, err := t.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+func (t *RecordReader) skipLine() error {
+	t.line++
+	_, err := t.r.ReadString('\n')
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// readRecord returns nil without an error for the lines that don't contain a record
+func (t *RecordReader) readRecord() ([]string, error) {
+	peeked, err := t.r.Peek(utf8.UTFMax)
+	if len(peeked) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	t.line++
+	startLine := t.line
+	first, _ := utf8.DecodeRune(peeked)
+	if t.config.Comment != 0 && first == t.config.Comment {
+		return nil, t.skipRestOfLine()
+	}
+	if first == '\n' {
+		t.r.Discard(1)
+		return nil, nil
+	}
+	if first == '\r' && len(peeked) > 1 && peeked[1] == '\n' {
+		t.r.Discard(2)
+		return nil, nil
+	}
+
+	record := make([]string, 0)
+	for {
+		value, endOfRecord, err := t.readField()
+		if err != nil {
+			if err == ErrUnterminatedQuote {
+				return nil, &ParseError{Line: startLine, Err: err}
+			}
+			return nil, err
+		}
+		record = append(record, value)
+		if endOfRecord {
+			return record, nil
+		}
+	}
+}
+
+func (t *RecordReader) skipRestOfLine() error {
+	_, err := t.r.ReadString('\n')
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// isNewLine consumes a line ending that starts with c - a lone carriage return is not a line ending
+func (t *RecordReader) isNewLine(c rune) bool {
+	if c == '\n' {
+		return true
+	}
+	if c != '\r' {
+		return false
+	}
+	if next, err := t.r.Peek(1); err == nil && next[0] == '\n' {
+		t.r.Discard(1)
+		return true
+	}
+	return false
+}
+
+func (t *RecordReader) readField() (string, bool, error) {
+	t.field.Reset()
+	c, _, err := t.r.ReadRune()
+	if err == io.EOF {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if c != t.config.Quote {
+		// plain field - everything until the next delimiter or line ending
+		for {
+			if c == t.config.Delimiter {
+				return t.field.String(), false, nil
+			}
+			if t.isNewLine(c) {
+				return t.field.String(), true, nil
+			}
+			t.field.WriteRune(c)
+			c, _, err = t.r.ReadRune()
+			if err == io.EOF {
+				return t.field.String(), true, nil
+			}
+			if err != nil {
+				return "", false, err
+			}
+		}
+	}
+
+	// quoted field - delimiters and line endings are part of the value
+	for {
+		c, _, err = t.r.ReadRune()
+		if err == io.EOF {
+			return "", false, ErrUnterminatedQuote
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if c == '\n' {
+			t.line++
+		}
+		if c != t.config.Quote {
+			t.field.WriteRune(c)
+			continue
+		}
+		// a doubled quote is an escaped quote
+		next, _, err := t.r.ReadRune()
+		if err == io.EOF {
+			return t.field.String(), true, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if next == t.config.Quote {
+			t.field.WriteRune(next)
+			continue
+		}
+		if next == t.config.Delimiter {
+			return t.field.String(), false, nil
+		}
+		if t.isNewLine(next) {
+			return t.field.String(), true, nil
+		}
+		// we are lenient here - text after the closing quote is added to the value
+		t.field.WriteRune(next)
+		for {
+			c, _, err = t.r.ReadRune()
+			if err == io.EOF {
+				return t.field.String(), true, nil
+			}
+			if err != nil {
+				return "", false, err
+			}
+			if c == t.config.Delimiter {
+				return t.field.String(), false, nil
+			}
+			if t.isNewLine(c) {
+				return t.field.String(), true, nil
+			}
+			t.field.WriteRune(c)
+		}
+	}
+}
+
+// ReadRaw reads all the records without any type conversion
+func ReadRaw(r io.Reader, config *ReaderConfiguration) ([][]string, error) {
+	rr, err := NewRecordReader(r, config)
+	if err != nil {
+		return nil, err
+	}
+	return rr.ReadAll()
+}
+
+// Read reads delimited data into a typed dataset
+func Read(r io.Reader, config *ReaderConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = DefaultReaderConfiguration()
+	}
+	rawData, err := ReadRaw(r, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.FirstLineIsHeader && len(rawData) > 0 {
+		if err := checkHeaders(rawData[0]); err != nil {
+			return nil, err
+		}
+	}
+	return conversion.ConvertToTypedData(rawData, config.FirstLineIsHeader, config.ConvertDataTypes, config.ConversionMap, config.ConvertNumbers)
+}
+
+func ReadFile(filePath string, config *ReaderConfiguration) (*types.DataSet, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, config)
+}
+
+func checkHeaders(headers []string) error {
+	seen := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		if seen[h] {
+			return fmt.Errorf("duplicate column “%s” in header row", h)
+		}
+		seen[h] = true
+	}
+	return nil
+}
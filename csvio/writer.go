@@ -0,0 +1,129 @@
+package csvio
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+type WriterConfiguration struct {
+	Delimiter  rune
+	Quote      rune
+	OmitHeader bool
+	UseCRLF    bool
+	// writes a UTF-8 byte order mark first - Excel needs it to detect UTF-8
+	WriteBOM bool
+}
+
+func DefaultWriterConfiguration() *WriterConfiguration {
+	return &WriterConfiguration{
+		Delimiter: ',',
+		Quote:     '"',
+	}
+}
+
+func TSVWriterConfiguration() *WriterConfiguration {
+	config := DefaultWriterConfiguration()
+	config.Delimiter = '\t'
+	return config
+}
+
+// Write serialises the dataset using the header order of the dataset.
+// Cells are formatted with CellValue.ToString so nil cells become empty fields
+func Write(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	if err := validateConfiguration(config.Delimiter, config.Quote); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if config.WriteBOM {
+		if _, err := bw.WriteString("\uFEFF"); err != nil {
+			return err
+		}
+	}
+
+	headers := dataset.OrderedHeaders()
+	record := make([]string, len(headers))
+	if !config.OmitHeader {
+		for i, h := range headers {
+			record[i] = h.ColumnName
+		}
+		if err := writeRecord(bw, record, config); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range dataset.Rows {
+		colIndex := make(map[string]*types.DataColumn, len(r.Columns))
+		for _, c := range r.Columns {
+			colIndex[c.ColumnName] = c
+		}
+		for i, h := range headers {
+			record[i] = ""
+			if c, ok := colIndex[h.ColumnName]; ok {
+				record[i] = c.CellValue.ToString()
+			}
+		}
+		if err := writeRecord(bw, record, config); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func WriteFile(filePath string, dataset *types.DataSet, config *WriterConfiguration) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	err = Write(f, dataset, config)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeRecord(w *bufio.Writer, record []string, config *WriterConfiguration) error {
+	quote := string(config.Quote)
+	for i, field := range record {
+		if i > 0 {
+			if _, err := w.WriteRune(config.Delimiter); err != nil {
+				return err
+			}
+		}
+		// a single empty field would be an empty line - which readers skip
+		if !needsQuotes(field, config) && !(field == "" && len(record) == 1) {
+			if _, err := w.WriteString(field); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := w.WriteString(quote + strings.ReplaceAll(field, quote, quote+quote) + quote); err != nil {
+			return err
+		}
+	}
+	lineEnding := "\n"
+	if config.UseCRLF {
+		lineEnding = "\r\n"
+	}
+	_, err := w.WriteString(lineEnding)
+	return err
+}
+
+func needsQuotes(field string, config *WriterConfiguration) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, config.Delimiter) || strings.ContainsRune(field, config.Quote) || strings.ContainsAny(field, "\r\n") {
+		return true
+	}
+	// leading spaces would be trimmed by some readers
+	return field[0] == ' ' || field[0] == '\t'
+}
@@ -87,3 +87,64 @@ func Lte(field string, value string) *FilterCriteria {
 		},
 	}
 }
+
+func criteria(field string, operatorName string, value string, values ...string) *FilterCriteria {
+	return &FilterCriteria{
+		Criteria: &operator.Criteria{
+			FieldName: field,
+			Operator:  operatorName,
+			Value:     value,
+			Values:    values,
+		},
+	}
+}
+
+func StartsWith(field string, value string) *FilterCriteria {
+	return criteria(field, "STARTS WITH", value)
+}
+
+func EndsWith(field string, value string) *FilterCriteria {
+	return criteria(field, "ENDS WITH", value)
+}
+
+func EqIgnoreCase(field string, value string) *FilterCriteria {
+	return criteria(field, "EQUALS IGNORE CASE", value)
+}
+
+func ContainsIgnoreCase(field string, value string) *FilterCriteria {
+	return criteria(field, "CONTAINS IGNORE CASE", value)
+}
+
+// Like matches the whole value - % matches any text and _ any character
+func Like(field string, pattern string) *FilterCriteria {
+	return criteria(field, "LIKE", pattern)
+}
+
+func RegexMatch(field string, pattern string) *FilterCriteria {
+	return criteria(field, "REGEX MATCH", pattern)
+}
+
+// Between includes the bounds
+func Between(field string, from string, to string) *FilterCriteria {
+	return criteria(field, "BETWEEN", "", from, to)
+}
+
+func BetweenExclusive(field string, from string, to string) *FilterCriteria {
+	return criteria(field, "BETWEEN EXCLUSIVE", "", from, to)
+}
+
+func In(field string, values ...string) *FilterCriteria {
+	return criteria(field, "IN", "", values...)
+}
+
+func NotIn(field string, values ...string) *FilterCriteria {
+	return criteria(field, "NOT IN", "", values...)
+}
+
+func IsTrue(field string) *FilterCriteria {
+	return criteria(field, "IS TRUE", "")
+}
+
+func IsFalse(field string) *FilterCriteria {
+	return criteria(field, "IS FALSE", "")
+}
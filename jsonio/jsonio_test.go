@@ -0,0 +1,114 @@
+package jsonio
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+const sampleArray = `[
+	{"account": "acc-1", "quantity": 10, "price": 12.5, "active": true, "address": {"city": "Stockholm", "zip": 11122}},
+	{"account": "acc-2", "quantity": 3000000000, "price": 3, "tags": ["a", "b"], "address": {"city": "Oslo"}},
+	{"account": "acc-3", "quantity": null, "price": 1.25, "active": false, "extra": "only here"}
+]`
+
+func TestReadArrayWithObjects(t *testing.T) {
+	assert := assert2.New(t)
+	ds, err := Read(strings.NewReader(sampleArray), nil)
+	assert.Nil(err)
+	assert.Len(ds.Rows, 3)
+
+	headers := ds.OrderedHeaders()
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		names[i] = h.ColumnName
+	}
+	assert.Equal([]string{"account", "quantity", "price", "active", "address", "tags", "extra"}, names)
+
+	assert.Equal(types.StringType, ds.Headers["account"].DataType)
+	assert.Equal(types.LongType, ds.Headers["quantity"].DataType)
+	assert.Equal(types.DoubleType, ds.Headers["price"].DataType)
+	assert.Equal(types.BoolType, ds.Headers["active"].DataType)
+	assert.Equal(types.ObjectType, ds.Headers["address"].DataType)
+	assert.Equal(types.StringType, ds.Headers["tags"].DataType)
+
+	assert.Equal(types.NilType, ds.Rows[2].GetColumn("quantity").CellValue.DataType)
+	assert.Equal(types.NilType, ds.Rows[1].GetColumn("active").CellValue.DataType)
+	assert.Equal(types.NilType, ds.Rows[0].GetColumn("extra").CellValue.DataType)
+	assert.Equal(3.0, ds.Rows[1].GetColumn("price").CellValue.DoubleValue)
+	assert.Equal("Stockholm", ds.Rows[0].GetColumn("address").CellValue.ObjectValue["city"])
+	assert.Equal(int64(11122), ds.Rows[0].GetColumn("address").CellValue.ObjectValue["zip"])
+	assert.Equal(`["a","b"]`, ds.Rows[1].GetColumn("tags").CellValue.StringValue)
+}
+
+func TestReadNDJSONFlattened(t *testing.T) {
+	assert := assert2.New(t)
+	data := "{\"id\": 1, \"trade\": {\"date\": \"2021-03-14\", \"fx\": {\"rate\": 1.1}}}\n" +
+		"\n" +
+		"{\"id\": 2, \"trade\": {\"date\": \"2021-11-18\", \"fx\": {\"rate\": 10}}, \"note\": 5}\n" +
+		"{\"id\": 3, \"note\": \"five\"}\n"
+	config := DefaultReaderConfiguration()
+	config.FlattenNested = true
+	config.ConvertStrings = true
+	ds, err := Read(strings.NewReader(data), config)
+	assert.Nil(err)
+	assert.Len(ds.Rows, 3)
+	assert.Equal(types.IntType, ds.Headers["id"].DataType)
+	assert.Equal(types.DateType, ds.Headers["trade.date"].DataType)
+	assert.Equal(types.DoubleType, ds.Headers["trade.fx.rate"].DataType)
+	assert.Equal(10.0, ds.Rows[1].GetColumn("trade.fx.rate").CellValue.DoubleValue)
+	// numbers and text in the same column are kept as text
+	assert.Equal(types.StringType, ds.Headers["note"].DataType)
+	assert.Equal("5", ds.Rows[1].GetColumn("note").CellValue.StringValue)
+	assert.Equal(types.NilType, ds.Rows[2].GetColumn("trade.date").CellValue.DataType)
+}
+
+func TestReadInvalidRecords(t *testing.T) {
+	assert := assert2.New(t)
+	_, err := Read(strings.NewReader(`[{"a": 1}, 2]`), nil)
+	assert.EqualError(err, "record 2 is not a JSON object")
+	_, err = Read(strings.NewReader("{\"a\": 1}\n{\"a\": "), nil)
+	assert.NotNil(err)
+}
+
+func TestWriteJSONAndNDJSON(t *testing.T) {
+	assert := assert2.New(t)
+	key := "row-1"
+	ds := &types.DataSet{
+		Rows: []*types.DataRow{
+			{Key: &key, Columns: []*types.DataColumn{
+				{ColumnName: "name", CellValue: &types.CellValue{DataType: types.StringType, StringValue: "a \"quoted\" name"}},
+				{ColumnName: "qty", CellValue: &types.CellValue{DataType: types.IntType, IntValue: 10}},
+				{ColumnName: "price", CellValue: &types.CellValue{DataType: types.DoubleType, DoubleValue: 1.5}},
+			}},
+			{Columns: []*types.DataColumn{
+				{ColumnName: "name", CellValue: &types.CellValue{DataType: types.StringType, StringValue: "b"}},
+				{ColumnName: "qty", CellValue: &types.CellValue{DataType: types.NilType}},
+				{ColumnName: "price", CellValue: &types.CellValue{DataType: types.DoubleType, DoubleValue: math.NaN()}},
+			}},
+		},
+		Headers: types.HeaderMap{
+			"name":  {ColumnName: "name", DataType: types.StringType, Order: 0},
+			"qty":   {ColumnName: "qty", DataType: types.IntType, Order: 1},
+			"price": {ColumnName: "price", DataType: types.DoubleType, Order: 2},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(WriteNDJSON(&buf, ds, &WriterConfiguration{KeyField: "_key"}))
+	assert.Equal("{\"_key\":\"row-1\",\"name\":\"a \\\"quoted\\\" name\",\"qty\":10,\"price\":1.5}\n"+
+		"{\"_key\":null,\"name\":\"b\",\"qty\":null,\"price\":null}\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(WriteJSON(&buf, ds, nil))
+	read, err := Read(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(err)
+	assert.Len(read.Rows, 2)
+	assert.Equal(types.IntType, read.Headers["qty"].DataType)
+	assert.Equal("a \"quoted\" name", read.Rows[0].GetColumn("name").CellValue.StringValue)
+	assert.Equal(types.NilType, read.Rows[1].GetColumn("price").CellValue.DataType)
+}
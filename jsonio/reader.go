@@ -0,0 +1,436 @@
+package jsonio
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/liminaab/filtrify/conversion"
+	"github.com/liminaab/filtrify/types"
+)
+
+type Format int
+
+const (
+	// Auto detects the format from the first character of the input
+	Auto Format = iota
+	// Array is a JSON array of records
+	Array
+	// NDJSON is newline delimited JSON - one record per line
+	NDJSON
+)
+
+type ReaderConfiguration struct {
+	Format Format
+	// nested objects become dotted columns like "address.city" instead of ObjectType columns
+	FlattenNested bool
+	Separator     string
+	// text columns are passed through the same type estimation as CSV data - this is how dates are detected
+	ConvertStrings bool
+}
+
+func DefaultReaderConfiguration() *ReaderConfiguration {
+	return &ReaderConfiguration{
+		Format:    Auto,
+		Separator: ".",
+	}
+}
+
+// columnBuilder collects the values of a single column while the records are read
+type columnBuilder struct {
+	name   string
+	values map[int]interface{}
+}
+
+type recordCollector struct {
+	config  *ReaderConfiguration
+	columns []*columnBuilder
+	index   map[string]*columnBuilder
+	count   int
+}
+
+func Read(r io.Reader, config *ReaderConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = DefaultReaderConfiguration()
+	}
+	if len(config.Separator) == 0 {
+		config.Separator = "."
+	}
+	br := bufio.NewReader(r)
+	format := config.Format
+	if format == Auto {
+		first, err := firstNonSpace(br)
+		if err == io.EOF {
+			return &types.DataSet{Rows: []*types.DataRow{}, Headers: make(types.HeaderMap)}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		format = NDJSON
+		if first == '[' {
+			format = Array
+		}
+	}
+
+	collector := &recordCollector{
+		config: config,
+		index:  make(map[string]*columnBuilder),
+	}
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+
+	if format == Array {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("expected a JSON array of records")
+		}
+		for dec.More() {
+			if err := collector.decodeRecord(dec); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	} else {
+		for {
+			err := collector.decodeRecord(dec)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return collector.build()
+}
+
+func ReadFile(filePath string, config *ReaderConfiguration) (*types.DataSet, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, config)
+}
+
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case 0xEF:
+			// utf-8 byte order mark
+			bom, err := br.Peek(3)
+			if err == nil && string(bom) == "\xEF\xBB\xBF" {
+				br.Discard(3)
+				continue
+			}
+			return b[0], nil
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// object keeps the keys in the order of the input - the column order follows it
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	return decodeNextValue(dec, true)
+}
+
+// only the end of the input before a top level value is a clean io.EOF
+func decodeNextValue(dec *json.Decoder, topLevel bool) (interface{}, error) {
+	tok, err := dec.Token()
+	if err == io.EOF && !topLevel {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// string, json.Number, bool or nil
+		return tok, nil
+	}
+	switch delim {
+	case '{':
+		o := &object{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return nil, errors.New("invalid object key")
+			}
+			val, err := decodeNextValue(dec, false)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := o.values[key]; !exists {
+				o.keys = append(o.keys, key)
+			}
+			o.values[key] = val
+		}
+		return o, closeDelim(dec)
+	case '[':
+		list := make([]interface{}, 0)
+		for dec.More() {
+			val, err := decodeNextValue(dec, false)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, closeDelim(dec)
+	}
+	return nil, fmt.Errorf("unexpected %s", delim.String())
+}
+
+func closeDelim(dec *json.Decoder) error {
+	_, err := dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (t *recordCollector) decodeRecord(dec *json.Decoder) error {
+	record, err := decodeValue(dec)
+	if err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return fmt.Errorf("invalid JSON in record %d: %s", t.count+1, err.Error())
+	}
+	o, ok := record.(*object)
+	if !ok {
+		return fmt.Errorf("record %d is not a JSON object", t.count+1)
+	}
+	t.addObject("", o)
+	t.count++
+	return nil
+}
+
+func (t *recordCollector) addObject(prefix string, o *object) {
+	for _, k := range o.keys {
+		name := k
+		if len(prefix) > 0 {
+			name = prefix + t.config.Separator + k
+		}
+		if nested, ok := o.values[k].(*object); ok && t.config.FlattenNested {
+			t.addObject(name, nested)
+			continue
+		}
+		t.column(name).values[t.count] = o.values[k]
+	}
+}
+
+func (t *recordCollector) column(name string) *columnBuilder {
+	if c, ok := t.index[name]; ok {
+		return c
+	}
+	c := &columnBuilder{name: name, values: make(map[int]interface{})}
+	t.index[name] = c
+	t.columns = append(t.columns, c)
+	return c
+}
+
+func (t *recordCollector) build() (*types.DataSet, error) {
+	rows := make([]*types.DataRow, t.count)
+	for i := range rows {
+		rows[i] = &types.DataRow{Columns: make([]*types.DataColumn, len(t.columns))}
+	}
+	headers := make(types.HeaderMap, len(t.columns))
+	for ci, c := range t.columns {
+		cells, dataType, err := t.buildColumn(c)
+		if err != nil {
+			return nil, err
+		}
+		for ri := range rows {
+			rows[ri].Columns[ci] = &types.DataColumn{ColumnName: c.name, CellValue: cells[ri]}
+		}
+		headers[c.name] = &types.Header{ColumnName: c.name, DataType: dataType, Order: int64(ci)}
+	}
+	return &types.DataSet{Rows: rows, Headers: headers}, nil
+}
+
+// buildColumn infers the column type from the JSON value kinds
+// numbers are narrowed to Int or Long when all of them are integral.
+// When a column mixes different kinds all values are kept as text
+func (t *recordCollector) buildColumn(c *columnBuilder) ([]*types.CellValue, types.CellDataType, error) {
+	kind := types.NilType
+	mixed := false
+	numberType := types.IntType
+	for _, v := range c.values {
+		k := valueKind(v)
+		if k == types.NilType {
+			continue
+		}
+		if k == types.DoubleType {
+			numberType = widerNumberType(numberType, numberKind(v.(json.Number)))
+		}
+		if kind == types.NilType {
+			kind = k
+		} else if kind != k {
+			mixed = true
+		}
+	}
+	if kind == types.DoubleType {
+		kind = numberType
+	}
+	if mixed {
+		kind = types.StringType
+	}
+
+	cells := make([]*types.CellValue, t.count)
+	for ri := range cells {
+		v, ok := c.values[ri]
+		if !ok || v == nil {
+			cells[ri] = &types.CellValue{DataType: types.NilType}
+			continue
+		}
+		cell, err := toCell(v, kind)
+		if err != nil {
+			return nil, types.NilType, err
+		}
+		cells[ri] = cell
+	}
+
+	if kind == types.StringType && !mixed && t.config.ConvertStrings {
+		return convertStrings(c.name, cells)
+	}
+	if kind == types.NilType {
+		kind = types.StringType
+	}
+	return cells, kind, nil
+}
+
+func convertStrings(name string, cells []*types.CellValue) ([]*types.CellValue, types.CellDataType, error) {
+	rawData := make([][]string, len(cells)+1)
+	rawData[0] = []string{name}
+	for i, c := range cells {
+		rawData[i+1] = []string{c.ToString()}
+	}
+	converted, err := conversion.ConvertToTypedData(rawData, true, true, nil, false)
+	if err != nil {
+		return nil, types.NilType, err
+	}
+	for i, r := range converted.Rows {
+		cells[i] = r.Columns[0].CellValue
+	}
+	return cells, converted.Headers[name].DataType, nil
+}
+
+// valueKind returns DoubleType for all numbers - the exact number type is decided per column.
+// Arrays are kept as their JSON text
+func valueKind(v interface{}) types.CellDataType {
+	switch v.(type) {
+	case nil:
+		return types.NilType
+	case bool:
+		return types.BoolType
+	case json.Number:
+		return types.DoubleType
+	case *object:
+		return types.ObjectType
+	}
+	return types.StringType
+}
+
+func numberKind(n json.Number) types.CellDataType {
+	i, err := n.Int64()
+	if err != nil {
+		return types.DoubleType
+	}
+	if i > math.MaxInt32 || i < math.MinInt32 {
+		return types.LongType
+	}
+	return types.IntType
+}
+
+func widerNumberType(a types.CellDataType, b types.CellDataType) types.CellDataType {
+	if a == types.DoubleType || b == types.DoubleType {
+		return types.DoubleType
+	}
+	if a == types.LongType || b == types.LongType {
+		return types.LongType
+	}
+	return types.IntType
+}
+
+func toCell(v interface{}, kind types.CellDataType) (*types.CellValue, error) {
+	switch kind {
+	case types.IntType:
+		i, err := v.(json.Number).Int64()
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(i)}, err
+	case types.LongType:
+		i, err := v.(json.Number).Int64()
+		return &types.CellValue{DataType: types.LongType, LongValue: i}, err
+	case types.DoubleType:
+		f, err := v.(json.Number).Float64()
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: f}, err
+	case types.BoolType:
+		return &types.CellValue{DataType: types.BoolType, BoolValue: v.(bool)}, nil
+	case types.ObjectType:
+		if o, ok := v.(*object); ok {
+			return &types.CellValue{DataType: types.ObjectType, ObjectValue: plainValue(o).(map[string]interface{})}, nil
+		}
+	}
+	// strings and arrays - arrays keep their JSON text since cells can't hold lists
+	if s, ok := v.(string); ok {
+		return &types.CellValue{DataType: types.StringType, StringValue: s}, nil
+	}
+	b, err := json.Marshal(plainValue(v))
+	if err != nil {
+		return nil, err
+	}
+	return &types.CellValue{DataType: types.StringType, StringValue: string(b)}, nil
+}
+
+// plainValue replaces the decoded objects with maps and json.Number values with int64 or float64
+func plainValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case *object:
+		m := make(map[string]interface{}, len(val.keys))
+		for k, nested := range val.values {
+			m[k] = plainValue(nested)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(val))
+		for i := range val {
+			list[i] = plainValue(val[i])
+		}
+		return list
+	}
+	return v
+}
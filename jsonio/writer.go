@@ -0,0 +1,165 @@
+package jsonio
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+type WriterConfiguration struct {
+	// name of the field holding the row key - row keys are not written when it is empty
+	KeyField string
+}
+
+// RecordWriter streams rows as JSON objects - the fields follow the order of the headers
+type RecordWriter struct {
+	w       *bufio.Writer
+	headers []*types.Header
+	config  *WriterConfiguration
+	buf     []byte
+}
+
+func NewRecordWriter(w io.Writer, headers []*types.Header, config *WriterConfiguration) *RecordWriter {
+	if config == nil {
+		config = &WriterConfiguration{}
+	}
+	return &RecordWriter{
+		w:       bufio.NewWriter(w),
+		headers: headers,
+		config:  config,
+	}
+}
+
+// WriteRow writes a single row as a JSON object without any separator
+func (t *RecordWriter) WriteRow(row *types.DataRow) error {
+	colIndex := make(map[string]*types.DataColumn, len(row.Columns))
+	for _, c := range row.Columns {
+		colIndex[c.ColumnName] = c
+	}
+
+	t.buf = append(t.buf[:0], '{')
+	first := true
+	if len(t.config.KeyField) > 0 {
+		var key interface{}
+		if row.Key != nil {
+			key = *row.Key
+		}
+		if err := t.appendField(t.config.KeyField, key); err != nil {
+			return err
+		}
+		first = false
+	}
+	for _, h := range t.headers {
+		if !first {
+			t.buf = append(t.buf, ',')
+		}
+		first = false
+		var val interface{}
+		if c, ok := colIndex[h.ColumnName]; ok {
+			val = CellToJSONValue(c.CellValue)
+		}
+		if err := t.appendField(h.ColumnName, val); err != nil {
+			return err
+		}
+	}
+	t.buf = append(t.buf, '}')
+	_, err := t.w.Write(t.buf)
+	return err
+}
+
+func (t *RecordWriter) appendField(name string, val interface{}) error {
+	b, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	t.buf = append(t.buf, b...)
+	t.buf = append(t.buf, ':')
+	b, err = json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	t.buf = append(t.buf, b...)
+	return nil
+}
+
+func (t *RecordWriter) writeString(s string) error {
+	_, err := t.w.WriteString(s)
+	return err
+}
+
+func (t *RecordWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// WriteJSON writes the dataset as a JSON array of records with native JSON types
+func WriteJSON(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	rw := NewRecordWriter(w, dataset.OrderedHeaders(), config)
+	if err := rw.writeString("["); err != nil {
+		return err
+	}
+	for i, r := range dataset.Rows {
+		sep := ",\n"
+		if i == 0 {
+			sep = "\n"
+		}
+		if err := rw.writeString(sep); err != nil {
+			return err
+		}
+		if err := rw.WriteRow(r); err != nil {
+			return err
+		}
+	}
+	if err := rw.writeString("\n]\n"); err != nil {
+		return err
+	}
+	return rw.Flush()
+}
+
+// WriteNDJSON writes one JSON record per line
+func WriteNDJSON(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	rw := NewRecordWriter(w, dataset.OrderedHeaders(), config)
+	for _, r := range dataset.Rows {
+		if err := rw.WriteRow(r); err != nil {
+			return err
+		}
+		if err := rw.writeString("\n"); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+// CellToJSONValue returns the value that represents the cell in JSON.
+// Dates and times are written as text in the same formats CellValue.ToString uses, except timestamps keep their fractional seconds
+func CellToJSONValue(cell *types.CellValue) interface{} {
+	if cell == nil {
+		return nil
+	}
+	switch cell.DataType {
+	case types.IntType:
+		return cell.IntValue
+	case types.LongType:
+		return cell.LongValue
+	case types.DoubleType:
+		// JSON doesn't have NaN or infinity
+		if math.IsNaN(cell.DoubleValue) || math.IsInf(cell.DoubleValue, 0) {
+			return nil
+		}
+		return cell.DoubleValue
+	case types.BoolType:
+		return cell.BoolValue
+	case types.StringType:
+		return cell.StringValue
+	case types.TimestampType:
+		return cell.TimestampValue.Format(time.RFC3339Nano)
+	case types.DateType, types.TimeOfDayType:
+		return cell.ToString()
+	case types.ObjectType:
+		return cell.ObjectValue
+	}
+	return nil
+}
@@ -47,6 +47,7 @@ var operators map[string]expr.CustomFunc = map[string]expr.CustomFunc{
 	"date":             &operator.Date{},
 	"datetimeutc":      &operator.DateTimeUTC{},
 	"isblank":          &operator.IsBlank{},
+	"regexmatch":       &operator.RegexMatch{},
 	// we are removing it for now - qlbridge has built in and or functions
 	//"and":              &operator.AND{},
 	//"or":               &operator.OR{},
@@ -0,0 +1,48 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+type RegexMatch struct{}
+
+// patterns are compiled once - the same pattern is used for every row
+var regexMatchPatterns sync.Map
+
+// Type is Bool
+func (m *RegexMatch) Type() value.ValueType { return value.BoolType }
+func (m *RegexMatch) Validate(n *expr.FuncNode) (expr.EvaluatorFunc, error) {
+	if len(n.Args) != 2 {
+		return nil, fmt.Errorf("expected 2 args for regexmatch(str_value, pattern) but got %s", n)
+	}
+	return regexMatchEval, nil
+}
+
+func regexMatchEval(ctx expr.EvalContext, args []value.Value) (value.Value, bool) {
+	if args[0].Nil() || args[1].Nil() {
+		return value.BoolValueFalse, true
+	}
+	text, textOk := value.ValueToString(args[0])
+	pattern, patternOk := value.ValueToString(args[1])
+	if !textOk || !patternOk {
+		return value.BoolValueFalse, true
+	}
+
+	var re *regexp.Regexp
+	if cached, ok := regexMatchPatterns.Load(pattern); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return value.BoolValueFalse, false
+		}
+		regexMatchPatterns.Store(pattern, re)
+	}
+	return value.NewBoolValue(re.MatchString(text)), true
+}
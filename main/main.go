@@ -1,17 +1,14 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path"
 	"path/filepath"
 
 	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/csvio"
 	"github.com/liminaab/filtrify/operator"
 	"github.com/liminaab/filtrify/types"
 )
@@ -104,15 +101,10 @@ func buildTestFilterSteps() []*types.TransformationStep {
 func main() {
 	// let's prepare dummy operations here
 	// load CSV files
-	walletHeaders, wallets, err := loadCSVFileFromDataDir("wallet_csv")
+	ds, err := loadCSVFileFromDataDir("wallet_csv")
 	if err != nil {
 		panic(err)
 	}
-	wallets = append([][]string{walletHeaders}, wallets...)
-	ds, err := filtrify.ConvertToTypedData(wallets, true, true, true)
-	if err != nil {
-		panic(err.Error())
-	}
 	steps := buildTestFilterSteps()
 	newData, err := filtrify.Transform(ds, steps, nil)
 	if err != nil {
@@ -121,43 +113,7 @@ func main() {
 	fmt.Println(newData)
 }
 
-func loadCSVFile(filePath string) (headers []string, dataset [][]string, err error) {
-	var ior io.Reader
-	ior, err = os.Open(filePath)
-	if err != nil {
-		return nil, nil, err
-	}
-	// let's read columns
-	rc, ok := ior.(io.ReadCloser)
-	defer rc.Close()
-	if !ok {
-		return nil, nil, errors.New("File error possibly huh?")
-	}
-
-	buf := bufio.NewReader(ior)
-	csvr := csv.NewReader(buf)
-	csvr.TrailingComma = true
-	headers, err = csvr.Read()
-	if err != nil {
-		return nil, nil, err
-	}
-	// now lets load the complete file into memory
-	dataset = make([][]string, 0, 10000)
-	for {
-		var row []string
-		row, err = csvr.Read()
-		if err != nil {
-			if err == io.EOF {
-				err = nil
-			}
-			return headers, dataset, err
-		}
-		dataset = append(dataset, row)
-	}
-
-}
-
-func loadCSVFileFromDataDir(fileName string) (headers []string, dataset [][]string, err error) {
+func loadCSVFileFromDataDir(fileName string) (*types.DataSet, error) {
 	ex, err := os.Executable()
 	if err != nil {
 		panic(err)
@@ -167,5 +123,5 @@ func loadCSVFileFromDataDir(fileName string) (headers []string, dataset [][]stri
 	fmt.Println(exPath)
 	dataPath := path.Join(exPath, "testdata")
 	fullPath := filepath.Join(dataPath, fileName)
-	return loadCSVFile(fullPath)
+	return csvio.ReadFile(fullPath, csvio.DefaultReaderConfiguration())
 }
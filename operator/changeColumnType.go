@@ -12,6 +12,7 @@ import (
 	"time"
 
 	_ "github.com/araddon/qlbridge/qlbdriver"
+	"github.com/liminaab/filtrify/conversion"
 	"github.com/liminaab/filtrify/types"
 )
 
@@ -555,7 +556,7 @@ func intToString(input interface{}, config ConversionConfiguration) (interface{}
 	return convertedNumber, nil
 }
 
-const numberOfDaysBetweenUnixEpochAndExcelEpoch = 25569
+const numberOfDaysBetweenUnixEpochAndExcelEpoch = conversion.ExcelEpochDays
 
 func commonIntToTime(input int64, config ConversionConfiguration) (time.Time, error) {
 	if config.NumericDate != nil && config.NumericDate.IsUnixMillis {
@@ -0,0 +1,275 @@
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+type ClassifyOperator struct {
+}
+
+// ClassifyRule labels the rows it matches. A rule is one of
+//   - a range of the classified column from From to To - From is inclusive and To is exclusive by default
+//     so bands don't overlap, an empty bound is open. Numbers, dates and texts are compared as such
+//   - a regular expression Pattern or a Prefix of the classified column's text
+//   - any Criteria, which can use every column
+type ClassifyRule struct {
+	Label           string          `json:"label"`
+	From            string          `json:"from"`
+	To              string          `json:"to"`
+	FromExclusive   bool            `json:"fromExclusive"`
+	ToInclusive     bool            `json:"toInclusive"`
+	Pattern         string          `json:"pattern"`
+	Prefix          string          `json:"prefix"`
+	CaseInsensitive bool            `json:"caseInsensitive"`
+	Criteria        *FilterCriteria `json:"criteria"`
+}
+
+// ClassifyConfiguration adds NewColumnName with the label of the first rule matching the row,
+// or ElseValue when no rule matches - empty by default
+type ClassifyConfiguration struct {
+	ColumnName    string          `json:"columnName"`
+	NewColumnName string          `json:"newColumnName"`
+	Rules         []*ClassifyRule `json:"rules"`
+	ElseValue     *string         `json:"elseValue"`
+}
+
+func (r *ClassifyRule) isRange() bool {
+	return len(r.From) > 0 || len(r.To) > 0
+}
+
+// classifyBound returns the bound as a value of the column's type
+func classifyBound(bound string, colType types.CellDataType) (*types.CellValue, error) {
+	if len(bound) == 0 {
+		return nil, nil
+	}
+	switch {
+	case isNumberType(colType):
+		f, err := strconv.ParseFloat(strings.TrimSpace(bound), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number “%s” in classify rule", bound)
+		}
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: f}, nil
+	case isDateType(colType):
+		ts := tryParseDateAndTime(strings.TrimSpace(bound))
+		if ts == nil {
+			return nil, fmt.Errorf("invalid date “%s” in classify rule", bound)
+		}
+		return &types.CellValue{DataType: colType, TimestampValue: *ts}, nil
+	}
+	return &types.CellValue{DataType: types.StringType, StringValue: bound}, nil
+}
+
+// classifyMatcher checks a rule on the value of the classified column
+type classifyMatcher func(value *types.CellValue) bool
+
+func (t *ClassifyOperator) buildMatcher(rule *ClassifyRule, colType types.CellDataType) (classifyMatcher, error) {
+	if len(rule.Pattern) > 0 {
+		pattern := rule.Pattern
+		if rule.CaseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		// it's already validated
+		re := regexp.MustCompile(pattern)
+		return func(value *types.CellValue) bool {
+			return value != nil && value.DataType != types.NilType && re.MatchString(value.ToString())
+		}, nil
+	}
+	if len(rule.Prefix) > 0 {
+		return func(value *types.CellValue) bool {
+			if value == nil || value.DataType == types.NilType {
+				return false
+			}
+			text := value.ToString()
+			if rule.CaseInsensitive {
+				return len(text) >= len(rule.Prefix) && strings.EqualFold(text[:len(rule.Prefix)], rule.Prefix)
+			}
+			return strings.HasPrefix(text, rule.Prefix)
+		}, nil
+	}
+
+	from, err := classifyBound(rule.From, colType)
+	if err != nil {
+		return nil, err
+	}
+	to, err := classifyBound(rule.To, colType)
+	if err != nil {
+		return nil, err
+	}
+	return func(value *types.CellValue) bool {
+		if value == nil || value.DataType == types.NilType {
+			return false
+		}
+		if from != nil {
+			c, ok := compareJoinValues(value, from)
+			if !ok || c < 0 || (c == 0 && rule.FromExclusive) {
+				return false
+			}
+		}
+		if to != nil {
+			c, ok := compareJoinValues(value, to)
+			if !ok || c > 0 || (c == 0 && !rule.ToInclusive) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// criteriaMatches returns the indexes of the rows matching the criteria
+func (t *ClassifyOperator) criteriaMatches(dataset *types.DataSet, criteria *FilterCriteria) (map[int]bool, error) {
+	// rows are copied and keyed by their index - the filter adds a column to them
+	indexed := &types.DataSet{
+		Rows: make([]*types.DataRow, len(dataset.Rows)),
+	}
+	for i, r := range dataset.Rows {
+		key := strconv.Itoa(i)
+		indexed.Rows[i] = &types.DataRow{
+			Key:     &key,
+			Columns: append([]*types.DataColumn{}, r.Columns...),
+		}
+	}
+	filterOp := &FilterOperator{}
+	filtered, err := filterOp.TransformTyped(indexed, &FilterConfiguration{FilterCriteria: criteria})
+	if err != nil {
+		return nil, err
+	}
+	matches := make(map[int]bool, len(filtered.Rows))
+	for _, r := range filtered.Rows {
+		if r.Key == nil {
+			continue
+		}
+		if i, err := strconv.Atoi(*r.Key); err == nil {
+			matches[i] = true
+		}
+	}
+	return matches, nil
+}
+
+func (t *ClassifyOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataset.Rows) < 1 {
+		return dataset, nil
+	}
+
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	if _, ok := columnTypeMap[typedConfig.NewColumnName]; ok {
+		return nil, fmt.Errorf("column “%s” already exists", typedConfig.NewColumnName)
+	}
+	colType := types.NilType
+	if len(typedConfig.ColumnName) > 0 {
+		var ok bool
+		if colType, ok = columnTypeMap[typedConfig.ColumnName]; !ok {
+			return nil, buildColumnNotExistsError(typedConfig.ColumnName)
+		}
+	}
+
+	matchers := make([]classifyMatcher, len(typedConfig.Rules))
+	criteriaRows := make([]map[int]bool, len(typedConfig.Rules))
+	for i, rule := range typedConfig.Rules {
+		if rule.Criteria != nil {
+			criteriaRows[i], err = t.criteriaMatches(dataset, rule.Criteria)
+		} else {
+			matchers[i], err = t.buildMatcher(rule, colType)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newDataset := &types.DataSet{
+		Rows: make([]*types.DataRow, len(dataset.Rows)),
+	}
+	for ri, row := range dataset.Rows {
+		var value *types.CellValue
+		if len(typedConfig.ColumnName) > 0 {
+			if c := row.GetColumn(typedConfig.ColumnName); c != nil {
+				value = c.CellValue
+			}
+		}
+		label := &types.CellValue{DataType: types.NilType}
+		if typedConfig.ElseValue != nil {
+			label = &types.CellValue{DataType: types.StringType, StringValue: *typedConfig.ElseValue}
+		}
+		for i, rule := range typedConfig.Rules {
+			if (criteriaRows[i] != nil && criteriaRows[i][ri]) || (matchers[i] != nil && matchers[i](value)) {
+				label = &types.CellValue{DataType: types.StringType, StringValue: rule.Label}
+				break
+			}
+		}
+
+		newRow := &types.DataRow{
+			Key:     row.Key,
+			Columns: make([]*types.DataColumn, 0, len(row.Columns)+1),
+		}
+		newRow.Columns = append(newRow.Columns, row.Columns...)
+		newRow.Columns = append(newRow.Columns, &types.DataColumn{
+			ColumnName: typedConfig.NewColumnName,
+			CellValue:  label,
+		})
+		newDataset.Rows[ri] = newRow
+	}
+
+	newDataset.Headers = buildHeaders(newDataset, dataset)
+	if h, ok := newDataset.Headers[typedConfig.NewColumnName]; ok && h.DataType == types.NilType {
+		h.DataType = types.StringType
+	}
+	return newDataset, nil
+}
+
+func (t *ClassifyOperator) buildConfiguration(config string) (*ClassifyConfiguration, error) {
+	if len(config) < 1 {
+		return nil, errors.New("invalid configuration")
+	}
+	// config is a json declaration of our field configuration
+	typedConfig := ClassifyConfiguration{}
+	err := json.Unmarshal([]byte(config), &typedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(typedConfig.NewColumnName) < 1 {
+		return nil, errors.New("missing new column name in Classify configuration")
+	}
+	if len(typedConfig.Rules) < 1 {
+		return nil, errors.New("missing rules in Classify configuration")
+	}
+	for i, rule := range typedConfig.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule %d is empty in Classify configuration", i+1)
+		}
+		kinds := 0
+		for _, set := range []bool{rule.isRange(), len(rule.Pattern) > 0, len(rule.Prefix) > 0, rule.Criteria != nil} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return nil, fmt.Errorf("rule “%s” needs exactly one of a range, a pattern, a prefix or criteria", rule.Label)
+		}
+		if rule.Criteria == nil && len(typedConfig.ColumnName) < 1 {
+			return nil, fmt.Errorf("rule “%s” needs the column name in Classify configuration", rule.Label)
+		}
+		if len(rule.Pattern) > 0 {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern “%s”: %s", rule.Pattern, err.Error())
+			}
+		}
+	}
+
+	return &typedConfig, nil
+}
+
+func (t *ClassifyOperator) ValidateConfiguration(config string) (bool, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	return typedConfig != nil, err
+}
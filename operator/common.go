@@ -96,10 +96,17 @@ func removeAndAssignRowKey(resultDataSet *types.DataSet) *types.DataSet {
 }
 
 func executeSQLQuery(q string, dataset *types.DataSet, existingColumnTypeMap map[string]types.CellDataType) (*types.DataSet, error) {
+	return executeSQLQueryOnTables(q, map[string]*types.DataSet{defaultTableName: dataset}, existingColumnTypeMap)
+}
+
+// executeSQLQueryOnTables registers every dataset as a table of the same in memory source so queries can join them
+func executeSQLQueryOnTables(q string, tables map[string]*types.DataSet, existingColumnTypeMap map[string]types.CellDataType) (*types.DataSet, error) {
 	exit := make(chan bool)
 	inMemoryDataSource := lmnqlbridge.NewLmnInMemDataSource(exit)
 
-	inMemoryDataSource.AddTable(defaultTableName, dataset)
+	for name, dataset := range tables {
+		inMemoryDataSource.AddTable(name, dataset)
+	}
 	// Suffix with a process-global sequence so the name is unique even on a random
 	// collision; concurrent calls share the global schema registry and a reused
 	// name causes cross-call SchemaDrop interference (empty query results).
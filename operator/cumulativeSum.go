@@ -3,16 +3,156 @@ package operator
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+
 	_ "github.com/araddon/qlbridge/qlbdriver"
 	"github.com/liminaab/filtrify/types"
 )
 
+const (
+	CumulativeSumAggregation     = "sum"
+	CumulativeProductAggregation = "product"
+	CumulativeMinAggregation     = "min"
+	CumulativeMaxAggregation     = "max"
+)
+
+const (
+	// rows without a value get the total so far
+	CumulativeSkipNulls = "skip"
+	// rows without a value get nil and the total starts over after them
+	CumulativeResetOnNull = "reset"
+)
+
 type CumulativeSumOperator struct {
 }
 
+// CumulativeSumConfiguration keeps one running total per partition.
+// Without OrderBy the totals follow the row order of the dataset, the rows keep their order either way.
+// OutputType defaults to LongType for Int and Long columns and to DoubleType for everything else
 type CumulativeSumConfiguration struct {
-	Column        string `json:"column"`
-	NewColumnName string `json:"newColumnName"`
+	Column        string                `json:"column"`
+	NewColumnName string                `json:"newColumnName"`
+	PartitionBy   []string              `json:"partitionBy"`
+	OrderBy       []*OrderConfiguration `json:"orderBy"`
+	Aggregation   string                `json:"aggregation"`
+	OutputType    *types.CellDataType   `json:"outputType"`
+	NullHandling  string                `json:"nullHandling"`
+}
+
+var errCumulativeOverflow = errors.New("overflow")
+
+// runningAggregate folds numeric values one by one. Integers are folded as integers
+// until the first fractional value so large totals stay exact
+type runningAggregate struct {
+	aggregation string
+	value       float64
+	whole       int64
+	integer     bool
+	started     bool
+}
+
+func (r *runningAggregate) add(v *types.CellValue) error {
+	var w int64
+	isWhole := true
+	switch v.DataType {
+	case types.IntType:
+		w = int64(v.IntValue)
+	case types.LongType:
+		w = v.LongValue
+	default:
+		isWhole = false
+	}
+	if !r.started {
+		r.started = true
+		r.integer = isWhole
+		r.whole = w
+		r.value = v.GetNumericVal()
+		return nil
+	}
+	if r.integer && !isWhole {
+		r.integer = false
+		r.value = float64(r.whole)
+	}
+	if !r.integer {
+		r.value = r.fold(r.value, v.GetNumericVal())
+		return nil
+	}
+
+	switch r.aggregation {
+	case CumulativeProductAggregation:
+		p := r.whole * w
+		if r.whole != 0 && (p/r.whole != w || (r.whole == -1 && w == math.MinInt64) || (w == -1 && r.whole == math.MinInt64)) {
+			return errCumulativeOverflow
+		}
+		r.whole = p
+	case CumulativeMinAggregation:
+		if w < r.whole {
+			r.whole = w
+		}
+	case CumulativeMaxAggregation:
+		if w > r.whole {
+			r.whole = w
+		}
+	default:
+		s := r.whole + w
+		if (w > 0 && s < r.whole) || (w < 0 && s > r.whole) {
+			return errCumulativeOverflow
+		}
+		r.whole = s
+	}
+	return nil
+}
+
+func (r *runningAggregate) fold(total, v float64) float64 {
+	switch r.aggregation {
+	case CumulativeProductAggregation:
+		return total * v
+	case CumulativeMinAggregation:
+		return math.Min(total, v)
+	case CumulativeMaxAggregation:
+		return math.Max(total, v)
+	}
+	return total + v
+}
+
+func (r *runningAggregate) reset() {
+	r.value = 0
+	r.whole = 0
+	r.integer = false
+	r.started = false
+}
+
+func (r *runningAggregate) cell(outputType types.CellDataType) (*types.CellValue, error) {
+	if !r.started {
+		// an empty sum is still 0 but there is no product, min or max of nothing
+		if r.aggregation != CumulativeSumAggregation {
+			return &types.CellValue{DataType: types.NilType}, nil
+		}
+	}
+	if outputType == types.DoubleType {
+		if r.integer {
+			return &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(r.whole)}, nil
+		}
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: r.value}, nil
+	}
+
+	whole := r.whole
+	if !r.integer && r.started {
+		rounded := math.Round(r.value)
+		// 2^63 itself is out of range
+		if math.IsNaN(rounded) || rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+			return nil, errCumulativeOverflow
+		}
+		whole = int64(rounded)
+	}
+	if outputType == types.IntType {
+		if whole < math.MinInt32 || whole > math.MaxInt32 {
+			return nil, errCumulativeOverflow
+		}
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(whole)}, nil
+	}
+	return &types.CellValue{DataType: types.LongType, LongValue: whole}, nil
 }
 
 func (t *CumulativeSumOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
@@ -22,32 +162,65 @@ func (t *CumulativeSumOperator) Transform(dataset *types.DataSet, config string,
 		return nil, err
 	}
 
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	if _, ok := columnTypeMap[typedConfig.Column]; !ok {
+		return nil, buildColumnNotExistsError(typedConfig.Column)
+	}
+	for _, col := range typedConfig.PartitionBy {
+		if _, ok := columnTypeMap[col]; !ok {
+			return nil, buildColumnNotExistsError(col)
+		}
+	}
+	for _, o := range typedConfig.OrderBy {
+		if _, ok := columnTypeMap[o.ColumnName]; !ok {
+			return nil, buildColumnNotExistsError(o.ColumnName)
+		}
+	}
+
+	outputType := types.DoubleType
+	if typedConfig.OutputType != nil {
+		outputType = *typedConfig.OutputType
+	} else if columnTypeMap[typedConfig.Column] == types.IntType || columnTypeMap[typedConfig.Column] == types.LongType {
+		outputType = types.LongType
+	}
+
+	partitions, err := buildOrderedPartitions(dataset.Rows, typedConfig.PartitionBy, typedConfig.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*types.CellValue, len(dataset.Rows))
+	for _, partition := range partitions {
+		total := &runningAggregate{aggregation: typedConfig.Aggregation}
+		for _, ri := range partition {
+			col := dataset.Rows[ri].GetColumn(typedConfig.Column)
+			if col == nil || !col.CellValue.IsNumeric() {
+				if typedConfig.NullHandling == CumulativeResetOnNull {
+					total.reset()
+					results[ri] = &types.CellValue{DataType: types.NilType}
+					continue
+				}
+			} else if err := total.add(col.CellValue); err != nil {
+				return nil, t.buildOverflowError(typedConfig, outputType)
+			}
+			if results[ri], err = total.cell(outputType); err != nil {
+				return nil, t.buildOverflowError(typedConfig, outputType)
+			}
+		}
+	}
+
 	newDataset := &types.DataSet{
 		Rows: make([]*types.DataRow, len(dataset.Rows)),
 	}
 
-	var cumulativeSum float64 = 0
 	for i, row := range dataset.Rows {
 		newRow := types.DataRow{
 			Key:     row.Key,
-			Columns: make([]*types.DataColumn, 0),
-		}
-		for _, col := range row.Columns {
-			if col.ColumnName == typedConfig.Column {
-				if !col.CellValue.IsNumeric() {
-					newRow.Columns = append(newRow.Columns, col)
-					continue
-				}
-				cumulativeSum += col.CellValue.GetNumericVal()
-			}
-			newRow.Columns = append(newRow.Columns, col)
+			Columns: make([]*types.DataColumn, 0, len(row.Columns)+1),
 		}
+		newRow.Columns = append(newRow.Columns, row.Columns...)
 		newRow.Columns = append(newRow.Columns, &types.DataColumn{
 			ColumnName: typedConfig.NewColumnName,
-			CellValue: &types.CellValue{
-				DataType:    types.DoubleType,
-				DoubleValue: cumulativeSum,
-			},
+			CellValue:  results[i],
 		})
 		newDataset.Rows[i] = &newRow
 	}
@@ -56,6 +229,13 @@ func (t *CumulativeSumOperator) Transform(dataset *types.DataSet, config string,
 	return newDataset, nil
 }
 
+func (t *CumulativeSumOperator) buildOverflowError(typedConfig *CumulativeSumConfiguration, outputType types.CellDataType) error {
+	if outputType == types.IntType {
+		return fmt.Errorf("cumulative %s of “%s” doesn't fit in an integer - use a long or a double output", typedConfig.Aggregation, typedConfig.Column)
+	}
+	return fmt.Errorf("cumulative %s of “%s” doesn't fit in a long - use a double output", typedConfig.Aggregation, typedConfig.Column)
+}
+
 func (t *CumulativeSumOperator) buildConfiguration(config string) (*CumulativeSumConfiguration, error) {
 	if len(config) < 1 {
 		return nil, errors.New("invalid configuration")
@@ -73,6 +253,32 @@ func (t *CumulativeSumOperator) buildConfiguration(config string) (*CumulativeSu
 	if len(typedConfig.NewColumnName) < 1 {
 		return nil, errors.New("missing new column name in CumulativeSum configuration")
 	}
+	for _, o := range typedConfig.OrderBy {
+		if o == nil || len(o.ColumnName) < 1 {
+			return nil, errors.New("missing order by column name in CumulativeSum configuration")
+		}
+	}
+	switch typedConfig.Aggregation {
+	case "":
+		typedConfig.Aggregation = CumulativeSumAggregation
+	case CumulativeSumAggregation, CumulativeProductAggregation, CumulativeMinAggregation, CumulativeMaxAggregation:
+	default:
+		return nil, fmt.Errorf("unknown aggregation “%s” in CumulativeSum configuration", typedConfig.Aggregation)
+	}
+	switch typedConfig.NullHandling {
+	case "":
+		typedConfig.NullHandling = CumulativeSkipNulls
+	case CumulativeSkipNulls, CumulativeResetOnNull:
+	default:
+		return nil, fmt.Errorf("unknown null handling “%s” in CumulativeSum configuration", typedConfig.NullHandling)
+	}
+	if typedConfig.OutputType != nil {
+		switch *typedConfig.OutputType {
+		case types.IntType, types.LongType, types.DoubleType:
+		default:
+			return nil, fmt.Errorf("cumulative values can't be %s - use an integer or a double", typedConfig.OutputType.String())
+		}
+	}
 
 	return &typedConfig, nil
 }
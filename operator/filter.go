@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -25,10 +27,12 @@ type FilterCriteria struct {
 	Criteria        *Criteria         `json:"criteria"`
 }
 
+// Criteria compares the field with Value - or with Values for IN, NOT IN and the BETWEEN operators
 type Criteria struct {
-	FieldName string `json:"field"`
-	Operator  string `json:"operator"`
-	Value     string `json:"value"`
+	FieldName string   `json:"field"`
+	Operator  string   `json:"operator"`
+	Value     string   `json:"value"`
+	Values    []string `json:"values"`
 }
 
 func parsePercentage(data string) (float64, error) {
@@ -154,6 +158,131 @@ func (t *FilterOperator) buildContainsQuery(c *Criteria, colType types.CellDataT
 	}
 }
 
+// quoteFilterText returns the text as a string of the query. qlbridge drops every backslash and double quote
+// of a string with an escaped quote and a backslash before the closing quote escapes it,
+// so texts with quotes or backslashes are url encoded and decoded by the query
+func quoteFilterText(text string) string {
+	if !strings.ContainsAny(text, `'\`) {
+		return "'" + text + "'"
+	}
+	return "urldecode('" + url.QueryEscape(text) + "')"
+}
+
+// likePattern turns a LIKE pattern into a regular expression - % matches any text and _ any character
+func likePattern(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^(?s)")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func (t *FilterOperator) buildTextQuery(c *Criteria, colType types.CellDataType) (string, error) {
+	if colType != types.StringType {
+		return "", errors.New("invalid comparison on filter query")
+	}
+	switch c.Operator {
+	case "STARTS WITH":
+		return fmt.Sprintf("hasprefix(`%s`, %s)", c.FieldName, quoteFilterText(c.Value)), nil
+	case "ENDS WITH":
+		return fmt.Sprintf("hassuffix(`%s`, %s)", c.FieldName, quoteFilterText(c.Value)), nil
+	case "EQUALS IGNORE CASE":
+		return fmt.Sprintf("tolower(`%s`) = %s", c.FieldName, quoteFilterText(strings.ToLower(c.Value))), nil
+	case "CONTAINS IGNORE CASE":
+		return fmt.Sprintf("tolower(`%s`) CONTAINS %s", c.FieldName, quoteFilterText(strings.ToLower(c.Value))), nil
+	case "LIKE":
+		return fmt.Sprintf("regexmatch(`%s`, %s)", c.FieldName, quoteFilterText(likePattern(c.Value))), nil
+	default:
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return "", fmt.Errorf("invalid regular expression “%s”: %s", c.Value, err.Error())
+		}
+		return fmt.Sprintf("regexmatch(`%s`, %s)", c.FieldName, quoteFilterText(c.Value)), nil
+	}
+}
+
+// buildListQuery checks the field against a list of values. qlbridge lists can't hold dates, times or booleans,
+// so these are compared one by one - chained with OR for IN and compared with != and chained with AND for NOT IN
+func (t *FilterOperator) buildListQuery(c *Criteria, colType types.CellDataType) (string, error) {
+	if len(c.Values) == 0 {
+		return "", fmt.Errorf("missing values of %s in filter", c.Operator)
+	}
+	values := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		switch colType {
+		case types.IntType, types.LongType:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return "", err
+			}
+			values[i] = strconv.FormatInt(n, 10)
+		case types.DoubleType:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return "", err
+			}
+			values[i] = strconv.FormatFloat(f, 'f', -1, 64)
+		case types.StringType:
+			values[i] = quoteFilterText(v)
+		case types.TimestampType, types.DateType, types.TimeOfDayType, types.BoolType:
+			operator, chainWith := "=", " OR "
+			if c.Operator == "NOT IN" {
+				operator, chainWith = "!=", " AND "
+			}
+			q, err := t.buildEqualsQuery(&Criteria{FieldName: c.FieldName, Operator: operator, Value: v}, colType)
+			if err != nil {
+				return "", err
+			}
+			values[i] = "(" + q + ")"
+			if i == len(c.Values)-1 {
+				return strings.Join(values, chainWith), nil
+			}
+		default:
+			return "", errors.New("unknown column type in where clause")
+		}
+	}
+	query := fmt.Sprintf("`%s` IN (%s)", c.FieldName, strings.Join(values, ", "))
+	if c.Operator == "NOT IN" {
+		return "NOT (" + query + ")", nil
+	}
+	return query, nil
+}
+
+func (t *FilterOperator) buildBetweenQuery(c *Criteria, columnTypeMap map[string]types.CellDataType) (string, error) {
+	if len(c.Values) != 2 {
+		return "", fmt.Errorf("%s needs 2 values in filter", c.Operator)
+	}
+	from, to := ">=", "<="
+	if c.Operator == "BETWEEN EXCLUSIVE" {
+		from, to = ">", "<"
+	}
+	return t.buildWhereClause(&FilterCriteria{
+		NestedCriterias: []*FilterCriteria{
+			{Criteria: &Criteria{FieldName: c.FieldName, Operator: from, Value: c.Values[0]}},
+			{Criteria: &Criteria{FieldName: c.FieldName, Operator: to, Value: c.Values[1]}},
+		},
+		ChainWith: []string{"AND"},
+	}, columnTypeMap)
+}
+
+func (t *FilterOperator) buildBoolQuery(c *Criteria, colType types.CellDataType) (string, error) {
+	if colType != types.BoolType {
+		return "", errors.New("invalid comparison on filter query")
+	}
+	if c.Operator == "IS TRUE" {
+		return fmt.Sprintf("`%s` = true", c.FieldName), nil
+	}
+	return fmt.Sprintf("`%s` = false", c.FieldName), nil
+}
+
 func (t *FilterOperator) buildEmptyQuery(c *Criteria, colType types.CellDataType) (string, error) {
 	return fmt.Sprintf("(`%s` = NULL OR `%s` = '')", c.FieldName, c.FieldName), nil
 }
@@ -213,6 +342,13 @@ func (t *FilterOperator) buildCriteriaText(c *Criteria, columnTypeMap map[string
 	// CONTAINS
 	// NOT CONTAINS
 	// IS EMPTY
+	// IS NOT EMPTY
+	// STARTS WITH, ENDS WITH
+	// EQUALS IGNORE CASE, CONTAINS IGNORE CASE
+	// LIKE, REGEX MATCH
+	// BETWEEN, BETWEEN EXCLUSIVE
+	// IN, NOT IN
+	// IS TRUE, IS FALSE
 
 	// we need to find out criteria's column type to be able to do this comparison
 	colType, exists := columnTypeMap[c.FieldName]
@@ -236,13 +372,25 @@ func (t *FilterOperator) buildCriteriaText(c *Criteria, columnTypeMap map[string
 	case "IS NOT EMPTY":
 		// valid for all
 		return t.buildNotEmptyQuery(c, colType)
+	case "STARTS WITH", "ENDS WITH", "EQUALS IGNORE CASE", "CONTAINS IGNORE CASE", "LIKE", "REGEX MATCH":
+		// valid for string
+		return t.buildTextQuery(c, colType)
+	case "BETWEEN", "BETWEEN EXCLUSIVE":
+		// valid for numerical and timestamp
+		return t.buildBetweenQuery(c, columnTypeMap)
+	case "IN", "NOT IN":
+		// valid for all data types
+		return t.buildListQuery(c, colType)
+	case "IS TRUE", "IS FALSE":
+		// valid for bool
+		return t.buildBoolQuery(c, colType)
 	default:
 		return "", errors.New("unknown comparison operator in filter")
 	}
 }
 
 func (t *FilterOperator) isListComparison(statement *FilterCriteria) bool {
-	if statement.Criteria == nil {
+	if statement.Criteria == nil || t.hasOwnValueQuoting(statement.Criteria) {
 		return false
 	}
 	val := statement.Criteria.Value
@@ -255,6 +403,15 @@ func (t *FilterOperator) isListComparison(statement *FilterCriteria) bool {
 	return false
 }
 
+// hasOwnValueQuoting tells whether the operator quotes its value itself - its value is never a "(a,b)" list
+func (t *FilterOperator) hasOwnValueQuoting(c *Criteria) bool {
+	switch c.Operator {
+	case "STARTS WITH", "ENDS WITH", "EQUALS IGNORE CASE", "CONTAINS IGNORE CASE", "LIKE", "REGEX MATCH", "IN", "NOT IN":
+		return true
+	}
+	return false
+}
+
 func (t *FilterOperator) compileListComparisonStatements(statement *FilterCriteria) *FilterCriteria {
 	newStatement := &FilterCriteria{
 		Criteria: nil,
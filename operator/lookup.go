@@ -4,25 +4,63 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	_ "github.com/araddon/qlbridge/qlbdriver"
 	"github.com/liminaab/filtrify/types"
 )
 
+const (
+	LookupInnerJoin = "inner"
+	LookupLeftJoin  = "left"
+	LookupRightJoin = "right"
+	LookupFullJoin  = "full"
+	LookupSemiJoin  = "semi"
+	LookupAntiJoin  = "anti"
+)
+
+const (
+	LookupRenameOnCollision = "rename"
+	LookupErrorOnCollision  = "error"
+)
+
+const (
+	LookupFirstMatch             = "first"
+	LookupLastMatch              = "last"
+	LookupAllMatches             = "all"
+	LookupErrorOnMultipleMatches = "error"
+)
+
 type LookupOperator struct {
 }
 
+// JoinColumn matches the Left column with the Right column in the given Mode - exact by default.
+// numericTolerance matches numbers at most Tolerance apart. asOf matches the latest right rows whose
+// value is on or before the left value. between matches when the left value is between Right and RightEnd,
+// an empty bound is open
 type JoinColumn struct {
-	Left  string `json:"left"`
-	Right string `json:"right"`
+	Left      string  `json:"left"`
+	Right     string  `json:"right"`
+	RightEnd  string  `json:"rightEnd"`
+	Mode      string  `json:"mode"`
+	Tolerance float64 `json:"tolerance"`
 }
 
+// LookupFilter is a filter configuration as json - use RightFilters instead
 type LookupFilter struct {
 	Value  string `json:"value"`
 	Filter string `json:"filter"`
 }
 
+// PostJoinFilter compares the Left column of the left row with the Right column of a matching right row.
+// Operator is one of <, <=, >, >=, = and !=. Empty values never pass
+type PostJoinFilter struct {
+	Left     string `json:"left"`
+	Operator string `json:"operator"`
+	Right    string `json:"right"`
+}
+
 type LookupConfiguration struct {
 	TargetDataset            string                  `json:"targetDataset"`
 	Columns                  []*JoinColumn           `json:"columns"`
@@ -30,6 +68,32 @@ type LookupConfiguration struct {
 	RemoveRightDatasetPrefix bool                    `json:"removeRightDatasetPrefix"`
 	SelectedColumns          []string                `json:"selectedColumns"`
 	TargetDatasetFilters     map[string]LookupFilter `json:"targetDatasetFilters"`
+	// RightFilters are applied to the target dataset in the given order before the join,
+	// after TargetDatasetFilters which are applied in the order of their names
+	RightFilters []*FilterCriteria `json:"rightFilters"`
+	// PostJoinFilters drop the matches failing any of them - before MultiMatch picks from the rest,
+	// so a left row whose matches are all dropped is unmatched
+	PostJoinFilters []*PostJoinFilter `json:"postJoinFilters"`
+	// JoinType is left by default. Semi and anti joins keep the left rows with and without a match
+	// and don't add any columns. Right and full joins add the right rows no left row matched at the end
+	JoinType string `json:"joinType"`
+	// MultiMatch decides what happens when a left row matches more than one right row - first by default,
+	// last, all (one row per match) or error. Semi and anti joins ignore it
+	MultiMatch string `json:"multiMatch"`
+	// right columns are named by RightColumnNames, or get RightColumnPrefix (the target dataset name
+	// with a dot by default) and RightColumnSuffix. When a name is taken they get _1, _2... at the end
+	// unless OnCollision is error
+	RightColumnPrefix string            `json:"rightColumnPrefix"`
+	RightColumnSuffix string            `json:"rightColumnSuffix"`
+	RightColumnNames  map[string]string `json:"rightColumnNames"`
+	OnCollision       string            `json:"onCollision"`
+	// the next steps of a transformation get the statistics and the unmatched keys as other datasets
+	// with these names - see TransformWithSideSets
+	StatisticsDataset string `json:"statisticsDataset"`
+	UnmatchedDataset  string `json:"unmatchedDataset"`
+	// the lookup fails when more left rows than these percentages are unmatched or match more than once
+	MaxUnmatchedPercent    *float64 `json:"maxUnmatchedPercent"`
+	MaxMultiMatchedPercent *float64 `json:"maxMultiMatchedPercent"`
 }
 
 func (t *LookupOperator) GetColumn(r *types.DataRow, col string) *types.DataColumn {
@@ -54,222 +118,264 @@ func (t *LookupOperator) createColIndex(ds *types.DataSet) map[*types.DataRow]ma
 	return index
 }
 
-func (t *LookupOperator) copyColumn(orgDataset *types.DataSet, col *types.DataColumn, config *LookupConfiguration) *types.DataColumn {
-
-	cellVal := &types.CellValue{
-		DataType: col.CellValue.DataType,
-	}
-	switch cellVal.DataType {
-	case types.IntType:
-		cellVal.IntValue = col.CellValue.IntValue
-		break
-	case types.LongType:
-		cellVal.LongValue = col.CellValue.LongValue
-		break
-	case types.TimestampType, types.DateType, types.TimeOfDayType:
-		cellVal.TimestampValue = col.CellValue.TimestampValue
-		break
-	case types.StringType:
-		cellVal.StringValue = col.CellValue.StringValue
-		break
-	case types.DoubleType:
-		cellVal.DoubleValue = col.CellValue.DoubleValue
-		break
-	case types.BoolType:
-		cellVal.BoolValue = col.CellValue.BoolValue
-		break
-	}
-
-	newCol := &types.DataColumn{
-		ColumnName: t.getRightColumnName(orgDataset, col, config),
-		CellValue:  cellVal,
-	}
-
-	return newCol
+// lookupOutputColumn is a right column added to the joined rows
+type lookupOutputColumn struct {
+	right string
+	name  string
+}
+
+func copyCell(cell *types.CellValue) *types.CellValue {
+	if cell == nil {
+		return &types.CellValue{DataType: types.NilType}
+	}
+	v := *cell
+	return &v
 }
 
-func (t *LookupOperator) isRightMatchColumn(col *types.DataColumn, config *LookupConfiguration) bool {
+func (t *LookupOperator) isRightMatchColumn(column string, config *LookupConfiguration) bool {
 	for _, c := range config.Columns {
-		if col.ColumnName == c.Right {
+		if column == c.Right {
 			return true
 		}
 	}
 	return false
 }
 
-func (t *LookupOperator) getRightColumnName(orgDataset *types.DataSet, col *types.DataColumn, config *LookupConfiguration) string {
-	var columnName string
-	if config.RemoveRightDatasetPrefix {
-		columnName = col.ColumnName
-	} else {
-		columnName = fmt.Sprintf("%s.%s", config.TargetDataset, col.ColumnName)
-	}
-	counter := 1
-	for {
-		exists := false
-		// let's check if this name already exists
-		for _, val := range orgDataset.Headers {
-			if val.ColumnName == columnName {
-				exists = true
-				break
-			}
-		}
-		if exists {
-			columnName = fmt.Sprintf("%s_%d", columnName, counter)
-			counter++
-		} else {
-			break
-		}
+// rightColumnName names a right column without looking at the other columns
+func (t *LookupOperator) rightColumnName(column string, config *LookupConfiguration) string {
+	if name, ok := config.RightColumnNames[column]; ok {
+		return name
 	}
-	return columnName
+	prefix := fmt.Sprintf("%s.", config.TargetDataset)
+	if len(config.RightColumnPrefix) > 0 {
+		prefix = config.RightColumnPrefix
+	} else if config.RemoveRightDatasetPrefix {
+		prefix = ""
+	}
+	return prefix + column + config.RightColumnSuffix
 }
 
-func (t *LookupOperator) mergeRows(orgDataset *types.DataSet, left *types.DataRow, right *types.DataRow, config *LookupConfiguration) *types.DataRow {
-	targetLength := len(left.Columns) + len(right.Columns)
-	if config.RemoveRightMatchColumn {
-		targetLength -= len(config.Columns)
-	}
+// outputColumns returns the right columns of the joined rows with their names -
+// the selected columns in their order or every right column in the order of the right dataset
+func (t *LookupOperator) outputColumns(left *types.DataSet, rightTemplate *types.DataRow, config *LookupConfiguration) ([]*lookupOutputColumn, error) {
+	rightColumns := make([]string, 0, len(rightTemplate.Columns))
 	if len(config.SelectedColumns) > 0 {
-		targetLength = len(left.Columns) + len(config.SelectedColumns)
+		for _, sc := range config.SelectedColumns {
+			if t.GetColumn(rightTemplate, sc) == nil {
+				return nil, buildColumnNotExistsError(sc)
+			}
+			rightColumns = append(rightColumns, sc)
+		}
+	} else {
+		for _, c := range rightTemplate.Columns {
+			rightColumns = append(rightColumns, c.ColumnName)
+		}
 	}
-	newRow := &types.DataRow{
-		Key:     left.Key,
-		Columns: make([]*types.DataColumn, targetLength),
+
+	taken := make(map[string]bool)
+	for name := range left.Headers {
+		taken[name] = true
 	}
-	for i, c := range left.Columns {
-		newRow.Columns[i] = c
+	for _, name := range t.leftColumnNames(left) {
+		taken[name] = true
 	}
-	colCounter := 0
-	for _, c := range right.Columns {
-		if config.RemoveRightMatchColumn && t.isRightMatchColumn(c, config) {
-			// let's skip this
+	outputs := make([]*lookupOutputColumn, 0, len(rightColumns))
+	for _, column := range rightColumns {
+		if config.RemoveRightMatchColumn && t.isRightMatchColumn(column, config) {
 			continue
 		}
-		if len(config.SelectedColumns) > 0 {
-			// let's check if this column is selected
-			found := false
-			for _, sc := range config.SelectedColumns {
-				if strings.EqualFold(sc, c.ColumnName) {
-					found = true
-					break
-				}
+		name := t.rightColumnName(column, config)
+		if taken[name] {
+			if config.OnCollision == LookupErrorOnCollision {
+				return nil, fmt.Errorf("column “%s” of “%s” can't be added - column “%s” already exists", column, config.TargetDataset, name)
 			}
-			if !found {
-				continue
+			counter := 1
+			for taken[fmt.Sprintf("%s_%d", name, counter)] {
+				counter++
 			}
+			name = fmt.Sprintf("%s_%d", name, counter)
 		}
-		newRow.Columns[len(left.Columns)+colCounter] = t.copyColumn(orgDataset, c, config)
-		colCounter++
+		taken[name] = true
+		outputs = append(outputs, &lookupOutputColumn{right: column, name: name})
 	}
+	return outputs, nil
+}
 
-	// if the configuration is invalid - some columns might be nil
-	// let's fill them with nil columns
-	for i := range newRow.Columns {
-		if newRow.Columns[i] == nil {
-			newRow.Columns[i] = &types.DataColumn{
-				ColumnName: fmt.Sprintf("column_%d", i),
-				CellValue:  &types.CellValue{DataType: types.NilType},
-			}
+func (t *LookupOperator) mergeRows(left *types.DataRow, rightColumns map[string]*types.DataColumn, outputs []*lookupOutputColumn) *types.DataRow {
+	newRow := &types.DataRow{
+		Key:     left.Key,
+		Columns: make([]*types.DataColumn, len(left.Columns), len(left.Columns)+len(outputs)),
+	}
+	copy(newRow.Columns, left.Columns)
+	for _, o := range outputs {
+		var cell *types.CellValue
+		if c := rightColumns[o.right]; c != nil {
+			cell = c.CellValue
 		}
+		newRow.Columns = append(newRow.Columns, &types.DataColumn{
+			ColumnName: o.name,
+			CellValue:  copyCell(cell),
+		})
 	}
-
 	return newRow
 }
 
-func (t *LookupOperator) mergeNilRow(orgDataset *types.DataSet, left *types.DataRow, rightTemplate *types.DataRow, config *LookupConfiguration) *types.DataRow {
-	targetLength := len(left.Columns) + len(rightTemplate.Columns)
-	if config.RemoveRightMatchColumn {
-		targetLength -= len(config.Columns)
+func (t *LookupOperator) mergeNilRow(left *types.DataRow, outputs []*lookupOutputColumn) *types.DataRow {
+	return t.mergeRows(left, nil, outputs)
+}
+
+// selectMatches applies the multi match policy
+func (t *LookupOperator) selectMatches(lr *types.DataRow, matches []int, config *LookupConfiguration) ([]int, error) {
+	if len(matches) < 2 {
+		return matches, nil
+	}
+	switch config.MultiMatch {
+	case LookupLastMatch:
+		return matches[len(matches)-1:], nil
+	case LookupAllMatches:
+		return matches, nil
+	case LookupErrorOnMultipleMatches:
+		keys := make([]string, len(config.Columns))
+		for i, jc := range config.Columns {
+			keys[i] = lr.GetColumn(jc.Left).CellValue.ToString()
+		}
+		return nil, fmt.Errorf("lookup key “%s” matches %d rows in “%s”", strings.Join(keys, ", "), len(matches), config.TargetDataset)
 	}
-	if len(config.SelectedColumns) > 0 {
-		targetLength = len(left.Columns) + len(config.SelectedColumns)
+	return matches[:1], nil
+}
+
+// leftColumnNames returns the columns of the left rows - the headers when there aren't any rows
+func (t *LookupOperator) leftColumnNames(left *types.DataSet) []string {
+	names := make([]string, 0)
+	if len(left.Rows) > 0 {
+		for _, c := range left.Rows[0].Columns {
+			names = append(names, c.ColumnName)
+		}
+		return names
 	}
-	newRow := &types.DataRow{
-		Key:     left.Key,
-		Columns: make([]*types.DataColumn, targetLength),
+	for _, h := range left.OrderedHeaders() {
+		names = append(names, h.ColumnName)
 	}
-	for i, c := range left.Columns {
-		newRow.Columns[i] = c
+	return names
+}
+
+// mergeRightOnlyRow builds the row of a right row no left row matched - left columns are nil
+// except the join columns which get the values of the right row
+func (t *LookupOperator) mergeRightOnlyRow(leftColumns []string, rightColumns map[string]*types.DataColumn, outputs []*lookupOutputColumn, config *LookupConfiguration) *types.DataRow {
+	leftRow := &types.DataRow{
+		Columns: make([]*types.DataColumn, len(leftColumns)),
 	}
-	colCounter := 0
-	for _, c := range rightTemplate.Columns {
-		if config.RemoveRightMatchColumn && t.isRightMatchColumn(c, config) {
-			// let's skip this
-			continue
+	for i, name := range leftColumns {
+		leftRow.Columns[i] = &types.DataColumn{
+			ColumnName: name,
+			CellValue:  &types.CellValue{DataType: types.NilType},
 		}
-		if len(config.SelectedColumns) > 0 {
-			// let's check if this column is selected
-			found := false
-			for _, sc := range config.SelectedColumns {
-				if strings.EqualFold(sc, c.ColumnName) {
-					found = true
-					break
+		for _, jc := range config.Columns {
+			if jc.Left == name {
+				if rc := rightColumns[jc.Right]; rc != nil {
+					leftRow.Columns[i].CellValue = copyCell(rc.CellValue)
 				}
-			}
-			if !found {
-				continue
+				break
 			}
 		}
-		newRow.Columns[len(left.Columns)+colCounter] = &types.DataColumn{
-			ColumnName: t.getRightColumnName(orgDataset, c, config),
-			CellValue:  &types.CellValue{DataType: types.NilType},
-		}
-		colCounter++
 	}
-
-	return newRow
+	return t.mergeRows(leftRow, rightColumns, outputs)
 }
 
-func (t *LookupOperator) mergeSets(left *types.DataSet, right *types.DataSet, config *LookupConfiguration) *types.DataSet {
+func (t *LookupOperator) mergeSets(left *types.DataSet, right *types.DataSet, config *LookupConfiguration) (*types.DataSet, *lookupReport, error) {
 	mergedSet := &types.DataSet{
-		Rows: make([]*types.DataRow, len(left.Rows)),
+		Rows: make([]*types.DataRow, 0, len(left.Rows)),
+	}
+	report := &lookupReport{
+		statistics: &LookupStatistics{LeftRows: len(left.Rows), RightRows: len(right.Rows)},
 	}
-	rightIndex := t.createColIndex(right)
-	leftIndex := t.createColIndex(left)
 
 	if len(right.Rows) == 0 {
+		// nothing can match
+		report.statistics.Unmatched = len(left.Rows)
+		report.unmatchedLeft = left.Rows
+		switch config.JoinType {
+		case LookupInnerJoin, LookupRightJoin, LookupSemiJoin:
+			return mergedSet, report, nil
+		}
 		// no data on right - we can't merge it
 		// TODO maybe create empty columns for right?
-		return left
+		return left, report, nil
 	}
+	if len(left.Rows) == 0 && config.JoinType != LookupRightJoin && config.JoinType != LookupFullJoin {
+		// nothing to join - every right row is unused
+		report.statistics.UnusedRight = len(right.Rows)
+		report.unusedRight = right.Rows
+		return left, report, nil
+	}
+	rightIndex := t.createColIndex(right)
+	leftIndex := t.createColIndex(left)
 
-	refRow := right.Rows[0]
-	for li, lr := range left.Rows {
-		leftJoinColumns := make([]*types.DataColumn, len(config.Columns))
-		for i, jc := range config.Columns {
-			leftJoinColumns[i] = leftIndex[lr][jc.Left]
+	var outputs []*lookupOutputColumn
+	if config.JoinType != LookupSemiJoin && config.JoinType != LookupAntiJoin {
+		var err error
+		outputs, err = t.outputColumns(left, right.Rows[0], config)
+		if err != nil {
+			return nil, nil, err
 		}
-		var matchRow *types.DataRow = nil
-		for _, rr := range right.Rows {
-			rightJoinColumns := make([]*types.DataColumn, len(config.Columns))
-			for i, jc := range config.Columns {
-				rightJoinColumns[i] = rightIndex[rr][jc.Right]
-			}
-			foundMatch := true
-			// we need to try find if those values are equal?
-			for i := range leftJoinColumns {
-				if !leftJoinColumns[i].CellValue.EqualsAsText(rightJoinColumns[i].CellValue) {
-					foundMatch = false
-				}
+	}
+	index := t.buildLookupIndex(right, rightIndex, config)
+	matchedRight := make([]bool, len(right.Rows))
+	for _, lr := range left.Rows {
+		matches := t.matchingRows(lr, leftIndex, right, rightIndex, index, config)
+		for _, ri := range matches {
+			matchedRight[ri] = true
+		}
+		switch {
+		case len(matches) == 0:
+			report.statistics.Unmatched++
+			report.unmatchedLeft = append(report.unmatchedLeft, lr)
+		case len(matches) > 1:
+			report.statistics.MultiMatched++
+			fallthrough
+		default:
+			report.statistics.Matched++
+		}
+		// semi and anti joins only need to know if there is a match - the multi match policy doesn't apply
+		switch config.JoinType {
+		case LookupSemiJoin:
+			if len(matches) > 0 {
+				mergedSet.Rows = append(mergedSet.Rows, lr)
 			}
-			if foundMatch {
-				// we need to merge these 2 rows
-				matchRow = rr
-				// let's move to next row on left
-				break
+			continue
+		case LookupAntiJoin:
+			if len(matches) == 0 {
+				mergedSet.Rows = append(mergedSet.Rows, lr)
 			}
+			continue
+		}
+
+		selected, err := t.selectMatches(lr, matches, config)
+		if err != nil {
+			return nil, nil, err
 		}
-		var newRow *types.DataRow = nil
-		if matchRow != nil {
-			newRow = t.mergeRows(left, lr, matchRow, config)
-		} else {
+
+		for _, ri := range selected {
+			mergedSet.Rows = append(mergedSet.Rows, t.mergeRows(lr, rightIndex[right.Rows[ri]], outputs))
+		}
+		if len(selected) == 0 && (config.JoinType == LookupLeftJoin || config.JoinType == LookupFullJoin) {
 			// we need to do a nil merge
-			newRow = t.mergeNilRow(left, lr, refRow, config)
+			mergedSet.Rows = append(mergedSet.Rows, t.mergeNilRow(lr, outputs))
 		}
-		mergedSet.Rows[li] = newRow
+	}
 
+	leftColumns := t.leftColumnNames(left)
+	for ri, rr := range right.Rows {
+		if matchedRight[ri] {
+			continue
+		}
+		report.statistics.UnusedRight++
+		report.unusedRight = append(report.unusedRight, rr)
+		if config.JoinType == LookupRightJoin || config.JoinType == LookupFullJoin {
+			mergedSet.Rows = append(mergedSet.Rows, t.mergeRightOnlyRow(leftColumns, rightIndex[rr], outputs, config))
+		}
 	}
-	return mergedSet
+	return mergedSet, report, nil
 }
 
 func (t *LookupOperator) Transform(dataset *types.DataSet, config string, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
@@ -278,53 +384,141 @@ func (t *LookupOperator) Transform(dataset *types.DataSet, config string, otherS
 	if err != nil {
 		return nil, err
 	}
+	mergedSet, _, err := t.TransformWithStatistics(dataset, typedConfig, otherSets)
+	return mergedSet, err
+}
 
-	if _, ok := otherSets[typedConfig.TargetDataset]; !ok {
-		return nil, errors.New("target dataset not found")
+// TransformWithSideSets joins the datasets like Transform and returns the statistics and the unmatched keys
+// by the names of StatisticsDataset and UnmatchedDataset. otherSets is left as it is
+func (t *LookupOperator) TransformWithSideSets(dataset *types.DataSet, config string, otherSets map[string]*types.DataSet) (*types.DataSet, map[string]*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range []string{typedConfig.StatisticsDataset, typedConfig.UnmatchedDataset} {
+		if _, ok := otherSets[name]; ok && len(name) > 0 {
+			return nil, nil, fmt.Errorf("dataset “%s” already exists", name)
+		}
+	}
+	mergedSet, report, err := t.join(dataset, typedConfig, otherSets)
+	if err != nil {
+		return nil, nil, err
 	}
+	sideSets := make(map[string]*types.DataSet)
+	if len(typedConfig.StatisticsDataset) > 0 {
+		sideSets[typedConfig.StatisticsDataset] = report.statistics.toDataSet()
+	}
+	if len(typedConfig.UnmatchedDataset) > 0 {
+		sideSets[typedConfig.UnmatchedDataset] = t.buildUnmatchedSet(report, typedConfig)
+	}
+	return mergedSet, sideSets, nil
+}
 
-	tds := otherSets[typedConfig.TargetDataset]
+// TransformWithStatistics joins the datasets and tells how well they matched
+func (t *LookupOperator) TransformWithStatistics(dataset *types.DataSet, typedConfig *LookupConfiguration, otherSets map[string]*types.DataSet) (*types.DataSet, *LookupStatistics, error) {
+	mergedSet, report, err := t.join(dataset, typedConfig, otherSets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mergedSet, report.statistics, nil
+}
 
-	if len(tds.Rows) < 1 || len(dataset.Rows) < 1 {
-		return dataset, nil
+func (t *LookupOperator) join(dataset *types.DataSet, typedConfig *LookupConfiguration, otherSets map[string]*types.DataSet) (*types.DataSet, *lookupReport, error) {
+	if _, ok := otherSets[typedConfig.TargetDataset]; !ok {
+		return nil, nil, errors.New("target dataset not found")
 	}
 
-	firstTargetRow := tds.Rows[0]
-	// let's check if columns exist
-	for _, col := range typedConfig.Columns {
-		realCol := t.GetColumn(firstTargetRow, col.Right)
-		if realCol == nil {
-			return nil, buildColumnNotExistsError(col.Right)
+	tds := otherSets[typedConfig.TargetDataset]
+
+	if len(tds.Rows) > 0 {
+		firstTargetRow := tds.Rows[0]
+		// let's check if columns exist
+		for _, col := range typedConfig.Columns {
+			realCol := t.GetColumn(firstTargetRow, col.Right)
+			if realCol == nil {
+				return nil, nil, buildColumnNotExistsError(col.Right)
+			}
+			if col.Mode == BetweenMatch && t.GetColumn(firstTargetRow, col.RightEnd) == nil {
+				return nil, nil, buildColumnNotExistsError(col.RightEnd)
+			}
 		}
 	}
 
-	firstOriginalRow := dataset.Rows[0]
+	leftColumns := t.leftColumnNames(dataset)
 	for _, col := range typedConfig.Columns {
-		realCol := t.GetColumn(firstOriginalRow, col.Left)
-		if realCol == nil {
-			return nil, buildColumnNotExistsError(col.Left)
+		if !containsString(leftColumns, col.Left) {
+			return nil, nil, buildColumnNotExistsError(col.Left)
 		}
 	}
 
-	filteredSet := tds
-	if len(typedConfig.TargetDatasetFilters) > 0 {
-		filterOp := &FilterOperator{}
-		// before merging the data - let's do a filter for the target dataset if it exists
-		for _, filter := range typedConfig.TargetDatasetFilters {
-			if len(filter.Filter) == 0 {
-				continue
+	if len(tds.Rows) > 0 {
+		for _, pf := range typedConfig.PostJoinFilters {
+			if !containsString(leftColumns, pf.Left) {
+				return nil, nil, buildColumnNotExistsError(pf.Left)
 			}
-			filteredSet, err = filterOp.Transform(filteredSet, filter.Filter, nil)
-			if err != nil {
-				return nil, err
+			if t.GetColumn(tds.Rows[0], pf.Right) == nil {
+				return nil, nil, buildColumnNotExistsError(pf.Right)
 			}
 		}
 	}
 
+	// before merging the data - let's filter the target dataset
+	filteredSet, err := t.filterRightSet(tds, typedConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// wow we are ready to join those tables
-	mergedSet := t.mergeSets(dataset, filteredSet, typedConfig)
-	mergedSet.Headers = buildHeaders(mergedSet, dataset)
-	return mergedSet, nil
+	mergedSet, report, err := t.mergeSets(dataset, filteredSet, typedConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := t.checkMatchQuality(report.statistics, typedConfig); err != nil {
+		return nil, nil, err
+	}
+	if mergedSet != dataset {
+		mergedSet.Headers = buildHeaders(mergedSet, dataset)
+		// left columns without any value keep their type
+		for name, h := range mergedSet.Headers {
+			if lh := dataset.Headers[name]; lh != nil && h.DataType == types.NilType {
+				h.DataType = lh.DataType
+			}
+		}
+	}
+	return mergedSet, report, nil
+}
+
+func (t *LookupOperator) filterRightSet(right *types.DataSet, config *LookupConfiguration) (*types.DataSet, error) {
+	filterOp := &FilterOperator{}
+	names := make([]string, 0, len(config.TargetDatasetFilters))
+	for name, filter := range config.TargetDatasetFilters {
+		if len(filter.Filter) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	filteredSet := right
+	var err error
+	for _, name := range names {
+		if len(filteredSet.Rows) == 0 {
+			return filteredSet, nil
+		}
+		filteredSet, err = filterOp.Transform(filteredSet, config.TargetDatasetFilters[name].Filter, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, criteria := range config.RightFilters {
+		if len(filteredSet.Rows) == 0 {
+			return filteredSet, nil
+		}
+		filteredSet, err = filterOp.TransformTyped(filteredSet, &FilterConfiguration{FilterCriteria: criteria})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filteredSet, nil
 }
 
 func (t *LookupOperator) buildConfiguration(config string) (*LookupConfiguration, error) {
@@ -346,6 +540,7 @@ func (t *LookupOperator) buildConfiguration(config string) (*LookupConfiguration
 		return nil, errors.New("missing columns in lookup configuration")
 	}
 
+	asOfColumns := 0
 	for _, ob := range typedConfig.Columns {
 		if len(ob.Left) < 1 {
 			return nil, errors.New("missing join left in lookup configuration")
@@ -353,6 +548,84 @@ func (t *LookupOperator) buildConfiguration(config string) (*LookupConfiguration
 		if len(ob.Right) < 1 {
 			return nil, errors.New("missing join right in lookup configuration")
 		}
+		switch ob.Mode {
+		case "":
+			ob.Mode = ExactMatch
+		case ExactMatch, CaseInsensitiveMatch, TrimmedMatch:
+		case NumericToleranceMatch:
+			if ob.Tolerance < 0 {
+				return nil, fmt.Errorf("tolerance of “%s” can't be negative", ob.Left)
+			}
+		case AsOfMatch:
+			asOfColumns++
+		case BetweenMatch:
+			if len(ob.RightEnd) < 1 {
+				return nil, fmt.Errorf("missing join right end of “%s” in lookup configuration", ob.Left)
+			}
+		default:
+			return nil, fmt.Errorf("unknown match mode “%s” in lookup configuration", ob.Mode)
+		}
+	}
+	if asOfColumns > 1 {
+		return nil, errors.New("only one join column can be matched as of in lookup configuration")
+	}
+
+	switch typedConfig.JoinType {
+	case "":
+		typedConfig.JoinType = LookupLeftJoin
+	case LookupInnerJoin, LookupLeftJoin, LookupRightJoin, LookupFullJoin, LookupSemiJoin, LookupAntiJoin:
+	default:
+		return nil, fmt.Errorf("unknown join type “%s” in lookup configuration", typedConfig.JoinType)
+	}
+	for _, limit := range []*float64{typedConfig.MaxUnmatchedPercent, typedConfig.MaxMultiMatchedPercent} {
+		if limit != nil && (*limit < 0 || *limit > 100) {
+			return nil, fmt.Errorf("match quality limit %g%% must be between 0 and 100", *limit)
+		}
+	}
+	for _, name := range []string{typedConfig.StatisticsDataset, typedConfig.UnmatchedDataset} {
+		if name == typedConfig.TargetDataset {
+			return nil, fmt.Errorf("dataset “%s” can't be replaced by the lookup", name)
+		}
+	}
+	if len(typedConfig.StatisticsDataset) > 0 && typedConfig.StatisticsDataset == typedConfig.UnmatchedDataset {
+		return nil, errors.New("statistics and unmatched datasets need different names")
+	}
+	switch typedConfig.OnCollision {
+	case "":
+		typedConfig.OnCollision = LookupRenameOnCollision
+	case LookupRenameOnCollision, LookupErrorOnCollision:
+	default:
+		return nil, fmt.Errorf("unknown collision policy “%s” in lookup configuration", typedConfig.OnCollision)
+	}
+	for right, name := range typedConfig.RightColumnNames {
+		if len(name) < 1 {
+			return nil, fmt.Errorf("missing new name of column “%s” in lookup configuration", right)
+		}
+	}
+	for i, sc := range typedConfig.SelectedColumns {
+		if containsString(typedConfig.SelectedColumns[:i], sc) {
+			return nil, fmt.Errorf("column “%s” is selected more than once", sc)
+		}
+	}
+	for _, rf := range typedConfig.RightFilters {
+		if rf == nil {
+			return nil, errors.New("empty right filter in lookup configuration")
+		}
+	}
+	for _, pf := range typedConfig.PostJoinFilters {
+		if pf == nil || len(pf.Left) < 1 || len(pf.Right) < 1 {
+			return nil, errors.New("missing column of post join filter in lookup configuration")
+		}
+		if !isPostJoinOperator(pf.Operator) {
+			return nil, fmt.Errorf("unknown post join filter operator “%s” in lookup configuration", pf.Operator)
+		}
+	}
+	switch typedConfig.MultiMatch {
+	case "":
+		typedConfig.MultiMatch = LookupFirstMatch
+	case LookupFirstMatch, LookupLastMatch, LookupAllMatches, LookupErrorOnMultipleMatches:
+	default:
+		return nil, fmt.Errorf("unknown multi match policy “%s” in lookup configuration", typedConfig.MultiMatch)
 	}
 
 	return &typedConfig, nil
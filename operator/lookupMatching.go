@@ -0,0 +1,338 @@
+package operator
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+const (
+	ExactMatch            = "exact"
+	CaseInsensitiveMatch  = "caseInsensitive"
+	TrimmedMatch          = "trimmed"
+	NumericToleranceMatch = "numericTolerance"
+	AsOfMatch             = "asOf"
+	BetweenMatch          = "between"
+)
+
+// lookupKeyPrefix marks the instant based keys of time values - ToString never starts with it
+const lookupKeyPrefix = "\x01"
+
+// lookupIndex groups the right rows by their normalised join key so every left row needs one probe.
+// Keys only narrow the candidates down - the matches are still checked column by column.
+// Only exact, caseInsensitive and trimmed columns are part of the key, without them every row is a candidate
+type lookupIndex struct {
+	columns []int
+	buckets map[string][]int
+	all     []int
+}
+
+func isHashedMatch(mode string) bool {
+	return mode == ExactMatch || mode == CaseInsensitiveMatch || mode == TrimmedMatch
+}
+
+// normaliseWhitespace drops the leading and trailing whitespace and turns every other run of it into one space
+func normaliseWhitespace(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// joinKeyParts returns the keys of a cell - equal cells always share at least one of them.
+// Values of different types are equal when their texts are equal so the text is the key,
+// except that time values of the same type are equal as instants whatever their location is
+func joinKeyParts(cell *types.CellValue, mode string) []string {
+	if mode != ExactMatch {
+		if cell == nil || cell.DataType == types.NilType {
+			// nothing matches an empty value
+			return []string{}
+		}
+		if mode == CaseInsensitiveMatch {
+			return []string{strings.ToLower(cell.ToString())}
+		}
+		return []string{normaliseWhitespace(cell.ToString())}
+	}
+	if cell == nil {
+		return []string{""}
+	}
+	switch cell.DataType {
+	case types.TimestampType, types.DateType, types.TimeOfDayType:
+		return []string{cell.ToString(), lookupKeyPrefix + cell.TimestampValue.UTC().Format(time.RFC3339Nano)}
+	case types.DoubleType:
+		if cell.DoubleValue == 0 {
+			// -0 equals 0
+			return []string{"0"}
+		}
+	}
+	return []string{cell.ToString()}
+}
+
+// joinKeys returns every combination of the key parts of the hashed join columns
+func joinKeys(cells []*types.CellValue, config *LookupConfiguration, columns []int) []string {
+	keys := []string{""}
+	for i, ci := range columns {
+		parts := joinKeyParts(cells[ci], config.Columns[ci].Mode)
+		combined := make([]string, 0, len(keys)*len(parts))
+		for _, k := range keys {
+			for _, p := range parts {
+				if i > 0 {
+					combined = append(combined, k+"\x00"+p)
+				} else {
+					combined = append(combined, p)
+				}
+			}
+		}
+		keys = combined
+	}
+	return keys
+}
+
+func (t *LookupOperator) joinCells(row *types.DataRow, index map[*types.DataRow]map[string]*types.DataColumn, columns []string) []*types.CellValue {
+	cells := make([]*types.CellValue, len(columns))
+	for i, col := range columns {
+		if c := index[row][col]; c != nil {
+			cells[i] = c.CellValue
+		}
+	}
+	return cells
+}
+
+func (t *LookupOperator) buildLookupIndex(right *types.DataSet, rightIndex map[*types.DataRow]map[string]*types.DataColumn, config *LookupConfiguration) *lookupIndex {
+	index := &lookupIndex{columns: make([]int, 0)}
+	rightColumns := make([]string, len(config.Columns))
+	for i, jc := range config.Columns {
+		rightColumns[i] = jc.Right
+		if isHashedMatch(jc.Mode) {
+			index.columns = append(index.columns, i)
+		}
+	}
+	if len(index.columns) == 0 {
+		index.all = make([]int, len(right.Rows))
+		for ri := range right.Rows {
+			index.all[ri] = ri
+		}
+		return index
+	}
+
+	index.buckets = make(map[string][]int, len(right.Rows))
+	for ri, rr := range right.Rows {
+		for _, key := range joinKeys(t.joinCells(rr, rightIndex, rightColumns), config, index.columns) {
+			index.buckets[key] = append(index.buckets[key], ri)
+		}
+	}
+	return index
+}
+
+func (index *lookupIndex) candidates(cells []*types.CellValue, config *LookupConfiguration) []int {
+	if index.buckets == nil {
+		return index.all
+	}
+	keys := joinKeys(cells, config, index.columns)
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) == 1 {
+		return index.buckets[keys[0]]
+	}
+	seen := make(map[int]bool)
+	candidates := make([]int, 0)
+	for _, key := range keys {
+		for _, ri := range index.buckets[key] {
+			if !seen[ri] {
+				seen[ri] = true
+				candidates = append(candidates, ri)
+			}
+		}
+	}
+	sort.Ints(candidates)
+	return candidates
+}
+
+// compareJoinValues orders numbers, dates and texts - ok is false when the values can't be compared
+func compareJoinValues(v1 *types.CellValue, v2 *types.CellValue) (result int, ok bool) {
+	if v1 == nil || v2 == nil || v1.DataType == types.NilType || v2.DataType == types.NilType {
+		return 0, false
+	}
+	// dates of text columns are compared as dates
+	if isDateType(v1.DataType) && v2.DataType == types.StringType {
+		if ts := tryParseDateAndTime(v2.StringValue); ts != nil {
+			v2 = &types.CellValue{DataType: v1.DataType, TimestampValue: *ts}
+		}
+	} else if v1.DataType == types.StringType && isDateType(v2.DataType) {
+		if ts := tryParseDateAndTime(v1.StringValue); ts != nil {
+			v1 = &types.CellValue{DataType: v2.DataType, TimestampValue: *ts}
+		}
+	}
+	switch {
+	case v1.IsNumeric() && v2.IsNumeric():
+		n1, n2 := v1.GetNumericVal(), v2.GetNumericVal()
+		if n1 < n2 {
+			return -1, true
+		} else if n1 > n2 {
+			return 1, true
+		}
+		return 0, true
+	case isDateType(v1.DataType) && isDateType(v2.DataType), v1.DataType == types.TimeOfDayType && v2.DataType == types.TimeOfDayType:
+		if v1.TimestampValue.Before(v2.TimestampValue) {
+			return -1, true
+		} else if v1.TimestampValue.After(v2.TimestampValue) {
+			return 1, true
+		}
+		return 0, true
+	case v1.DataType == types.StringType && v2.DataType == types.StringType:
+		return strings.Compare(v1.StringValue, v2.StringValue), true
+	}
+	return 0, false
+}
+
+func isPostJoinOperator(operator string) bool {
+	switch operator {
+	case "<", "<=", ">", ">=", "=", "!=":
+		return true
+	}
+	return false
+}
+
+// postJoinFiltersPass checks the post join filters of a matching pair of rows
+func postJoinFiltersPass(left *types.DataRow, leftIndex map[*types.DataRow]map[string]*types.DataColumn, right *types.DataRow, rightIndex map[*types.DataRow]map[string]*types.DataColumn, filters []*PostJoinFilter) bool {
+	for _, pf := range filters {
+		var leftValue, rightValue *types.CellValue
+		if c := leftIndex[left][pf.Left]; c != nil {
+			leftValue = c.CellValue
+		}
+		if c := rightIndex[right][pf.Right]; c != nil {
+			rightValue = c.CellValue
+		}
+		if leftValue == nil || rightValue == nil || leftValue.DataType == types.NilType || rightValue.DataType == types.NilType {
+			return false
+		}
+		c, ok := compareJoinValues(leftValue, rightValue)
+		if !ok {
+			// values which can't be ordered can still be equal
+			if pf.Operator != "=" && pf.Operator != "!=" {
+				return false
+			}
+			c = 1
+			if leftValue.EqualsAsText(rightValue) {
+				c = 0
+			}
+		}
+		var pass bool
+		switch pf.Operator {
+		case "<":
+			pass = c < 0
+		case "<=":
+			pass = c <= 0
+		case ">":
+			pass = c > 0
+		case ">=":
+			pass = c >= 0
+		case "=":
+			pass = c == 0
+		case "!=":
+			pass = c != 0
+		}
+		if !pass {
+			return false
+		}
+	}
+	return true
+}
+
+// columnMatches checks one join column of a candidate - as of values also have to be the latest, see latestMatches
+func columnMatches(jc *JoinColumn, left *types.CellValue, right *types.DataRow, rightIndex map[*types.DataRow]map[string]*types.DataColumn) bool {
+	var rightValue *types.CellValue
+	if c := rightIndex[right][jc.Right]; c != nil {
+		rightValue = c.CellValue
+	}
+	switch jc.Mode {
+	case CaseInsensitiveMatch, TrimmedMatch:
+		if left == nil || rightValue == nil || left.DataType == types.NilType || rightValue.DataType == types.NilType {
+			return false
+		}
+		if jc.Mode == CaseInsensitiveMatch {
+			return strings.EqualFold(left.ToString(), rightValue.ToString())
+		}
+		return normaliseWhitespace(left.ToString()) == normaliseWhitespace(rightValue.ToString())
+	case NumericToleranceMatch:
+		if left == nil || rightValue == nil || !left.IsNumeric() || !rightValue.IsNumeric() {
+			return false
+		}
+		return math.Abs(left.GetNumericVal()-rightValue.GetNumericVal()) <= jc.Tolerance
+	case AsOfMatch:
+		c, ok := compareJoinValues(rightValue, left)
+		return ok && c <= 0
+	case BetweenMatch:
+		if left == nil || left.DataType == types.NilType {
+			return false
+		}
+		if rightValue != nil && rightValue.DataType != types.NilType {
+			c, ok := compareJoinValues(left, rightValue)
+			if !ok || c < 0 {
+				return false
+			}
+		}
+		if end := rightIndex[right][jc.RightEnd]; end != nil && end.CellValue.DataType != types.NilType {
+			c, ok := compareJoinValues(left, end.CellValue)
+			if !ok || c > 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return left.EqualsAsText(rightValue)
+}
+
+// matchingRows returns the indexes of the right rows matching the left row in their dataset order
+func (t *LookupOperator) matchingRows(lr *types.DataRow, leftIndex map[*types.DataRow]map[string]*types.DataColumn, right *types.DataSet, rightIndex map[*types.DataRow]map[string]*types.DataColumn, index *lookupIndex, config *LookupConfiguration) []int {
+	leftColumns := make([]string, len(config.Columns))
+	for i, jc := range config.Columns {
+		leftColumns[i] = jc.Left
+	}
+	leftJoinCells := t.joinCells(lr, leftIndex, leftColumns)
+	candidates := index.candidates(leftJoinCells, config)
+
+	matches := make([]int, 0, len(candidates))
+	for _, ri := range candidates {
+		rr := right.Rows[ri]
+		foundMatch := true
+		for i, jc := range config.Columns {
+			if !columnMatches(jc, leftJoinCells[i], rr, rightIndex) {
+				foundMatch = false
+				break
+			}
+		}
+		if foundMatch && postJoinFiltersPass(lr, leftIndex, rr, rightIndex, config.PostJoinFilters) {
+			matches = append(matches, ri)
+		}
+	}
+
+	for _, jc := range config.Columns {
+		if jc.Mode == AsOfMatch {
+			matches = t.latestMatches(matches, right, rightIndex, jc)
+		}
+	}
+	return matches
+}
+
+// latestMatches keeps the matches with the latest as of value
+func (t *LookupOperator) latestMatches(matches []int, right *types.DataSet, rightIndex map[*types.DataRow]map[string]*types.DataColumn, jc *JoinColumn) []int {
+	latest := make([]int, 0, 1)
+	var latestValue *types.CellValue
+	for _, ri := range matches {
+		value := rightIndex[right.Rows[ri]][jc.Right].CellValue
+		if latestValue != nil {
+			c, _ := compareJoinValues(value, latestValue)
+			if c < 0 {
+				continue
+			}
+			if c > 0 {
+				latest = latest[:0]
+			}
+		}
+		latest = append(latest, ri)
+		latestValue = value
+	}
+	return latest
+}
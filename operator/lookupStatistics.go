@@ -0,0 +1,128 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+const (
+	lookupSideColumnName  = "Side"
+	lookupRowsColumnName  = "Rows"
+	lookupLeftSide        = "left"
+	lookupRightSide       = "right"
+	lookupStatisticsOrder = "Left rows,Right rows,Matched,Unmatched,Multi matched,Unused right rows"
+)
+
+// LookupStatistics counts the left rows by their matches. Unused right rows are the right rows no left row matched
+type LookupStatistics struct {
+	LeftRows     int `json:"leftRows"`
+	RightRows    int `json:"rightRows"`
+	Matched      int `json:"matched"`
+	Unmatched    int `json:"unmatched"`
+	MultiMatched int `json:"multiMatched"`
+	UnusedRight  int `json:"unusedRight"`
+}
+
+type lookupReport struct {
+	statistics    *LookupStatistics
+	unmatchedLeft []*types.DataRow
+	unusedRight   []*types.DataRow
+}
+
+func (s *LookupStatistics) percentOfLeft(count int) float64 {
+	if s.LeftRows == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(s.LeftRows)
+}
+
+// toDataSet returns the statistics as a dataset with one row
+func (s *LookupStatistics) toDataSet() *types.DataSet {
+	values := []int{s.LeftRows, s.RightRows, s.Matched, s.Unmatched, s.MultiMatched, s.UnusedRight}
+	row := &types.DataRow{
+		Columns: make([]*types.DataColumn, len(values)),
+	}
+	for i, name := range strings.Split(lookupStatisticsOrder, ",") {
+		row.Columns[i] = &types.DataColumn{
+			ColumnName: name,
+			CellValue:  &types.CellValue{DataType: types.LongType, LongValue: int64(values[i])},
+		}
+	}
+	set := &types.DataSet{Rows: []*types.DataRow{row}}
+	set.Headers = buildHeaders(set, set)
+	return set
+}
+
+func (t *LookupOperator) checkMatchQuality(statistics *LookupStatistics, config *LookupConfiguration) error {
+	if config.MaxUnmatchedPercent != nil {
+		if p := statistics.percentOfLeft(statistics.Unmatched); p > *config.MaxUnmatchedPercent {
+			return fmt.Errorf("%.1f%% of the rows don't match “%s” - at most %g%% may be unmatched", p, config.TargetDataset, *config.MaxUnmatchedPercent)
+		}
+	}
+	if config.MaxMultiMatchedPercent != nil {
+		if p := statistics.percentOfLeft(statistics.MultiMatched); p > *config.MaxMultiMatchedPercent {
+			return fmt.Errorf("%.1f%% of the rows match more than one row of “%s” - at most %g%% may", p, config.TargetDataset, *config.MaxMultiMatchedPercent)
+		}
+	}
+	return nil
+}
+
+// buildUnmatchedSet lists the distinct join keys of the unmatched left rows and then of the unused right rows.
+// Key columns are named like the left join columns and Rows counts the rows with the key
+func (t *LookupOperator) buildUnmatchedSet(report *lookupReport, config *LookupConfiguration) *types.DataSet {
+	set := &types.DataSet{Rows: make([]*types.DataRow, 0)}
+	rowIndex := make(map[string]*types.DataRow)
+	addKey := func(side string, row *types.DataRow, columns []string) {
+		cells := make([]*types.CellValue, len(columns))
+		var sb strings.Builder
+		sb.WriteString(side)
+		for i, col := range columns {
+			if c := row.GetColumn(col); c != nil {
+				cells[i] = c.CellValue
+			}
+			sb.WriteByte(0)
+			sb.WriteString(cells[i].ToString())
+		}
+		key := sb.String()
+		if existing, ok := rowIndex[key]; ok {
+			existing.Columns[len(existing.Columns)-1].CellValue.LongValue++
+			return
+		}
+		newRow := &types.DataRow{
+			Columns: make([]*types.DataColumn, 0, len(columns)+2),
+		}
+		newRow.Columns = append(newRow.Columns, &types.DataColumn{
+			ColumnName: lookupSideColumnName,
+			CellValue:  &types.CellValue{DataType: types.StringType, StringValue: side},
+		})
+		for i, jc := range config.Columns {
+			newRow.Columns = append(newRow.Columns, &types.DataColumn{
+				ColumnName: jc.Left,
+				CellValue:  copyCell(cells[i]),
+			})
+		}
+		newRow.Columns = append(newRow.Columns, &types.DataColumn{
+			ColumnName: lookupRowsColumnName,
+			CellValue:  &types.CellValue{DataType: types.LongType, LongValue: 1},
+		})
+		rowIndex[key] = newRow
+		set.Rows = append(set.Rows, newRow)
+	}
+
+	leftColumns := make([]string, len(config.Columns))
+	rightColumns := make([]string, len(config.Columns))
+	for i, jc := range config.Columns {
+		leftColumns[i] = jc.Left
+		rightColumns[i] = jc.Right
+	}
+	for _, r := range report.unmatchedLeft {
+		addKey(lookupLeftSide, r, leftColumns)
+	}
+	for _, r := range report.unusedRight {
+		addKey(lookupRightSide, r, rightColumns)
+	}
+	set.Headers = buildHeaders(set, set)
+	return set
+}
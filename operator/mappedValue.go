@@ -3,101 +3,227 @@ package operator
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	_ "github.com/araddon/qlbridge/qlbdriver"
 	"github.com/liminaab/filtrify/conversion"
 	"github.com/liminaab/filtrify/types"
 )
 
+const (
+	defaultMapKeyColumn   = "Key"
+	defaultMapValueColumn = "Value"
+)
+
 type MappedValueOperator struct {
 }
 
+// MappedValueConfiguration maps the values of MappedColumnName with the Key and Value columns of the map table.
+// The map table is TargetDataset or TargetData, whose columns are named by TargetDataHeaders (Key and Value by default).
+// The key columns of TargetData are always text so values like 007 keep their zeros.
+// Composite keys match MappedColumnNames with KeyColumns in order, and Outputs add a column for each
+// value column - instead of MappedColumnName and NewColumnName. When a key is in the map table more than once
+// the first row wins
 type MappedValueConfiguration struct {
-	MappedColumnName string     `json:"mappedColumnName"`
-	NewColumnName    string     `json:"newColumnName"`
-	TargetDataset    string     `json:"targetDataset"`
-	TargetData       [][]string `json:"targetData"`
+	MappedColumnName  string               `json:"mappedColumnName"`
+	NewColumnName     string               `json:"newColumnName"`
+	TargetDataset     string               `json:"targetDataset"`
+	TargetData        [][]string           `json:"targetData"`
+	TargetDataHeaders []string             `json:"targetDataHeaders"`
+	MappedColumnNames []string             `json:"mappedColumnNames"`
+	KeyColumns        []string             `json:"keyColumns"`
+	Outputs           []*MappedValueOutput `json:"outputs"`
+	// DefaultValue, KeepUnmappedValue and DataType of NewColumnName - see MappedValueOutput
+	DefaultValue      *string             `json:"defaultValue"`
+	KeepUnmappedValue bool                `json:"keepUnmappedValue"`
+	DataType          *types.CellDataType `json:"dataType"`
 }
 
-func (t *MappedValueOperator) Transform(dataset *types.DataSet, config string, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+// MappedValueOutput adds the ValueColumn of the map table as NewColumnName.
+// Values are converted to DataType, the type of the value column by default.
+// Unmapped rows get DefaultValue, or the value of the mapped column with KeepUnmappedValue - otherwise they are empty.
+// With KeepUnmappedValue the output must be text or have the type of the mapped column
+type MappedValueOutput struct {
+	ValueColumn       string              `json:"valueColumn"`
+	NewColumnName     string              `json:"newColumnName"`
+	DefaultValue      *string             `json:"defaultValue"`
+	KeepUnmappedValue bool                `json:"keepUnmappedValue"`
+	DataType          *types.CellDataType `json:"dataType"`
+}
 
-	typedConfig, err := t.buildConfiguration(config)
+// convertMappedCell converts the cell to the given type through its text
+func convertMappedCell(cell *types.CellValue, dataType types.CellDataType) (*types.CellValue, error) {
+	if cell == nil || cell.DataType == types.NilType {
+		return &types.CellValue{DataType: types.NilType}, nil
+	}
+	if cell.DataType == dataType || dataType == types.NilType {
+		return copyCell(cell), nil
+	}
+	text := cell.ToString()
+	if len(text) == 0 && dataType != types.StringType {
+		return &types.CellValue{DataType: types.NilType}, nil
+	}
+	converted, _, err := conversion.ParseToCell(text, dataType, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("value “%s” can't be converted to %s", text, dataType.String())
 	}
+	return converted, nil
+}
 
-	if _, ok := otherSets[typedConfig.TargetDataset]; !ok {
-		// let's not give up so fast
-		// data might be embedded in our configuration
-		if len(typedConfig.TargetData) == 0 {
+func (t *MappedValueOperator) mapTable(typedConfig *MappedValueConfiguration, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+	if len(typedConfig.TargetData) == 0 {
+		tds, ok := otherSets[typedConfig.TargetDataset]
+		if !ok {
 			return nil, errors.New("target dataset not found")
 		}
+		return tds, nil
 	}
 
-	var tds *types.DataSet
-	if len(typedConfig.TargetData) > 0 {
-		// let's append static headers to our data
-		typedConfig.TargetData = append([][]string{{"Key", "Value"}}, typedConfig.TargetData...)
-		// let's make sure key is always text type
-		conversionMap := conversion.ConversionMap{"Key": false}
-		tds, err = conversion.ConvertToTypedData(typedConfig.TargetData, true, true, conversionMap, true)
-		if err != nil {
-			return nil, err
-		}
-		typedConfig.TargetDataset = RandStringBytesMaskImprSrcUnsafe(10)
-		otherSets[typedConfig.TargetDataset] = tds
-	} else {
-		tds = otherSets[typedConfig.TargetDataset]
+	// let's append the headers to our data
+	data := append([][]string{typedConfig.TargetDataHeaders}, typedConfig.TargetData...)
+	// let's make sure keys are always text type
+	conversionMap := conversion.ConversionMap{}
+	for _, key := range typedConfig.KeyColumns {
+		conversionMap[key] = false
+	}
+	return conversion.ConvertToTypedData(data, true, true, conversionMap, true)
+}
+
+func (t *MappedValueOperator) Transform(dataset *types.DataSet, config string, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(tds.Rows) < 1 || len(dataset.Rows) < 1 {
+	tds, err := t.mapTable(typedConfig, otherSets)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataset.Rows) < 1 {
 		return dataset, nil
 	}
 
-	refRow := tds.Rows[0]
-	if len(refRow.Columns) != 2 {
-		return nil, errors.New("invalid map table")
+	for _, col := range typedConfig.MappedColumnNames {
+		if t.getColumn(dataset.Rows[0], col) == nil {
+			return nil, buildColumnNotExistsError(col)
+		}
 	}
-	if refRow.Columns[0].ColumnName != "Key" || refRow.Columns[1].ColumnName != "Value" {
-		return nil, errors.New("invalid map table")
+	for _, output := range typedConfig.Outputs {
+		if t.getColumn(dataset.Rows[0], output.NewColumnName) != nil {
+			return nil, fmt.Errorf("column “%s” already exists", output.NewColumnName)
+		}
+	}
+	_, valueTypes := extractHeadersAndTypeMap(tds)
+	if len(tds.Rows) > 0 {
+		for _, col := range append(append([]string{}, typedConfig.KeyColumns...), t.valueColumns(typedConfig)...) {
+			if _, ok := valueTypes[col]; !ok {
+				return nil, buildColumnNotExistsError(col)
+			}
+		}
+	}
+
+	_, columnTypes := extractHeadersAndTypeMap(dataset)
+	// the type and the default value of every output
+	outputTypes := make([]types.CellDataType, len(typedConfig.Outputs))
+	defaults := make([]*types.CellValue, len(typedConfig.Outputs))
+	for i, output := range typedConfig.Outputs {
+		// an empty map table has no types - unknown values stay texts
+		outputTypes[i] = types.StringType
+		if vt, ok := valueTypes[output.ValueColumn]; ok && vt != types.NilType {
+			outputTypes[i] = vt
+		}
+		if output.DataType != nil {
+			outputTypes[i] = *output.DataType
+		}
+		// kept values can only be converted to texts
+		mappedType := columnTypes[typedConfig.MappedColumnNames[0]]
+		if output.KeepUnmappedValue && mappedType != types.NilType && mappedType != outputTypes[i] && outputTypes[i] != types.StringType {
+			return nil, fmt.Errorf("column “%s” keeps the unmapped values of “%s” - its data type must be %s like the mapped column or text", output.NewColumnName, typedConfig.MappedColumnNames[0], mappedType.String())
+		}
+		defaults[i] = &types.CellValue{DataType: types.NilType}
+		if output.DefaultValue != nil {
+			defaults[i], err = convertMappedCell(&types.CellValue{DataType: types.StringType, StringValue: *output.DefaultValue}, outputTypes[i])
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
+	lookupOp := &LookupOperator{}
 	lookupConf := &LookupConfiguration{
 		TargetDataset: typedConfig.TargetDataset,
-		Columns: []*JoinColumn{
-			{
-				Left:  typedConfig.MappedColumnName,
-				Right: "Key",
-			},
-		},
-		RemoveRightMatchColumn:   true,
-		RemoveRightDatasetPrefix: true,
-	}
-	textConf, err := json.Marshal(lookupConf)
-	if err != nil {
-		return nil, err
+		Columns:       make([]*JoinColumn, len(typedConfig.MappedColumnNames)),
+	}
+	for i, col := range typedConfig.MappedColumnNames {
+		lookupConf.Columns[i] = &JoinColumn{Left: col, Right: typedConfig.KeyColumns[i], Mode: ExactMatch}
 	}
+	leftIndex := lookupOp.createColIndex(dataset)
+	rightIndex := lookupOp.createColIndex(tds)
+	index := lookupOp.buildLookupIndex(tds, rightIndex, lookupConf)
 
-	lookupOp := &LookupOperator{}
-	transformedSet, err := lookupOp.Transform(dataset, string(textConf), otherSets)
-	if err != nil {
-		return nil, err
+	newDataset := &types.DataSet{
+		Rows: make([]*types.DataRow, len(dataset.Rows)),
 	}
+	for ri, row := range dataset.Rows {
+		newRow := &types.DataRow{
+			Key:     row.Key,
+			Columns: make([]*types.DataColumn, 0, len(row.Columns)+len(typedConfig.Outputs)),
+		}
+		newRow.Columns = append(newRow.Columns, row.Columns...)
 
-	// so is our new data is really string?
-	// let's try to guess it's real type
-	//estimateColumnDataType(transformedSet, typedConfig.NewColumnName)
-	for _, r := range transformedSet.Rows {
-		lastCol := r.Columns[len(r.Columns)-1]
-		if lastCol.ColumnName != "Value" {
-			// wow something fishy going on here
-			return nil, errors.New("internal join error")
+		var mapRow *types.DataRow
+		if matches := lookupOp.matchingRows(row, leftIndex, tds, rightIndex, index, lookupConf); len(matches) > 0 {
+			mapRow = tds.Rows[matches[0]]
 		}
-		lastCol.ColumnName = typedConfig.NewColumnName
+		for i, output := range typedConfig.Outputs {
+			cell := defaults[i]
+			if mapRow != nil {
+				if c := rightIndex[mapRow][output.ValueColumn]; c != nil {
+					cell = c.CellValue
+				}
+			} else if output.KeepUnmappedValue {
+				cell = nil
+				if c := leftIndex[row][typedConfig.MappedColumnNames[0]]; c != nil {
+					cell = c.CellValue
+				}
+			}
+			cell, err = convertMappedCell(cell, outputTypes[i])
+			if err != nil {
+				return nil, err
+			}
+			newRow.Columns = append(newRow.Columns, &types.DataColumn{
+				ColumnName: output.NewColumnName,
+				CellValue:  cell,
+			})
+		}
+		newDataset.Rows[ri] = newRow
 	}
 
-	transformedSet.Headers = buildHeaders(transformedSet, dataset)
-	return transformedSet, nil
+	newDataset.Headers = buildHeaders(newDataset, dataset)
+	for i, output := range typedConfig.Outputs {
+		if h, ok := newDataset.Headers[output.NewColumnName]; ok && h.DataType == types.NilType {
+			h.DataType = outputTypes[i]
+		}
+	}
+	return newDataset, nil
+}
+
+func (t *MappedValueOperator) getColumn(r *types.DataRow, col string) *types.DataColumn {
+	for _, c := range r.Columns {
+		if c.ColumnName == col {
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *MappedValueOperator) valueColumns(typedConfig *MappedValueConfiguration) []string {
+	columns := make([]string, len(typedConfig.Outputs))
+	for i, output := range typedConfig.Outputs {
+		columns[i] = output.ValueColumn
+	}
+	return columns
 }
 
 func (t *MappedValueOperator) buildConfiguration(config string) (*MappedValueConfiguration, error) {
@@ -110,13 +236,69 @@ func (t *MappedValueOperator) buildConfiguration(config string) (*MappedValueCon
 	if err != nil {
 		return nil, err
 	}
-	if len(typedConfig.MappedColumnName) < 1 {
+	if len(typedConfig.MappedColumnName) > 0 && len(typedConfig.MappedColumnNames) > 0 {
+		return nil, errors.New("either mappedcolumnname or mappedcolumnnames is needed in mappedvalue configuration")
+	}
+	if len(typedConfig.MappedColumnName) > 0 {
+		typedConfig.MappedColumnNames = []string{typedConfig.MappedColumnName}
+	}
+	if len(typedConfig.MappedColumnNames) < 1 {
 		return nil, errors.New("missing mappedcolumname in mappedvalue configuration")
 	}
-	if len(typedConfig.NewColumnName) < 1 {
+	if len(typedConfig.KeyColumns) == 0 && len(typedConfig.MappedColumnNames) == 1 {
+		typedConfig.KeyColumns = []string{defaultMapKeyColumn}
+	}
+	if len(typedConfig.KeyColumns) != len(typedConfig.MappedColumnNames) {
+		return nil, errors.New("every mapped column needs a key column in mappedvalue configuration")
+	}
+
+	if len(typedConfig.NewColumnName) > 0 && len(typedConfig.Outputs) > 0 {
+		return nil, errors.New("either newcolumnname or outputs is needed in mappedvalue configuration")
+	}
+	if len(typedConfig.NewColumnName) > 0 {
+		typedConfig.Outputs = []*MappedValueOutput{{
+			ValueColumn:       defaultMapValueColumn,
+			NewColumnName:     typedConfig.NewColumnName,
+			DefaultValue:      typedConfig.DefaultValue,
+			KeepUnmappedValue: typedConfig.KeepUnmappedValue,
+			DataType:          typedConfig.DataType,
+		}}
+	} else if typedConfig.DefaultValue != nil || typedConfig.KeepUnmappedValue || typedConfig.DataType != nil {
+		return nil, errors.New("default value, unmapped value and data type of outputs belong to the outputs in mappedvalue configuration")
+	}
+	if len(typedConfig.Outputs) < 1 {
 		return nil, errors.New("missing newcolumnname in mappedvalue configuration")
 	}
-	if len(typedConfig.TargetDataset) < 1 && len(typedConfig.TargetData) < 1 {
+	for i, output := range typedConfig.Outputs {
+		if output == nil || len(output.NewColumnName) < 1 {
+			return nil, errors.New("missing newcolumnname in mappedvalue configuration")
+		}
+		if len(output.ValueColumn) < 1 {
+			output.ValueColumn = defaultMapValueColumn
+		}
+		if output.DefaultValue != nil && output.KeepUnmappedValue {
+			return nil, fmt.Errorf("“%s” can't have both a default value and the unmapped value", output.NewColumnName)
+		}
+		if output.KeepUnmappedValue && len(typedConfig.MappedColumnNames) > 1 {
+			return nil, fmt.Errorf("“%s” can only keep the unmapped value of a single mapped column", output.NewColumnName)
+		}
+		for _, previous := range typedConfig.Outputs[:i] {
+			if previous.NewColumnName == output.NewColumnName {
+				return nil, fmt.Errorf("column “%s” is added more than once", output.NewColumnName)
+			}
+		}
+	}
+
+	if len(typedConfig.TargetData) > 0 {
+		if len(typedConfig.TargetDataHeaders) == 0 {
+			typedConfig.TargetDataHeaders = []string{defaultMapKeyColumn, defaultMapValueColumn}
+		}
+		for _, col := range append(append([]string{}, typedConfig.KeyColumns...), t.valueColumns(&typedConfig)...) {
+			if !containsString(typedConfig.TargetDataHeaders, col) {
+				return nil, buildColumnNotExistsError(col)
+			}
+		}
+	} else if len(typedConfig.TargetDataset) < 1 {
 		return nil, errors.New("missing targetdataset in mappedvalue configuration")
 	}
 
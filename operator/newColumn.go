@@ -9,7 +9,7 @@ import (
 
 	"github.com/araddon/qlbridge/expr"
 	_ "github.com/araddon/qlbridge/qlbdriver"
-	"github.com/liminaab/filtrify/lmnqlbridge"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/liminaab/filtrify/types"
 )
 
@@ -20,142 +20,346 @@ type NewColumnOperator struct {
 type NewColumnConfiguration struct {
 	Statement string `json:"statement"`
 	GroupBy   string `json:"groupby"`
+	// aggregations are calculated per partition and added to every row of it - rows are kept unlike with GroupBy
+	PartitionBy []string `json:"partitionBy"`
 }
 
-func (t *NewColumnOperator) findSelectedColumnName(config *NewColumnConfiguration) *string {
-	index := strings.LastIndex(config.Statement, " AS `")
-	if index < 0 {
-		return nil
+// newColumnStatement is one of the select columns of the statement
+type newColumnStatement struct {
+	expression string
+	alias      string
+	node       expr.Node
+}
+
+// query returns the statement as a select column
+func (s *newColumnStatement) query() string {
+	expression := s.expression
+	// qlbridge only projects columns without a field when they are function calls
+	if _, isFunc := s.node.(*expr.FuncNode); !isFunc && len(expr.FindAllIdentityField(s.node)) == 0 {
+		expression = fmt.Sprintf("EVAL(%s)", expression)
+	}
+	if len(s.alias) == 0 {
+		return expression
 	}
-	subText := config.Statement[index+4:]
-	selectedColumnName := strings.ReplaceAll(subText, "`", "")
-	return &selectedColumnName
+	return fmt.Sprintf("%s AS %s", expression, quoteIdentifier(s.alias))
 }
 
-func (t *NewColumnOperator) getSelectedStatement(config *NewColumnConfiguration) *string {
-	index := strings.LastIndex(config.Statement, " AS `")
-	if index < 0 {
-		return nil
+func (s *newColumnStatement) isAggregation() bool {
+	return containsNode(s.node, func(node expr.Node) bool {
+		fn, ok := node.(*expr.FuncNode)
+		return ok && fn.F.Aggregate
+	})
+}
+
+// hasFloatLiteral tells if the expression has a literal like 2.0 - qlbridge turns the whole ones into integers
+func (s *newColumnStatement) hasFloatLiteral() bool {
+	return containsNode(s.node, func(node expr.Node) bool {
+		n, ok := node.(*expr.NumberNode)
+		if !ok || !n.IsFloat {
+			return false
+		}
+		// whole floats like 2.0 are flagged as integers too - only integer literals parse as one
+		_, err := strconv.ParseInt(n.Text, 0, 64)
+		return err != nil
+	})
+}
+
+// containsNode tells if the node or any node under it matches
+func containsNode(node expr.Node, match func(expr.Node) bool) bool {
+	if node == nil {
+		return false
+	}
+	if match(node) {
+		return true
 	}
-	selectedStatement := config.Statement[:index]
-	return &selectedStatement
+	var args []expr.Node
+	switch n := node.(type) {
+	case *expr.FuncNode:
+		args = n.Args
+	case *expr.BinaryNode:
+		args = n.Args
+	case *expr.BooleanNode:
+		args = n.Args
+	case *expr.TriNode:
+		args = n.Args
+	case *expr.ArrayNode:
+		args = n.Args
+	case *expr.UnaryNode:
+		args = []expr.Node{n.Arg}
+	}
+	for _, arg := range args {
+		if containsNode(arg, match) {
+			return true
+		}
+	}
+	return false
 }
 
-func (t *NewColumnOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+// parseStatements parses every column of the statement as the column list of a qlbridge select
+func (t *NewColumnOperator) parseStatements(statement string) ([]*newColumnStatement, error) {
+	tokens, err := tokenizeSQL(statement)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statement “%s”: %s", statement, err.Error())
+	}
+	columns, err := splitColumns(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statement “%s”: %s", statement, err.Error())
+	}
+	statements := make([]*newColumnStatement, len(columns))
+	for i, columnTokens := range columns {
+		if len(columnTokens) == 0 {
+			return nil, fmt.Errorf("invalid statement “%s” - a column is missing", statement)
+		}
+		column := statement[columnTokens[0].start:columnTokens[len(columnTokens)-1].end]
+		col, err := parseColumn(wrapLiterals(statement, columnTokens))
+		if err != nil {
+			// the error of the column as it is written points at the user's text rather than the rewritten one
+			if _, writtenErr := parseColumn(column); writtenErr != nil {
+				err = writtenErr
+			}
+			return nil, fmt.Errorf("invalid statement “%s”: %s", statement, err.Error())
+		}
+		s := &newColumnStatement{
+			expression: col.Expr.String(),
+			node:       col.Expr,
+		}
+		// qlbridge names every column but only writes the names given with AS
+		if col.String() != s.expression {
+			s.alias = col.As
+		}
+		statements[i] = s
+	}
+	return statements, nil
+}
 
-	typedConfig, err := t.buildConfiguration(config)
+// splitColumns splits the tokens at the commas which aren't inside parentheses
+func splitColumns(tokens []*sqlToken) ([][]*sqlToken, error) {
+	columns := make([][]*sqlToken, 0)
+	depth := 0
+	start := 0
+	for i, tok := range tokens {
+		if tok.isSymbol("(") {
+			depth++
+		} else if tok.isSymbol(")") {
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unexpected )")
+			}
+		} else if depth == 0 && tok.isSymbol(",") {
+			columns = append(columns, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if depth > 0 {
+		return nil, errors.New("missing )")
+	}
+	return append(columns, tokens[start:]), nil
+}
+
+// parseColumn parses a single select column
+func parseColumn(column string) (*rel.Column, error) {
+	stmt, err := rel.ParseSql("SELECT " + column)
 	if err != nil {
 		return nil, err
 	}
+	sel, ok := stmt.(*rel.SqlSelect)
+	if !ok || len(sel.From) > 0 || sel.Where != nil || sel.Having != nil || len(sel.GroupBy) > 0 || len(sel.OrderBy) > 0 || sel.Limit > 0 {
+		return nil, errors.New("only columns can be selected")
+	}
+	if len(sel.Columns) != 1 || sel.Columns[0].Star || sel.Columns[0].Expr == nil {
+		return nil, fmt.Errorf("“%s” is not a column", column)
+	}
+	return sel.Columns[0], nil
+}
 
-	headers, columnTypeMap := extractHeadersAndTypeMap(dataset)
-	plainAggs := make([]*types.DataColumn, 0)
-
-	// let's check what is the name of the final result column
-	// we need to make sure it doesn't exist in our dataset
-	// if it does we need to return error
-	selectedColName := t.findSelectedColumnName(typedConfig)
-	if selectedColName != nil {
+// checkAliases makes sure the new columns don't exist in our dataset and aren't defined twice
+func (t *NewColumnOperator) checkAliases(statements []*newColumnStatement, headers []string, required bool) error {
+	newColumns := make(map[string]bool)
+	for _, s := range statements {
+		if len(s.alias) == 0 {
+			if required {
+				return fmt.Errorf("statement “%s” has no alias - name the new column like %s AS `name`", s.expression, s.expression)
+			}
+			continue
+		}
 		for _, h := range headers {
-			if strings.EqualFold(h, *selectedColName) {
-				return nil, errors.New("column already exists")
+			if strings.EqualFold(h, s.alias) {
+				return fmt.Errorf("column “%s” already exists", s.alias)
 			}
 		}
+		if newColumns[strings.ToLower(s.alias)] {
+			return fmt.Errorf("column “%s” is defined more than once", s.alias)
+		}
+		newColumns[strings.ToLower(s.alias)] = true
 	}
+	return nil
+}
 
-	var sb strings.Builder
-	sb.WriteString("SELECT ")
+func (t *NewColumnOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := t.parseStatements(typedConfig.Statement)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, _ := extractHeadersAndTypeMap(dataset)
 	// we can't select original columns if there is a group by statement
-	if typedConfig.GroupBy == "" {
-		headers, columnTypeMap, dataset = addKeyRowToDataset(headers, columnTypeMap, dataset)
-		sb.WriteString(buildSelectStatement(headers))
+	// so those don't need a name
+	if err := t.checkAliases(statements, headers, typedConfig.GroupBy == ""); err != nil {
+		return nil, err
+	}
+	if typedConfig.GroupBy != "" {
+		return t.transformGrouped(dataset, typedConfig, statements)
+	}
+
+	for _, p := range typedConfig.PartitionBy {
+		if !containsString(headers, p) {
+			return nil, buildColumnNotExistsError(p)
+		}
+	}
 
-		// we need to execute multiple queries here
-		// first do we have any aggregations in statement?
-		plainStatement, aggs, err := t.splitAggs(typedConfig.Statement)
+	for _, batch := range t.batchStatements(statements) {
+		dataset, err = t.addColumns(dataset, batch, typedConfig.PartitionBy)
 		if err != nil {
 			return nil, err
 		}
-		if len(plainStatement) > 0 {
-			sb.WriteString(", ")
-			sb.WriteString(plainStatement)
+	}
+	return dataset, nil
+}
+
+// batchStatements groups the statements which can be selected in the same query.
+// A statement using a column defined by an earlier statement has to wait for the next query
+func (t *NewColumnOperator) batchStatements(statements []*newColumnStatement) [][]*newColumnStatement {
+	batches := make([][]*newColumnStatement, 0)
+	batch := make([]*newColumnStatement, 0)
+	defined := make(map[string]bool)
+	for _, s := range statements {
+		if len(batch) > 0 && t.referencesAny(s, defined) {
+			batches = append(batches, batch)
+			batch = make([]*newColumnStatement, 0)
+			defined = make(map[string]bool)
 		}
-		if len(aggs) > 0 {
-			// we have a problem here
-			// we have aggregations but we don't have a group by
-			// we have to execute each of these aggregations like a seperate query
-			for _, agg := range aggs {
-				aggData, err := t.executePlainAggregation(agg, dataset, columnTypeMap)
-				if err != nil {
-					return nil, err
-				}
-				if len(aggData.Rows) > 1 {
-					return nil, errors.New("invalid aggregation command")
-				}
-				aggRow := aggData.Rows[0]
-				if len(aggRow.Columns) > 1 {
-					return nil, errors.New("invalid aggregation command")
-				}
-				aggCol := aggRow.Columns[0]
-				plainAggs = append(plainAggs, aggCol)
-			}
+		batch = append(batch, s)
+		defined[strings.ToLower(s.alias)] = true
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func (t *NewColumnOperator) referencesAny(s *newColumnStatement, columns map[string]bool) bool {
+	for _, identity := range expr.FindAllIdentityField(s.node) {
+		if columns[strings.ToLower(identity)] {
+			return true
 		}
+	}
+	return false
+}
 
-	} else {
-		if len(typedConfig.Statement) > 0 {
-			sb.WriteString(typedConfig.Statement)
-		} else {
-			sb.WriteString(buildSelectStatement(headers))
+// addColumns selects the statements next to the existing columns in a single query
+func (t *NewColumnOperator) addColumns(dataset *types.DataSet, statements []*newColumnStatement, partitionBy []string) (*types.DataSet, error) {
+	headers, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	plainAggs := make([]*types.DataColumn, 0)
+	partitionedAggs := make([]*partitionedAggregation, 0)
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	headers, columnTypeMap, dataset = addKeyRowToDataset(headers, columnTypeMap, dataset)
+	sb.WriteString(buildSelectStatement(headers))
+
+	// we need to execute multiple queries here
+	// first do we have any aggregations in statement?
+	for _, s := range statements {
+		if s.isAggregation() {
+			continue
+		}
+		sb.WriteString(", ")
+		sb.WriteString(s.query())
+	}
+	// we have aggregations but we don't have a group by
+	// we have to execute each of these aggregations like a seperate query
+	for _, s := range statements {
+		if !s.isAggregation() {
+			continue
+		}
+		if len(partitionBy) > 0 {
+			agg, err := t.executePartitionedAggregation(s, partitionBy, dataset, columnTypeMap)
+			if err != nil {
+				return nil, err
+			}
+			partitionedAggs = append(partitionedAggs, agg)
+			continue
+		}
+		aggData, err := t.executePlainAggregation(s.query(), dataset, columnTypeMap)
+		if err != nil {
+			return nil, err
+		}
+		if len(aggData.Rows) > 1 {
+			return nil, errors.New("invalid aggregation command")
+		}
+		aggRow := aggData.Rows[0]
+		if len(aggRow.Columns) > 1 {
+			return nil, errors.New("invalid aggregation command")
 		}
+		aggCol := aggRow.Columns[0]
+		plainAggs = append(plainAggs, aggCol)
 	}
 	sb.WriteString(" FROM ")
 	sb.WriteString(defaultTableName)
-	if typedConfig.GroupBy != "" {
-		sb.WriteString(" GROUP BY ")
-		sb.WriteString(fmt.Sprintf("`%s`", typedConfig.GroupBy))
-	}
-	if err != nil {
-		return nil, err
-	}
 	fullQuery := sb.String()
 
 	result, err := executeSQLQuery(fullQuery, dataset, columnTypeMap)
 	if err != nil {
-		// TODO properly fix this in qlbridge
-		// this is a really ugly workaround to select floats
-		// right now the qlbridge driver doesn't support selecting float64
-		selectedStatement := t.getSelectedStatement(typedConfig)
-		if selectedStatement != nil {
-			// let's try to parse this into a float
-			// if it fails we return the original error
-			// if it succeeds we return the result
-			val, floatErr := strconv.ParseFloat(*selectedStatement, 64)
-			if floatErr == nil {
-				// ok let's add this value to all of the rows
-				// now we need to merge result with plain aggregations
-				for _, r := range dataset.Rows {
-					r.Columns = append(r.Columns, &types.DataColumn{
-						ColumnName: *selectedColName,
-						CellValue: &types.CellValue{
-							DataType:    types.DoubleType,
-							DoubleValue: val,
-						},
-					})
-				}
-				dataset.Headers = buildHeaders(dataset, dataset)
-				dataset = removeAndAssignRowKey(dataset)
-				return dataset, nil
-			}
-		}
-
 		return nil, err
 	}
 
 	// now we need to merge result with plain aggregations
 	for _, r := range result.Rows {
 		r.Columns = append(r.Columns, plainAggs...)
+		if len(partitionedAggs) == 0 {
+			continue
+		}
+		key := partitionKey(r, partitionBy)
+		for _, agg := range partitionedAggs {
+			cell, ok := agg.values[key]
+			if !ok {
+				cell = &types.CellValue{DataType: types.NilType}
+			}
+			r.Columns = append(r.Columns, &types.DataColumn{ColumnName: agg.column, CellValue: cell})
+		}
+	}
+	t.toDouble(result, statements)
+
+	result.Headers = buildHeaders(result, dataset)
+	result = removeAndAssignRowKey(result)
+	return result, nil
+}
+
+func (t *NewColumnOperator) transformGrouped(dataset *types.DataSet, typedConfig *NewColumnConfiguration, statements []*newColumnStatement) (*types.DataSet, error) {
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+
+	columns := make([]string, len(statements))
+	for i, s := range statements {
+		columns[i] = s.query()
 	}
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(columns, ", "))
+	sb.WriteString(" FROM ")
+	sb.WriteString(defaultTableName)
+	sb.WriteString(" GROUP BY ")
+	sb.WriteString(fmt.Sprintf("`%s`", typedConfig.GroupBy))
 
+	result, err := executeSQLQuery(sb.String(), dataset, columnTypeMap)
+	if err != nil {
+		return nil, err
+	}
+	t.toDouble(result, statements)
 	result.Headers = buildHeaders(result, dataset)
 	result = removeAndAssignRowKey(result)
 	return result, nil
@@ -171,92 +375,133 @@ func (t *NewColumnOperator) executePlainAggregation(aggrStatement string, ds *ty
 	return result, nil
 }
 
-func (t *NewColumnOperator) hasAggCall(statement string) bool {
-	statement = strings.ToLower(statement)
-	allOps := lmnqlbridge.GetOperators()
-	for key, op := range allOps {
-		aggfn, hasAggFlag := op.(expr.AggFunc)
-		if hasAggFlag {
-			if aggfn.IsAgg() {
-				fncCallKey := key + "("
-				// let's make sure our statement contains a call to an aggregate function
-				if strings.Contains(statement, fncCallKey) {
-					return true
-				}
-			}
+type partitionedAggregation struct {
+	column string
+	// aggregated values by partition key
+	values map[string]*types.CellValue
+}
+
+// executePartitionedAggregation groups the rows by the partition columns in a single query
+func (t *NewColumnOperator) executePartitionedAggregation(s *newColumnStatement, partitionBy []string, ds *types.DataSet, existingColumnTypeMap map[string]types.CellDataType) (*partitionedAggregation, error) {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	for _, p := range partitionBy {
+		sb.WriteString(fmt.Sprintf("lmnagg(`%s`) AS `%s`, ", p, p))
+	}
+	sb.WriteString(s.query())
+	sb.WriteString(" FROM ")
+	sb.WriteString(defaultTableName)
+	sb.WriteString(" GROUP BY ")
+	sb.WriteString(buildSelectStatement(partitionBy))
+
+	result, err := executeSQLQuery(sb.String(), ds, existingColumnTypeMap)
+	if err != nil {
+		return nil, err
+	}
+	agg := &partitionedAggregation{column: s.alias, values: make(map[string]*types.CellValue, len(result.Rows))}
+	for _, r := range result.Rows {
+		c := r.GetColumn(s.alias)
+		if c == nil {
+			return nil, errors.New("invalid aggregation command")
 		}
+		agg.values[partitionKey(r, partitionBy)] = c.CellValue
 	}
-	return false
+	return agg, nil
 }
 
-type selectState int
+// partitionKey marks every value with its type so empty texts, nulls and equal texts of other types don't collide.
+// Numbers share one marker - int, long and double cells of the same value belong to the same partition
+func partitionKey(r *types.DataRow, partitionBy []string) string {
+	var sb strings.Builder
+	for _, p := range partitionBy {
+		c := r.GetColumn(p)
+		if c == nil || c.CellValue == nil || c.CellValue.DataType == types.NilType {
+			sb.WriteString("null")
+		} else {
+			if isNumberType(c.CellValue.DataType) {
+				sb.WriteString("number")
+			} else {
+				sb.WriteString(strconv.Itoa(int(c.CellValue.DataType)))
+			}
+			sb.WriteByte(':')
+			sb.WriteString(c.CellValue.ToString())
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
 
-const (
-	no_call selectState = iota
-	in_call
-	param_name
-)
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
 
-func (t *NewColumnOperator) splitStatements(statement string) []string {
-	statements := make([]string, 0)
-	statementStack := []selectState{no_call}
-	statementBuilder := strings.Builder{}
+// a parenthesis after these is a group rather than a function call
+var groupingKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "LIKE": true, "BETWEEN": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true,
+}
 
-	for _, c := range statement {
-		switch c {
-		case '`':
-			if len(statementStack) > 0 && statementStack[len(statementStack)-1] == param_name {
-				statementStack = statementStack[:len(statementStack)-1]
-			} else {
-				statementStack = append(statementStack, param_name)
-			}
-			statementBuilder.WriteRune(c)
-			break
-		case '(':
-			if len(statementStack) > 0 && statementStack[len(statementStack)-1] != param_name {
-				statementStack = append(statementStack, in_call)
-			}
-			statementBuilder.WriteRune(c)
-			break
-		case ')':
-			if len(statementStack) > 0 && statementStack[len(statementStack)-1] != param_name {
-				statementStack = statementStack[:len(statementStack)-1]
+// wrapLiterals returns the column of the tokens parseable for qlbridge. It only parses select columns starting
+// with an identity, a function call or a text, so a leading number or sign becomes an EVAL call.
+// Function arguments can't start with a parenthesis either, so every group becomes an EVAL call
+func wrapLiterals(statement string, tokens []*sqlToken) string {
+	var sb strings.Builder
+	last := tokens[0].start
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.isSymbol("("):
+			if i > 0 {
+				prev := tokens[i-1]
+				isCall := prev.kind == identToken && !groupingKeywords[strings.ToUpper(prev.text)]
+				if isCall || prev.isKeyword("IN") {
+					continue
+				}
 			}
-			statementBuilder.WriteRune(c)
-			break
-		case ',':
-			if len(statementStack) > 0 && statementStack[len(statementStack)-1] == no_call {
-				statements = append(statements, statementBuilder.String())
-				statementBuilder.Reset()
-			} else {
-				statementBuilder.WriteRune(c)
+			sb.WriteString(statement[last:tok.start])
+			sb.WriteString("EVAL(")
+			last = tok.end
+		case i == 0 && (tok.kind == numberToken || tok.isSymbol("-") || tok.isSymbol("+")):
+			// a sign goes with the number or column after it
+			end := i
+			if tok.kind == symbolToken && i+1 < len(tokens) && !tokens[i+1].isSymbol("(") {
+				end = i + 1
 			}
-			break
-		default:
-			statementBuilder.WriteRune(c)
+			sb.WriteString("EVAL(")
+			sb.WriteString(statement[tok.start:tokens[end].end])
+			sb.WriteString(")")
+			last = tokens[end].end
+			i = end
 		}
 	}
-	if statementBuilder.Len() > 0 {
-		statements = append(statements, statementBuilder.String())
-	}
-	return statements
+	sb.WriteString(statement[last:tokens[len(tokens)-1].end])
+	return sb.String()
 }
 
-func (t *NewColumnOperator) splitAggs(statement string) (string, []string, error) {
-	plainStatements := make([]string, 0)
-	aggStatements := make([]string, 0)
-	miniStatements := t.splitStatements(statement)
-	if len(miniStatements) != 1 {
-		return "", nil, errors.New("new column operator only supports one statement")
-	}
-	for _, ms := range miniStatements {
-		if t.hasAggCall(ms) {
-			aggStatements = append(aggStatements, ms)
-		} else {
-			plainStatements = append(plainStatements, ms)
+// toDouble converts the integer cells of the float expressions
+func (t *NewColumnOperator) toDouble(dataset *types.DataSet, statements []*newColumnStatement) {
+	for _, s := range statements {
+		if len(s.alias) == 0 || !s.hasFloatLiteral() {
+			continue
+		}
+		for _, r := range dataset.Rows {
+			c := r.GetColumn(s.alias)
+			if c == nil {
+				continue
+			}
+			switch c.CellValue.DataType {
+			case types.IntType:
+				c.CellValue = &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(c.CellValue.IntValue)}
+			case types.LongType:
+				c.CellValue = &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(c.CellValue.LongValue)}
+			}
 		}
 	}
-	return strings.Join(plainStatements, ","), aggStatements, nil
 }
 
 func (t *NewColumnOperator) buildConfiguration(config string) (*NewColumnConfiguration, error) {
@@ -270,9 +515,15 @@ func (t *NewColumnOperator) buildConfiguration(config string) (*NewColumnConfigu
 		return nil, err
 	}
 
-	if len(typedConfig.Statement) < 1 {
+	if len(strings.TrimSpace(typedConfig.Statement)) < 1 {
 		return nil, errors.New("missing statement in newcolumn configuration")
 	}
+	if len(typedConfig.GroupBy) > 0 && len(typedConfig.PartitionBy) > 0 {
+		return nil, errors.New("groupby and partitionBy can't be used together in newcolumn configuration")
+	}
+	if _, err := t.parseStatements(typedConfig.Statement); err != nil {
+		return nil, err
+	}
 
 	return &typedConfig, nil
 }
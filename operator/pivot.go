@@ -0,0 +1,269 @@
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	_ "github.com/araddon/qlbridge/qlbdriver"
+	"github.com/liminaab/filtrify/lmnqlbridge"
+	"github.com/liminaab/filtrify/types"
+)
+
+const (
+	pivotValuePlaceholder  = "{value}"
+	pivotColumnPlaceholder = "{column}"
+	pivotColumnName        = "__pivot"
+	pivotValueColumnName   = "__pivot_value"
+	defaultPivotMethod     = "sumx"
+)
+
+type PivotOperator struct {
+}
+
+// PivotConfiguration turns every value of PivotColumn into a column holding the
+// aggregated ValueColumn of the rows with the same RowKeys.
+// Method is one of the lmnqlbridge aggregate functions like sumx, average or first (sumx by default).
+// ColumnNameTemplate names the new columns - {value} is the pivot value and {column} the value column.
+// When PivotValues is set only these columns are created, in this order, whether the data has them or not
+type PivotConfiguration struct {
+	RowKeys            []string `json:"rowKeys"`
+	PivotColumn        string   `json:"pivotColumn"`
+	ValueColumn        string   `json:"valueColumn"`
+	Method             string   `json:"method"`
+	ColumnNameTemplate string   `json:"columnNameTemplate"`
+	PivotValues        []string `json:"pivotValues"`
+}
+
+func isAggregateMethod(method string) bool {
+	op, ok := lmnqlbridge.GetOperators()[method]
+	if !ok {
+		return false
+	}
+	aggfn, hasAggFlag := op.(expr.AggFunc)
+	return hasAggFlag && aggfn.IsAgg()
+}
+
+func (t *PivotOperator) TransformWithConfig(dataset *types.DataSet, typedConfig *PivotConfiguration) (*types.DataSet, error) {
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	for _, col := range append(append([]string{}, typedConfig.RowKeys...), typedConfig.PivotColumn, typedConfig.ValueColumn) {
+		if _, ok := columnTypeMap[col]; !ok {
+			return nil, buildColumnNotExistsError(col)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	groupBy := append(append([]string{}, typedConfig.RowKeys...), typedConfig.PivotColumn)
+	for _, key := range typedConfig.RowKeys {
+		sb.WriteString(fmt.Sprintf("lmnagg(`%s`) AS `%s`,", key, key))
+	}
+	sb.WriteString(fmt.Sprintf("lmnagg(`%s`) AS `%s`,", typedConfig.PivotColumn, pivotColumnName))
+	sb.WriteString(fmt.Sprintf("%s(`%s`) AS `%s`", typedConfig.Method, typedConfig.ValueColumn, pivotValueColumnName))
+	sb.WriteString(" FROM ")
+	sb.WriteString(defaultTableName)
+	sb.WriteString(" GROUP BY ")
+	for i, gb := range groupBy {
+		sb.WriteString(fmt.Sprintf("`%s`", gb))
+		if i != len(groupBy)-1 {
+			sb.WriteString(",")
+		}
+	}
+	aggregated, err := executeSQLQuery(sb.String(), dataset, columnTypeMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// the pivot values become the columns - either the fixed ones or all of them in order
+	pivotValues := typedConfig.PivotValues
+	if len(pivotValues) == 0 {
+		pivotValues, err = t.collectPivotValues(aggregated)
+		if err != nil {
+			return nil, err
+		}
+	}
+	columnNames := make([]string, len(pivotValues))
+	pivotIndex := make(map[string]int, len(pivotValues))
+	for i, v := range pivotValues {
+		name := strings.ReplaceAll(typedConfig.ColumnNameTemplate, pivotValuePlaceholder, v)
+		name = strings.ReplaceAll(name, pivotColumnPlaceholder, typedConfig.ValueColumn)
+		if containsString(typedConfig.RowKeys, name) || containsString(columnNames[:i], name) {
+			return nil, fmt.Errorf("pivot column “%s” is created more than once - use {value} in the column name template", name)
+		}
+		columnNames[i] = name
+		pivotIndex[v] = i
+	}
+
+	// one row per row key combination in the order the dataset has them
+	rowIndex := make(map[string]int)
+	for _, r := range dataset.Rows {
+		key := partitionKey(r, typedConfig.RowKeys)
+		if _, ok := rowIndex[key]; !ok {
+			rowIndex[key] = len(rowIndex)
+		}
+	}
+	newDataset := &types.DataSet{
+		Rows: make([]*types.DataRow, len(rowIndex)),
+	}
+	for _, r := range aggregated.Rows {
+		ri, ok := rowIndex[partitionKey(r, typedConfig.RowKeys)]
+		if !ok {
+			return nil, fmt.Errorf("aggregated row keys “%s” don't match any row of the dataset", rowKeyText(r, typedConfig.RowKeys))
+		}
+		newRow := newDataset.Rows[ri]
+		if newRow == nil {
+			newRow = &types.DataRow{
+				Columns: make([]*types.DataColumn, 0, len(typedConfig.RowKeys)+len(columnNames)),
+			}
+			for _, key := range typedConfig.RowKeys {
+				newRow.Columns = append(newRow.Columns, r.GetColumn(key))
+			}
+			for _, name := range columnNames {
+				newRow.Columns = append(newRow.Columns, &types.DataColumn{
+					ColumnName: name,
+					CellValue:  &types.CellValue{DataType: types.NilType},
+				})
+			}
+			newDataset.Rows[ri] = newRow
+		}
+		pi, ok := pivotIndex[pivotValueName(r.GetColumn(pivotColumnName))]
+		if !ok {
+			// not one of the fixed pivot values
+			continue
+		}
+		value := r.GetColumn(pivotValueColumnName)
+		if value != nil && value.CellValue != nil {
+			newRow.Columns[len(typedConfig.RowKeys)+pi].CellValue = value.CellValue
+		}
+	}
+	for _, r := range newDataset.Rows {
+		if r == nil {
+			return nil, errors.New("aggregated rows are missing for some row keys of the dataset")
+		}
+	}
+
+	newDataset.Headers = buildHeaders(newDataset, dataset)
+	// columns without any value are still typed like the values would be
+	for _, name := range columnNames {
+		if h, ok := newDataset.Headers[name]; ok && h.DataType == types.NilType {
+			h.DataType = t.valueType(newDataset, columnNames, columnTypeMap[typedConfig.ValueColumn])
+		}
+	}
+	return newDataset, nil
+}
+
+// valueType returns the type of the first pivot column with values
+func (t *PivotOperator) valueType(dataset *types.DataSet, columnNames []string, fallback types.CellDataType) types.CellDataType {
+	for _, name := range columnNames {
+		if h, ok := dataset.Headers[name]; ok && h.DataType != types.NilType {
+			return h.DataType
+		}
+	}
+	return fallback
+}
+
+func (t *PivotOperator) collectPivotValues(aggregated *types.DataSet) ([]string, error) {
+	sorter := &SortOperator{}
+	cols := make([]*types.DataColumn, 0)
+	seen := make(map[string]bool)
+	for _, r := range aggregated.Rows {
+		col := r.GetColumn(pivotColumnName)
+		name := pivotValueName(col)
+		// rows without a pivot value don't get a column
+		if len(name) == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		cols = append(cols, col)
+	}
+	var err error
+	sort.SliceStable(cols, func(i, j int) bool {
+		result, compareErr := sorter.CompareColumns(cols[i], cols[j])
+		if compareErr != nil {
+			err = compareErr
+		}
+		return result < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(cols))
+	for i, c := range cols {
+		values[i] = pivotValueName(c)
+	}
+	return values, nil
+}
+
+func rowKeyText(r *types.DataRow, rowKeys []string) string {
+	values := make([]string, len(rowKeys))
+	for i, key := range rowKeys {
+		if c := r.GetColumn(key); c != nil && c.CellValue != nil {
+			values[i] = c.CellValue.ToString()
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func pivotValueName(col *types.DataColumn) string {
+	if col == nil || col.CellValue == nil || col.CellValue.DataType == types.NilType {
+		return ""
+	}
+	return col.CellValue.ToString()
+}
+
+func (t *PivotOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	return t.TransformWithConfig(dataset, typedConfig)
+}
+
+func (t *PivotOperator) buildConfiguration(config string) (*PivotConfiguration, error) {
+	if len(config) < 1 {
+		return nil, errors.New("invalid configuration")
+	}
+	// config is a json declaration of our field configuration
+	typedConfig := PivotConfiguration{}
+	err := json.Unmarshal([]byte(config), &typedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(typedConfig.PivotColumn) < 1 {
+		return nil, errors.New("missing pivot column in Pivot configuration")
+	}
+	if len(typedConfig.ValueColumn) < 1 {
+		return nil, errors.New("missing value column in Pivot configuration")
+	}
+	if containsString(typedConfig.RowKeys, typedConfig.PivotColumn) {
+		return nil, fmt.Errorf("column “%s” can't be both a row key and the pivot column", typedConfig.PivotColumn)
+	}
+	typedConfig.Method = strings.ToLower(strings.TrimSpace(typedConfig.Method))
+	if len(typedConfig.Method) == 0 {
+		typedConfig.Method = defaultPivotMethod
+	}
+	if !isAggregateMethod(typedConfig.Method) {
+		return nil, fmt.Errorf("“%s” is not an aggregate method", typedConfig.Method)
+	}
+	if len(typedConfig.ColumnNameTemplate) == 0 {
+		typedConfig.ColumnNameTemplate = pivotValuePlaceholder
+	}
+	seen := make(map[string]bool)
+	for _, v := range typedConfig.PivotValues {
+		if seen[v] {
+			return nil, fmt.Errorf("pivot value “%s” is listed more than once", v)
+		}
+		seen[v] = true
+	}
+
+	return &typedConfig, nil
+}
+
+func (t *PivotOperator) ValidateConfiguration(config string) (bool, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	return typedConfig != nil, err
+}
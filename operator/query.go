@@ -0,0 +1,144 @@
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/araddon/qlbridge/qlbdriver"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/liminaab/filtrify/types"
+)
+
+type QueryOperator struct {
+}
+
+// QueryConfiguration runs a SELECT statement - the current dataset is the ext table
+// and every other set is a table with its own lowercased name.
+// INNER and LEFT joins on column equality are supported, columns of joined tables are
+// referenced like i.`Instrument name`. ORDER BY can only use the columns of the result.
+// Other joins, subqueries and UNION are rejected when the configuration is validated
+type QueryConfiguration struct {
+	Query string `json:"query"`
+}
+
+func (t *QueryOperator) TransformWithConfig(dataset *types.DataSet, typedConfig *QueryConfiguration, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+	tables := map[string]*types.DataSet{defaultTableName: dataset}
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	for name, set := range otherSets {
+		tableName := strings.ToLower(name)
+		if _, exists := tables[tableName]; exists {
+			return nil, fmt.Errorf("dataset “%s” can't be used as a table - the name is already taken", name)
+		}
+		if set == nil {
+			continue
+		}
+		tables[tableName] = set
+		_, otherTypeMap := extractHeadersAndTypeMap(set)
+		for col, colType := range otherTypeMap {
+			if _, exists := columnTypeMap[col]; !exists {
+				columnTypeMap[col] = colType
+			}
+		}
+	}
+
+	for name, set := range tables {
+		columns, _ := extractHeadersAndTypeMap(set)
+		for _, h := range set.Headers {
+			columns = append(columns, h.ColumnName)
+		}
+		for _, col := range columns {
+			if strings.HasPrefix(strings.ToLower(col), hiddenColumnPrefix) {
+				return nil, fmt.Errorf("column “%s” of table “%s” can't be queried - “%s” is reserved", col, name, hiddenColumnPrefix)
+			}
+		}
+	}
+
+	plan, err := planQuery(typedConfig.Query, tables)
+	if err != nil {
+		return nil, err
+	}
+	for col, colType := range plan.columnTypes {
+		columnTypeMap[col] = colType
+	}
+	result, err := runQueryPlan(plan, columnTypeMap)
+	if err != nil {
+		return nil, err
+	}
+	if err := finishQuery(result, plan); err != nil {
+		return nil, err
+	}
+	result.Headers = buildHeaders(result, dataset)
+	return result, nil
+}
+
+// qlbridge panics on some queries it can parse but can't run
+func runQueryPlan(plan *queryPlan, columnTypeMap map[string]types.CellDataType) (result *types.DataSet, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = fmt.Errorf("query can't be executed: %v", r)
+		}
+	}()
+	// the in memory source takes the fields from the first row - empty sets can't be tables
+	tables := make(map[string]*types.DataSet, len(plan.tables))
+	for name, set := range plan.tables {
+		if len(set.Rows) > 0 {
+			tables[name] = set
+		}
+	}
+	if _, ok := plan.tables[plan.table]; ok {
+		if _, ok := tables[plan.table]; !ok {
+			return &types.DataSet{Rows: []*types.DataRow{}}, nil
+		}
+	}
+	return executeSQLQueryOnTables(plan.query, tables, columnTypeMap)
+}
+
+func (t *QueryOperator) Transform(dataset *types.DataSet, config string, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	return t.TransformWithConfig(dataset, typedConfig, otherSets)
+}
+
+func (t *QueryOperator) buildConfiguration(config string) (*QueryConfiguration, error) {
+	if len(config) < 1 {
+		return nil, errors.New("invalid configuration")
+	}
+	typedConfig := QueryConfiguration{}
+	err := json.Unmarshal([]byte(config), &typedConfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(typedConfig.Query)) == 0 {
+		return nil, errors.New("missing query in query configuration")
+	}
+	// only select statements are allowed - let's parse it to find out
+	if err := validateQuery(typedConfig.Query); err != nil {
+		return nil, fmt.Errorf("query must be a valid SELECT statement: %s", err.Error())
+	}
+	_, _, _, _, sources, err := splitQuery(typedConfig.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query must be a valid SELECT statement: %s", err.Error())
+	}
+	// joins are rewritten before running so qlbridge can only check the simple queries up front
+	if !needsJoin(sources) {
+		plan, err := planQuery(typedConfig.Query, nil)
+		if err == nil {
+			_, err = rel.ParseSqlSelect(plan.query)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query must be a valid SELECT statement: %s", err.Error())
+		}
+	}
+
+	return &typedConfig, nil
+}
+
+func (t *QueryOperator) ValidateConfiguration(config string) (bool, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	return typedConfig != nil, err
+}
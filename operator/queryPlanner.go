@@ -0,0 +1,1039 @@
+package operator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+// qlbridge can't run joins over our in memory tables reliably, so the joins are done here.
+// The joined rows become a single table with one column per source column and the query is
+// rewritten to read from it. DISTINCT, ORDER BY and LIMIT are applied on the result since
+// qlbridge ignores DISTINCT and sorts everything as text
+
+const joinedColumnSeparator = "__"
+
+// columnRef is a possibly qualified column reference like e.`Instrument name`
+type columnRef struct {
+	qualifier string
+	column    string
+	// index of the token after the reference
+	next int
+}
+
+func parseColumnRef(tokens []*sqlToken, i int) *columnRef {
+	tok := tokens[i]
+	adjacent := func(j int) bool {
+		return j < len(tokens) && tokens[j].start == tokens[j-1].end
+	}
+	switch tok.kind {
+	case identToken:
+		if dot := strings.IndexByte(tok.text, '.'); dot >= 0 {
+			if dot == len(tok.text)-1 {
+				// e.`quoted name` or e.*
+				if adjacent(i+1) && (tokens[i+1].kind == quotedIdentToken || tokens[i+1].isSymbol("*")) {
+					return &columnRef{qualifier: tok.text[:dot], column: tokens[i+1].value(), next: i + 2}
+				}
+				return nil
+			}
+			return &columnRef{qualifier: tok.text[:dot], column: tok.text[dot+1:], next: i + 1}
+		}
+		return &columnRef{column: tok.text, next: i + 1}
+	case quotedIdentToken:
+		if adjacent(i+1) && tokens[i+1].kind == identToken && strings.HasPrefix(tokens[i+1].text, ".") {
+			// `e`.name
+			return &columnRef{qualifier: tok.value(), column: tokens[i+1].text[1:], next: i + 2}
+		}
+		if adjacent(i+1) && tokens[i+1].isSymbol(".") && adjacent(i+2) {
+			next := tokens[i+2]
+			if next.kind == quotedIdentToken || next.kind == identToken || next.isSymbol("*") {
+				return &columnRef{qualifier: tok.value(), column: next.value(), next: i + 3}
+			}
+		}
+		return &columnRef{column: tok.value(), next: i + 1}
+	}
+	return nil
+}
+
+var clauseKeywords = []string{"WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET"}
+
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true, "HAVING": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "AS": true, "AND": true, "OR": true, "NOT": true, "IN": true,
+	"IS": true, "NULL": true, "LIKE": true, "BETWEEN": true, "ASC": true, "DESC": true, "TRUE": true, "FALSE": true,
+	"JOIN": true, "INNER": true, "LEFT": true, "OUTER": true, "ON": true, "CASE": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "END": true, "CONTAINS": true, "INTERSECTS": true,
+}
+
+type joinCondition struct {
+	left  *columnRef
+	right *columnRef
+}
+
+type querySource struct {
+	table       string
+	alias       string
+	leftJoin    bool
+	conditions  []*joinCondition
+	dataset     *types.DataSet
+	columns     []string
+	columnTypes map[string]types.CellDataType
+}
+
+// queryOrder is an ORDER BY item - it points to a column of the result either by name or by position
+type queryOrder struct {
+	ref       *columnRef
+	column    string
+	position  int
+	ascending bool
+}
+
+type queryPlan struct {
+	query string
+	// the table the rewritten query reads
+	table    string
+	tables   map[string]*types.DataSet
+	distinct bool
+	// result columns only used by HAVING
+	hidden  []string
+	orderBy []*queryOrder
+	// types of the result columns the tables don't have under the same name
+	columnTypes map[string]types.CellDataType
+	limit       int
+	offset      int
+}
+
+func findClauseEnd(tokens []*sqlToken, from int) int {
+	depth := 0
+	for i := from; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.isSymbol("(") {
+			depth++
+		} else if tok.isSymbol(")") {
+			depth--
+		} else if depth == 0 {
+			for _, kw := range clauseKeywords {
+				if tok.isKeyword(kw) {
+					return i
+				}
+			}
+		}
+	}
+	return len(tokens)
+}
+
+func findTopLevelKeyword(tokens []*sqlToken, from int, kw string) int {
+	depth := 0
+	for i := from; i < len(tokens); i++ {
+		if tokens[i].isSymbol("(") {
+			depth++
+		} else if tokens[i].isSymbol(")") {
+			depth--
+		} else if depth == 0 && tokens[i].isKeyword(kw) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSources reads the FROM clause - only equality joins are supported
+func parseSources(tokens []*sqlToken) ([]*querySource, error) {
+	sources := make([]*querySource, 0)
+	i := 0
+	readTable := func(src *querySource) error {
+		if i >= len(tokens) || (tokens[i].kind != identToken && tokens[i].kind != quotedIdentToken) {
+			return fmt.Errorf("expected a table name in query")
+		}
+		src.table = strings.ToLower(tokens[i].value())
+		src.alias = src.table
+		i++
+		if i < len(tokens) && tokens[i].isKeyword("AS") {
+			i++
+			if i >= len(tokens) {
+				return fmt.Errorf("expected a table alias in query")
+			}
+			src.alias = strings.ToLower(tokens[i].value())
+			i++
+		} else if i < len(tokens) && (tokens[i].kind == identToken || tokens[i].kind == quotedIdentToken) && !sqlKeywords[strings.ToUpper(tokens[i].text)] {
+			src.alias = strings.ToLower(tokens[i].value())
+			i++
+		}
+		return nil
+	}
+
+	first := &querySource{}
+	if err := readTable(first); err != nil {
+		return nil, err
+	}
+	sources = append(sources, first)
+	for i < len(tokens) {
+		src := &querySource{}
+		if tokens[i].isKeyword("LEFT") {
+			src.leftJoin = true
+			i++
+			if i < len(tokens) && tokens[i].isKeyword("OUTER") {
+				i++
+			}
+		} else if tokens[i].isKeyword("INNER") {
+			i++
+		} else if tokens[i].isKeyword("RIGHT") || tokens[i].isKeyword("FULL") || tokens[i].isKeyword("CROSS") {
+			return nil, fmt.Errorf("%s joins are not supported - only INNER and LEFT joins are", strings.ToUpper(tokens[i].text))
+		}
+		if i < len(tokens) && tokens[i].isSymbol(",") {
+			return nil, fmt.Errorf("tables must be joined with JOIN ... ON - comma joins are not supported")
+		}
+		if i >= len(tokens) || !tokens[i].isKeyword("JOIN") {
+			return nil, fmt.Errorf("expected JOIN in query")
+		}
+		i++
+		if err := readTable(src); err != nil {
+			return nil, err
+		}
+		if i >= len(tokens) || !tokens[i].isKeyword("ON") {
+			return nil, fmt.Errorf("join of “%s” needs an ON condition", src.table)
+		}
+		i++
+		for {
+			if i >= len(tokens) {
+				return nil, fmt.Errorf("expected a join condition in query")
+			}
+			left := parseColumnRef(tokens, i)
+			if left == nil || left.next >= len(tokens) || !tokens[left.next].isSymbol("=") || left.next+1 >= len(tokens) {
+				return nil, fmt.Errorf("join conditions must compare two columns with =")
+			}
+			right := parseColumnRef(tokens, left.next+1)
+			if right == nil {
+				return nil, fmt.Errorf("join conditions must compare two columns with =")
+			}
+			src.conditions = append(src.conditions, &joinCondition{left: left, right: right})
+			i = right.next
+			if i < len(tokens) && tokens[i].isKeyword("AND") {
+				i++
+				continue
+			}
+			if i < len(tokens) && tokens[i].isKeyword("OR") {
+				return nil, fmt.Errorf("join conditions can only be combined with AND")
+			}
+			break
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// splitQuery finds the select list and the sources of the query
+func splitQuery(q string) (tokens []*sqlToken, selectStart int, fromIndex int, fromEnd int, sources []*querySource, err error) {
+	tokens, err = tokenizeSQL(q)
+	if err != nil {
+		return
+	}
+	if len(tokens) == 0 || !tokens[0].isKeyword("SELECT") {
+		err = fmt.Errorf("query must be a SELECT statement")
+		return
+	}
+	selectStart = 1
+	if len(tokens) > 1 && tokens[1].isKeyword("DISTINCT") {
+		selectStart = 2
+	}
+	fromIndex = findTopLevelKeyword(tokens, selectStart, "FROM")
+	if fromIndex < 0 {
+		err = fmt.Errorf("query must have a FROM clause")
+		return
+	}
+	fromEnd = findClauseEnd(tokens, fromIndex+1)
+	sources, err = parseSources(tokens[fromIndex+1 : fromEnd])
+	return
+}
+
+// splitSelectItems returns the token ranges of the select list items
+func splitSelectItems(tokens []*sqlToken, selectStart int, fromIndex int) [][2]int {
+	items := make([][2]int, 0)
+	depth := 0
+	itemStart := selectStart
+	for i := selectStart; i <= fromIndex; i++ {
+		if i < fromIndex && tokens[i].isSymbol("(") {
+			depth++
+		} else if i < fromIndex && tokens[i].isSymbol(")") {
+			depth--
+		} else if i == fromIndex || (depth == 0 && tokens[i].isSymbol(",")) {
+			items = append(items, [2]int{itemStart, i})
+			itemStart = i + 1
+		}
+	}
+	return items
+}
+
+// validateQuery rejects the queries the planner can't run without looking at the tables
+func validateQuery(q string) error {
+	tokens, err := tokenizeSQL(q)
+	if err != nil {
+		return err
+	}
+	for i, tok := range tokens {
+		if tok.isKeyword("UNION") || tok.isKeyword("INTERSECT") || tok.isKeyword("EXCEPT") {
+			return fmt.Errorf("%s is not supported", strings.ToUpper(tok.text))
+		}
+		if i > 0 && tok.isKeyword("SELECT") {
+			return fmt.Errorf("subqueries are not supported")
+		}
+	}
+	if _, _, err := rewriteHaving(q); err != nil {
+		return err
+	}
+	tokens, selectStart, fromIndex, fromEnd, _, err := splitQuery(q)
+	if err != nil {
+		return err
+	}
+	plan := &queryPlan{limit: -1}
+	if err := extractOrderAndLimit(tokens, fromEnd, plan, make(map[int]bool)); err != nil {
+		return err
+	}
+
+	// ORDER BY can only use the columns of the result - those are known unless there are unnamed ones
+	items := splitSelectItems(tokens, selectStart, fromIndex)
+	names := make(map[string]bool, len(items))
+	for _, item := range items {
+		name := ""
+		depth := 0
+		for i := item[0]; i < item[1]; i++ {
+			if tokens[i].isSymbol("(") {
+				depth++
+			} else if tokens[i].isSymbol(")") {
+				depth--
+			} else if depth == 0 && tokens[i].isKeyword("AS") && i+1 < item[1] {
+				name = tokens[i+1].value()
+			}
+		}
+		if ref := parseColumnRef(tokens, item[0]); len(name) == 0 && ref != nil && ref.next == item[1] {
+			name = ref.column
+		}
+		if len(name) == 0 || name == "*" || (item[1]-item[0] == 1 && tokens[item[0]].isSymbol("*")) {
+			return nil
+		}
+		names[name] = true
+	}
+	for _, o := range plan.orderBy {
+		if o.position > len(items) {
+			return fmt.Errorf("ORDER BY position %d is greater than the number of selected columns (%d)", o.position, len(items))
+		}
+		if o.ref != nil && !names[o.ref.column] {
+			return fmt.Errorf("ORDER BY column “%s” must be a column of the result - select it or use its position", o.ref.column)
+		}
+	}
+	return nil
+}
+
+// needsJoin tells if the query has to be planned here rather than in qlbridge
+func needsJoin(sources []*querySource) bool {
+	return len(sources) > 1 || sources[0].alias != sources[0].table
+}
+
+// hiddenColumnPrefix is reserved - queries and tables can't use it, so a hidden column never hides another one
+const hiddenColumnPrefix = "__filtrify_having_"
+
+// rewriteHaving replaces the aggregates in HAVING with the aliases of the same select items.
+// qlbridge only evaluates HAVING on aliases - aggregates which aren't selected become hidden columns
+func rewriteHaving(q string) (string, []string, error) {
+	if strings.Contains(strings.ToLower(q), hiddenColumnPrefix) {
+		return "", nil, fmt.Errorf("“%s” is reserved and can't be used in queries", hiddenColumnPrefix)
+	}
+	tokens, selectStart, fromIndex, _, _, err := splitQuery(q)
+	if err != nil {
+		return "", nil, err
+	}
+	having := findTopLevelKeyword(tokens, fromIndex, "HAVING")
+	if having < 0 {
+		return q, nil, nil
+	}
+	end := findClauseEnd(tokens, having+1)
+
+	expressionKey := func(from int, to int) string {
+		var sb strings.Builder
+		for i := from; i < to; i++ {
+			sb.WriteString(strings.ToLower(tokens[i].text))
+		}
+		return sb.String()
+	}
+	aliases := make(map[string]string)
+	depth := 0
+	itemStart := selectStart
+	asIndex := -1
+	for i := selectStart; i <= fromIndex; i++ {
+		if i < fromIndex && tokens[i].isSymbol("(") {
+			depth++
+		} else if i < fromIndex && tokens[i].isSymbol(")") {
+			depth--
+		} else if i < fromIndex && depth == 0 && tokens[i].isKeyword("AS") {
+			asIndex = i
+		} else if i == fromIndex || (depth == 0 && tokens[i].isSymbol(",")) {
+			if asIndex > itemStart && asIndex+1 < i {
+				aliases[expressionKey(itemStart, asIndex)] = tokens[asIndex+1].value()
+			}
+			itemStart = i + 1
+			asIndex = -1
+		}
+	}
+
+	hidden := make([]string, 0)
+	var added, rewritten strings.Builder
+	last := tokens[having].end
+	for i := having + 1; i < end; i++ {
+		if tokens[i].kind != identToken || i+1 >= end || !tokens[i+1].isSymbol("(") {
+			continue
+		}
+		closing := -1
+		depth := 0
+		for j := i + 1; j < end; j++ {
+			if tokens[j].isSymbol("(") {
+				depth++
+			} else if tokens[j].isSymbol(")") {
+				depth--
+				if depth == 0 {
+					closing = j
+					break
+				}
+			}
+		}
+		if closing < 0 {
+			return "", nil, fmt.Errorf("missing ) in HAVING")
+		}
+		key := expressionKey(i, closing+1)
+		alias, ok := aliases[key]
+		if !ok {
+			alias = hiddenColumnPrefix + strconv.Itoa(len(hidden)+1)
+			hidden = append(hidden, alias)
+			aliases[key] = alias
+			added.WriteString(", " + q[tokens[i].start:tokens[closing].end] + " AS " + quoteIdentifier(alias))
+		}
+		rewritten.WriteString(q[last:tokens[i].start])
+		rewritten.WriteString(quoteIdentifier(alias))
+		last = tokens[closing].end
+		i = closing
+	}
+	rewritten.WriteString(q[last:])
+
+	// the hidden columns go to the end of the select list
+	selectEnd := strings.TrimRight(q[:tokens[fromIndex].start], " \t\r\n")
+	return selectEnd + added.String() + " " + q[tokens[fromIndex].start:tokens[having].end] + rewritten.String(), hidden, nil
+}
+
+func planQuery(q string, tables map[string]*types.DataSet) (*queryPlan, error) {
+	q, hidden, err := rewriteHaving(q)
+	if err != nil {
+		return nil, err
+	}
+	tokens, selectStart, fromIndex, fromEnd, sources, err := splitQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	plan := &queryPlan{tables: tables, limit: -1, hidden: hidden, columnTypes: make(map[string]types.CellDataType)}
+	replacements := make(map[int]string)
+	removed := make(map[int]bool)
+	if selectStart == 2 {
+		plan.distinct = true
+		removed[1] = true
+	}
+
+	// qlbridge sorts everything as text, so ordering and limits are applied on the result
+	if err := extractOrderAndLimit(tokens, fromEnd, plan, removed); err != nil {
+		return nil, err
+	}
+	if !needsJoin(sources) {
+		// tables are registered under their lowercased names
+		plan.table = sources[0].table
+		replacements[fromIndex+1] = quoteIdentifier(plan.table)
+		for i := fromIndex + 2; i < fromEnd; i++ {
+			removed[i] = true
+		}
+		for _, o := range plan.orderBy {
+			if o.ref != nil {
+				o.column = o.ref.column
+			}
+		}
+	}
+
+	if needsJoin(sources) {
+		if err := planJoin(tokens, selectStart, fromIndex, fromEnd, sources, plan, replacements, removed); err != nil {
+			return nil, err
+		}
+	}
+
+	var sb strings.Builder
+	last := 0
+	for i, tok := range tokens {
+		if repl, ok := replacements[i]; ok {
+			sb.WriteString(q[last:tok.start])
+			sb.WriteString(repl)
+			last = tok.end
+		} else if removed[i] {
+			sb.WriteString(q[last:tok.start])
+			last = tok.end
+		}
+	}
+	sb.WriteString(q[last:])
+	plan.query = sb.String()
+	return plan, nil
+}
+
+func extractOrderAndLimit(tokens []*sqlToken, from int, plan *queryPlan, removed map[int]bool) error {
+	end := len(tokens)
+	if i := findTopLevelKeyword(tokens, from, "LIMIT"); i >= 0 {
+		end = i
+	}
+	if i := findTopLevelKeyword(tokens, from, "OFFSET"); i >= 0 && i < end {
+		end = i
+	}
+	if i := findTopLevelKeyword(tokens, from, "ORDER"); i >= 0 && i < end {
+		if i+1 >= end || !tokens[i+1].isKeyword("BY") {
+			return fmt.Errorf("expected BY after ORDER in query")
+		}
+		for j := i; j < end; j++ {
+			removed[j] = true
+		}
+		itemStart := i + 2
+		for itemStart < end {
+			itemEnd := itemStart
+			depth := 0
+			for ; itemEnd < end; itemEnd++ {
+				if tokens[itemEnd].isSymbol("(") {
+					depth++
+				} else if tokens[itemEnd].isSymbol(")") {
+					depth--
+				} else if depth == 0 && tokens[itemEnd].isSymbol(",") {
+					break
+				}
+			}
+			o := &queryOrder{ascending: true}
+			last := itemEnd
+			if last > itemStart && (tokens[last-1].isKeyword("ASC") || tokens[last-1].isKeyword("DESC")) {
+				o.ascending = tokens[last-1].isKeyword("ASC")
+				last--
+			}
+			if last-itemStart == 1 && tokens[itemStart].kind == numberToken {
+				pos, err := strconv.Atoi(tokens[itemStart].text)
+				if err != nil || pos < 1 {
+					return fmt.Errorf("invalid ORDER BY position %s", tokens[itemStart].text)
+				}
+				o.position = pos
+			} else if last > itemStart {
+				o.ref = parseColumnRef(tokens, itemStart)
+				if o.ref == nil || o.ref.next != last {
+					return fmt.Errorf("ORDER BY only supports columns of the result - use an alias for expressions")
+				}
+			} else {
+				return fmt.Errorf("expected a column after ORDER BY")
+			}
+			plan.orderBy = append(plan.orderBy, o)
+			itemStart = itemEnd + 1
+		}
+	}
+
+	readNumber := func(i int) (int, error) {
+		if i >= len(tokens) || tokens[i].kind != numberToken {
+			return 0, fmt.Errorf("expected a number in query")
+		}
+		removed[i] = true
+		return strconv.Atoi(tokens[i].text)
+	}
+	var err error
+	if i := findTopLevelKeyword(tokens, from, "LIMIT"); i >= 0 {
+		removed[i] = true
+		if plan.limit, err = readNumber(i + 1); err != nil {
+			return err
+		}
+	}
+	if i := findTopLevelKeyword(tokens, from, "OFFSET"); i >= 0 {
+		removed[i] = true
+		if plan.offset, err = readNumber(i + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinedColumnName(alias string, column string) string {
+	return alias + joinedColumnSeparator + column
+}
+
+func planJoin(tokens []*sqlToken, selectStart int, fromIndex int, fromEnd int, sources []*querySource, plan *queryPlan, replacements map[int]string, removed map[int]bool) error {
+	aliases := make(map[string]*querySource, len(sources))
+	columnOwners := make(map[string][]*querySource)
+	for _, src := range sources {
+		if _, exists := aliases[src.alias]; exists {
+			return fmt.Errorf("table alias “%s” is used more than once", src.alias)
+		}
+		ds, ok := plan.tables[src.table]
+		if !ok {
+			return fmt.Errorf("table “%s” not found", src.table)
+		}
+		src.dataset = ds
+		src.columns, src.columnTypes = extractHeadersAndTypeMap(ds)
+		if len(ds.Rows) == 0 {
+			// an empty set only has its headers
+			for _, h := range ds.OrderedHeaders() {
+				src.columns = append(src.columns, h.ColumnName)
+				src.columnTypes[h.ColumnName] = h.DataType
+			}
+		}
+		aliases[src.alias] = src
+		for _, c := range src.columns {
+			columnOwners[c] = append(columnOwners[c], src)
+		}
+	}
+
+	resolve := func(ref *columnRef) (*querySource, error) {
+		if len(ref.qualifier) > 0 {
+			src, ok := aliases[strings.ToLower(ref.qualifier)]
+			if !ok {
+				return nil, fmt.Errorf("unknown table “%s” in query", ref.qualifier)
+			}
+			if ref.column == "*" {
+				return src, nil
+			}
+			for _, c := range src.columns {
+				if c == ref.column {
+					return src, nil
+				}
+			}
+			return nil, fmt.Errorf("column “%s” not found in table “%s”", ref.column, src.table)
+		}
+		owners := columnOwners[ref.column]
+		if len(owners) > 1 {
+			return nil, fmt.Errorf("column “%s” is ambiguous - qualify it with a table name", ref.column)
+		}
+		if len(owners) == 0 {
+			return nil, nil
+		}
+		return owners[0], nil
+	}
+
+	joined, err := joinSources(sources, resolve)
+	if err != nil {
+		return err
+	}
+	plan.tables = map[string]*types.DataSet{defaultTableName: joined}
+	plan.table = defaultTableName
+
+	// output names of the selected columns - a name used twice keeps its table as prefix
+	outputCount := make(map[string]int)
+	selectItems := splitSelectItems(tokens, selectStart, fromIndex)
+	for _, item := range selectItems {
+		ref := parseColumnRef(tokens, item[0])
+		if item[1]-item[0] == 1 && tokens[item[0]].isSymbol("*") {
+			ref = &columnRef{column: "*", next: item[1]}
+		}
+		if ref != nil && ref.next == item[1] {
+			if ref.column == "*" {
+				var src *querySource
+				if len(ref.qualifier) > 0 {
+					src = aliases[strings.ToLower(ref.qualifier)]
+				}
+				for _, s := range sources {
+					if src == nil || s == src {
+						for _, c := range s.columns {
+							outputCount[c]++
+						}
+					}
+				}
+			} else {
+				outputCount[ref.column]++
+			}
+		}
+	}
+	selected := make(map[string]string)
+	outputName := func(src *querySource, column string) string {
+		name := column
+		if outputCount[column] > 1 {
+			name = src.alias + "." + column
+		}
+		selected[joinedColumnName(src.alias, column)] = name
+		plan.columnTypes[name] = src.columnTypes[column]
+		return name
+	}
+
+	for _, item := range selectItems {
+		ref := parseColumnRef(tokens, item[0])
+		if item[1]-item[0] == 1 && tokens[item[0]].isSymbol("*") {
+			ref = &columnRef{column: "*", next: item[1]}
+		}
+		if ref == nil || ref.next != item[1] {
+			continue
+		}
+		if ref.column == "*" {
+			parts := make([]string, 0)
+			var only *querySource
+			if len(ref.qualifier) > 0 {
+				if only = aliases[strings.ToLower(ref.qualifier)]; only == nil {
+					return fmt.Errorf("unknown table “%s” in query", ref.qualifier)
+				}
+			}
+			for _, s := range sources {
+				if only != nil && s != only {
+					continue
+				}
+				for _, c := range s.columns {
+					parts = append(parts, quoteIdentifier(joinedColumnName(s.alias, c))+" AS "+quoteIdentifier(outputName(s, c)))
+				}
+			}
+			replacements[item[0]] = strings.Join(parts, ", ")
+			for i := item[0] + 1; i < item[1]; i++ {
+				removed[i] = true
+			}
+			continue
+		}
+		src, err := resolve(ref)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			continue
+		}
+		replacements[item[0]] = quoteIdentifier(joinedColumnName(src.alias, ref.column)) + " AS " + quoteIdentifier(outputName(src, ref.column))
+		for i := item[0] + 1; i < item[1]; i++ {
+			removed[i] = true
+		}
+	}
+
+	for _, o := range plan.orderBy {
+		if o.ref == nil {
+			continue
+		}
+		src, err := resolve(o.ref)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			// an alias of the select list
+			o.column = o.ref.column
+			continue
+		}
+		name, ok := selected[joinedColumnName(src.alias, o.ref.column)]
+		if !ok {
+			return fmt.Errorf("ORDER BY column “%s” must be selected", o.ref.column)
+		}
+		o.column = name
+	}
+
+	// every other column reference outside of the FROM clause
+	for i := selectStart; i < len(tokens); i++ {
+		if i == fromIndex {
+			replacements[i] = "FROM " + defaultTableName
+			for j := fromIndex + 1; j < fromEnd; j++ {
+				removed[j] = true
+			}
+			i = fromEnd - 1
+			continue
+		}
+		if _, done := replacements[i]; done || removed[i] {
+			continue
+		}
+		tok := tokens[i]
+		if tok.kind != identToken && tok.kind != quotedIdentToken {
+			continue
+		}
+		if tok.kind == identToken && sqlKeywords[strings.ToUpper(tok.text)] {
+			continue
+		}
+		if i > 0 && tokens[i-1].isKeyword("AS") {
+			// an output name
+			continue
+		}
+		ref := parseColumnRef(tokens, i)
+		if ref == nil {
+			continue
+		}
+		if ref.next < len(tokens) && tokens[ref.next].isSymbol("(") && len(ref.qualifier) == 0 {
+			// a function call
+			continue
+		}
+		src, err := resolve(ref)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			// probably an output name like in ORDER BY total
+			continue
+		}
+		replacements[i] = quoteIdentifier(joinedColumnName(src.alias, ref.column))
+		for j := i + 1; j < ref.next; j++ {
+			removed[j] = true
+		}
+		i = ref.next - 1
+	}
+	return nil
+}
+
+// joinSources hash joins the sources from left to right
+func joinSources(sources []*querySource, resolve func(*columnRef) (*querySource, error)) (*types.DataSet, error) {
+	offsets := make(map[*querySource]int, len(sources))
+	width := 0
+	for _, src := range sources {
+		offsets[src] = width
+		width += len(src.columns)
+	}
+	columnIndex := func(src *querySource, column string) int {
+		for i, c := range src.columns {
+			if c == column {
+				return offsets[src] + i
+			}
+		}
+		return -1
+	}
+
+	rows := make([][]*types.CellValue, 0, len(sources[0].dataset.Rows))
+	for _, r := range sources[0].dataset.Rows {
+		cells := make([]*types.CellValue, width)
+		for i, c := range r.Columns {
+			if i < len(sources[0].columns) {
+				cells[i] = c.CellValue
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	joinedSoFar := map[*querySource]bool{sources[0]: true}
+	for _, src := range sources[1:] {
+		leftIndexes := make([]int, 0, len(src.conditions))
+		rightIndexes := make([]int, 0, len(src.conditions))
+		keyTypes := make([]types.CellDataType, 0, len(src.conditions))
+		for _, cond := range src.conditions {
+			leftSrc, err := resolve(cond.left)
+			if err != nil {
+				return nil, err
+			}
+			rightSrc, err := resolve(cond.right)
+			if err != nil {
+				return nil, err
+			}
+			if leftSrc == nil {
+				return nil, fmt.Errorf("column “%s” not found", cond.left.column)
+			}
+			if rightSrc == nil {
+				return nil, fmt.Errorf("column “%s” not found", cond.right.column)
+			}
+			if leftSrc == src {
+				leftSrc, rightSrc = rightSrc, leftSrc
+				cond = &joinCondition{left: cond.right, right: cond.left}
+			}
+			if rightSrc != src || !joinedSoFar[leftSrc] {
+				return nil, fmt.Errorf("join condition of “%s” must compare its columns with a table joined before it", src.table)
+			}
+			leftIndexes = append(leftIndexes, columnIndex(leftSrc, cond.left.column))
+			rightIndexes = append(rightIndexes, columnIndex(rightSrc, cond.right.column)-offsets[src])
+			keyTypes = append(keyTypes, joinKeyType(leftSrc.columnTypes[cond.left.column], rightSrc.columnTypes[cond.right.column]))
+		}
+
+		index := make(map[string][]*types.DataRow)
+		for _, r := range src.dataset.Rows {
+			cells := make([]*types.CellValue, len(rightIndexes))
+			for i, ci := range rightIndexes {
+				if ci < len(r.Columns) {
+					cells[i] = r.Columns[ci].CellValue
+				}
+			}
+			if key, ok := joinKey(cells, keyTypes); ok {
+				index[key] = append(index[key], r)
+			}
+		}
+
+		next := make([][]*types.CellValue, 0, len(rows))
+		for _, cells := range rows {
+			keyCells := make([]*types.CellValue, len(leftIndexes))
+			for i, ci := range leftIndexes {
+				keyCells[i] = cells[ci]
+			}
+			var matches []*types.DataRow
+			if key, ok := joinKey(keyCells, keyTypes); ok {
+				matches = index[key]
+			}
+			if len(matches) == 0 && src.leftJoin {
+				next = append(next, cells)
+			}
+			for _, m := range matches {
+				joinedCells := make([]*types.CellValue, width)
+				copy(joinedCells, cells)
+				for i, c := range m.Columns {
+					if i < len(src.columns) {
+						joinedCells[offsets[src]+i] = c.CellValue
+					}
+				}
+				next = append(next, joinedCells)
+			}
+		}
+		rows = next
+		joinedSoFar[src] = true
+	}
+
+	joined := &types.DataSet{Rows: make([]*types.DataRow, len(rows))}
+	for ri, cells := range rows {
+		row := &types.DataRow{Columns: make([]*types.DataColumn, width)}
+		for _, src := range sources {
+			for i, c := range src.columns {
+				cell := cells[offsets[src]+i]
+				if cell == nil {
+					cell = &types.CellValue{DataType: types.NilType}
+				}
+				row.Columns[offsets[src]+i] = &types.DataColumn{ColumnName: joinedColumnName(src.alias, c), CellValue: cell}
+			}
+		}
+		joined.Rows[ri] = row
+	}
+	return joined, nil
+}
+
+// joinKeyType is the type both columns of a join condition are compared as - a number or a date
+// of one side turns the texts of the other side into numbers or dates
+func joinKeyType(left types.CellDataType, right types.CellDataType) types.CellDataType {
+	switch {
+	case isNumberType(left) || isNumberType(right):
+		return types.DoubleType
+	case isDateType(left) || isDateType(right):
+		return types.TimestampType
+	}
+	return types.StringType
+}
+
+// joinKeyPart returns the cell as text of the key type so equal values have the same text
+func joinKeyPart(c *types.CellValue, keyType types.CellDataType) (string, bool) {
+	switch keyType {
+	case types.DoubleType:
+		switch c.DataType {
+		case types.IntType:
+			return strconv.FormatInt(int64(c.IntValue), 10), true
+		case types.LongType:
+			return strconv.FormatInt(c.LongValue, 10), true
+		case types.DoubleType:
+			return formatJoinNumber(c.DoubleValue), true
+		case types.StringType:
+			text := strings.TrimSpace(c.StringValue)
+			if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return strconv.FormatInt(i, 10), true
+			}
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				return formatJoinNumber(f), true
+			}
+		}
+		return "", false
+	case types.TimestampType:
+		switch c.DataType {
+		case types.DateType, types.TimestampType:
+			return strconv.FormatInt(c.TimestampValue.UnixNano(), 10), true
+		case types.StringType:
+			if ts := tryParseDateAndTime(strings.TrimSpace(c.StringValue)); ts != nil {
+				return strconv.FormatInt(ts.UnixNano(), 10), true
+			}
+		}
+		return "", false
+	}
+	return c.ToString(), true
+}
+
+func formatJoinNumber(f float64) string {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// joinKey builds the hash key of the cells - nulls never match like in SQL
+func joinKey(cells []*types.CellValue, keyTypes []types.CellDataType) (string, bool) {
+	var sb strings.Builder
+	for i, c := range cells {
+		if c == nil || c.DataType == types.NilType {
+			return "", false
+		}
+		part, ok := joinKeyPart(c, keyTypes[i])
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(part)
+		sb.WriteByte(0)
+	}
+	return sb.String(), true
+}
+
+// finishQuery applies DISTINCT, ORDER BY, OFFSET and LIMIT in this order
+func finishQuery(ds *types.DataSet, plan *queryPlan) error {
+	rows := ds.Rows
+	if len(plan.hidden) > 0 {
+		for _, r := range rows {
+			columns := r.Columns[:0]
+			for _, c := range r.Columns {
+				if !containsString(plan.hidden, c.ColumnName) {
+					columns = append(columns, c)
+				}
+			}
+			r.Columns = columns
+		}
+	}
+	if plan.distinct {
+		seen := make(map[string]bool, len(rows))
+		unique := make([]*types.DataRow, 0, len(rows))
+		for _, r := range rows {
+			var sb strings.Builder
+			for _, c := range r.Columns {
+				sb.WriteString(strconv.Itoa(int(c.CellValue.DataType)))
+				sb.WriteByte(':')
+				sb.WriteString(c.CellValue.ToString())
+				sb.WriteByte(0)
+			}
+			key := sb.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			unique = append(unique, r)
+		}
+		rows = unique
+	}
+
+	if len(plan.orderBy) > 0 && len(rows) > 0 {
+		orderBy := make([]*OrderConfiguration, len(plan.orderBy))
+		for i, o := range plan.orderBy {
+			name := o.column
+			if o.position > 0 {
+				if o.position > len(rows[0].Columns) {
+					return fmt.Errorf("ORDER BY position %d is out of range", o.position)
+				}
+				name = rows[0].Columns[o.position-1].ColumnName
+			} else if rows[0].GetColumn(name) == nil {
+				return buildColumnNotExistsError(name)
+			}
+			orderBy[i] = &OrderConfiguration{ColumnName: name, Ascending: o.ascending}
+		}
+		sorter := &SortOperator{}
+		var err error
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, o := range orderBy {
+				result, cmpErr := sorter.CompareColumns(rows[i].GetColumn(o.ColumnName), rows[j].GetColumn(o.ColumnName))
+				if cmpErr != nil {
+					err = cmpErr
+					return false
+				}
+				if result != 0 {
+					return (result < 0) == o.Ascending
+				}
+			}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.offset > 0 {
+		if plan.offset > len(rows) {
+			plan.offset = len(rows)
+		}
+		rows = rows[plan.offset:]
+	}
+	if plan.limit >= 0 && plan.limit < len(rows) {
+		rows = rows[:plan.limit]
+	}
+	ds.Rows = rows
+	return nil
+}
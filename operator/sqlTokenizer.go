@@ -0,0 +1,109 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+)
+
+type sqlTokenKind int
+
+const (
+	identToken sqlTokenKind = iota
+	quotedIdentToken
+	stringToken
+	numberToken
+	symbolToken
+)
+
+type sqlToken struct {
+	kind  sqlTokenKind
+	text  string
+	start int
+	end   int
+}
+
+// value returns the identifier without the backticks
+func (t *sqlToken) value() string {
+	if t.kind == quotedIdentToken {
+		return strings.ReplaceAll(t.text[1:len(t.text)-1], "``", "`")
+	}
+	return t.text
+}
+
+func (t *sqlToken) isKeyword(kw string) bool {
+	return t.kind == identToken && strings.EqualFold(t.text, kw)
+}
+
+func (t *sqlToken) isSymbol(s string) bool {
+	return t.kind == symbolToken && t.text == s
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// tokenizeSQL splits queries and statements into tokens for the rewrites qlbridge needs before it can parse them
+func tokenizeSQL(q string) ([]*sqlToken, error) {
+	tokens := make([]*sqlToken, 0)
+	i := 0
+	for i < len(q) {
+		c := q[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '`' || c == '\'' || c == '"':
+			i++
+			for {
+				if i >= len(q) {
+					return nil, fmt.Errorf("unterminated %c", c)
+				}
+				if q[i] == c {
+					// doubled quotes are escaped quotes
+					if i+1 < len(q) && q[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				if q[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+			kind := stringToken
+			if c == '`' {
+				kind = quotedIdentToken
+			}
+			tokens = append(tokens, &sqlToken{kind: kind, text: q[start:i], start: start, end: i})
+			continue
+		case c >= '0' && c <= '9':
+			for i < len(q) && ((q[i] >= '0' && q[i] <= '9') || q[i] == '.' || q[i] == 'e' || q[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, &sqlToken{kind: numberToken, text: q[start:i], start: start, end: i})
+			continue
+		case isIdentChar(c):
+			for i < len(q) && isIdentChar(q[i]) {
+				i++
+			}
+			tokens = append(tokens, &sqlToken{kind: identToken, text: q[start:i], start: start, end: i})
+			continue
+		}
+		i++
+		if i < len(q) {
+			switch q[start : i+1] {
+			case "<=", ">=", "!=", "<>", "==", "||":
+				i++
+			}
+		}
+		tokens = append(tokens, &sqlToken{kind: symbolToken, text: q[start:i], start: start, end: i})
+	}
+	return tokens, nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
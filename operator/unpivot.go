@@ -0,0 +1,234 @@
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+const (
+	defaultVariableColumnName = "Variable"
+	defaultValueColumnName    = "Value"
+	unpivotKeySeparator       = "|"
+)
+
+type UnpivotOperator struct {
+}
+
+// UnpivotConfiguration turns every value column into a row with the id columns,
+// the name of the value column and its value.
+// Value columns are either listed or every column (except the id columns) whose name matches ValueColumnPattern.
+// When the value columns have different types they share the closest common type - integers become longs,
+// any double makes all numbers doubles, dates become timestamps and anything else becomes a string.
+// A row with a key gets one new row per value column keyed like <row key>|<value column name>
+type UnpivotConfiguration struct {
+	IDColumns          []string `json:"idColumns"`
+	ValueColumns       []string `json:"valueColumns"`
+	ValueColumnPattern string   `json:"valueColumnPattern"`
+	VariableColumnName string   `json:"variableColumnName"`
+	ValueColumnName    string   `json:"valueColumnName"`
+	SkipNulls          bool     `json:"skipNulls"`
+}
+
+func (t *UnpivotOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	for _, col := range typedConfig.IDColumns {
+		if _, ok := columnTypeMap[col]; !ok {
+			return nil, buildColumnNotExistsError(col)
+		}
+	}
+	valueColumns, err := t.selectValueColumns(typedConfig, headers, columnTypeMap)
+	if err != nil {
+		return nil, err
+	}
+
+	valueTypes := make([]types.CellDataType, len(valueColumns))
+	for i, col := range valueColumns {
+		valueTypes[i] = columnTypeMap[col]
+	}
+	valueType := commonCellDataType(valueTypes)
+
+	newDataset := &types.DataSet{
+		Rows: make([]*types.DataRow, 0, len(dataset.Rows)*len(valueColumns)),
+	}
+	for _, row := range dataset.Rows {
+		for _, col := range valueColumns {
+			value := &types.CellValue{DataType: types.NilType}
+			if c := row.GetColumn(col); c != nil && c.CellValue != nil {
+				value = convertToCommonType(c.CellValue, valueType)
+			}
+			if typedConfig.SkipNulls && value.DataType == types.NilType {
+				continue
+			}
+			newRow := &types.DataRow{
+				Columns: make([]*types.DataColumn, 0, len(typedConfig.IDColumns)+2),
+			}
+			if row.Key != nil {
+				key := *row.Key + unpivotKeySeparator + col
+				newRow.Key = &key
+			}
+			for _, id := range typedConfig.IDColumns {
+				var cell *types.CellValue
+				if c := row.GetColumn(id); c != nil {
+					cell = c.CellValue
+				}
+				newRow.Columns = append(newRow.Columns, &types.DataColumn{
+					ColumnName: id,
+					CellValue:  copyCell(cell),
+				})
+			}
+			newRow.Columns = append(newRow.Columns, &types.DataColumn{
+				ColumnName: typedConfig.VariableColumnName,
+				CellValue:  &types.CellValue{DataType: types.StringType, StringValue: col},
+			}, &types.DataColumn{
+				ColumnName: typedConfig.ValueColumnName,
+				CellValue:  value,
+			})
+			newDataset.Rows = append(newDataset.Rows, newRow)
+		}
+	}
+
+	newDataset.Headers = buildHeaders(newDataset, dataset)
+	if h, ok := newDataset.Headers[typedConfig.ValueColumnName]; ok && h.DataType == types.NilType {
+		h.DataType = valueType
+	}
+	return newDataset, nil
+}
+
+// selectValueColumns returns the value columns in the order of the dataset
+func (t *UnpivotOperator) selectValueColumns(typedConfig *UnpivotConfiguration, headers []string, columnTypeMap map[string]types.CellDataType) ([]string, error) {
+	valueColumns := make([]string, 0)
+	if len(typedConfig.ValueColumns) > 0 {
+		for _, col := range typedConfig.ValueColumns {
+			if _, ok := columnTypeMap[col]; !ok {
+				return nil, buildColumnNotExistsError(col)
+			}
+		}
+		for _, h := range headers {
+			if containsString(typedConfig.ValueColumns, h) {
+				valueColumns = append(valueColumns, h)
+			}
+		}
+		return valueColumns, nil
+	}
+
+	// it's already validated
+	pattern := regexp.MustCompile(typedConfig.ValueColumnPattern)
+	for _, h := range headers {
+		if !containsString(typedConfig.IDColumns, h) && pattern.MatchString(h) {
+			valueColumns = append(valueColumns, h)
+		}
+	}
+	if len(valueColumns) == 0 {
+		return nil, fmt.Errorf("no column matches the value column pattern “%s”", typedConfig.ValueColumnPattern)
+	}
+	return valueColumns, nil
+}
+
+// commonCellDataType returns the type all of the given types can be converted to
+func commonCellDataType(dataTypes []types.CellDataType) types.CellDataType {
+	common := types.NilType
+	for _, dt := range dataTypes {
+		if dt == types.NilType || dt == common {
+			continue
+		}
+		if common == types.NilType {
+			common = dt
+			continue
+		}
+		switch {
+		case isIntegerType(common) && isIntegerType(dt):
+			common = types.LongType
+		case isNumberType(common) && isNumberType(dt):
+			common = types.DoubleType
+		case isDateType(common) && isDateType(dt):
+			common = types.TimestampType
+		default:
+			return types.StringType
+		}
+	}
+	return common
+}
+
+func isIntegerType(dt types.CellDataType) bool {
+	return dt == types.IntType || dt == types.LongType
+}
+
+func isNumberType(dt types.CellDataType) bool {
+	return isIntegerType(dt) || dt == types.DoubleType
+}
+
+func isDateType(dt types.CellDataType) bool {
+	return dt == types.DateType || dt == types.TimestampType
+}
+
+func convertToCommonType(cell *types.CellValue, dataType types.CellDataType) *types.CellValue {
+	if cell.DataType == types.NilType || cell.DataType == dataType {
+		v := *cell
+		return &v
+	}
+	switch dataType {
+	case types.LongType:
+		return &types.CellValue{DataType: types.LongType, LongValue: int64(cell.IntValue)}
+	case types.DoubleType:
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: cell.GetNumericVal()}
+	case types.TimestampType:
+		return &types.CellValue{DataType: types.TimestampType, TimestampValue: cell.TimestampValue}
+	}
+	return &types.CellValue{DataType: types.StringType, StringValue: cell.ToString()}
+}
+
+func (t *UnpivotOperator) buildConfiguration(config string) (*UnpivotConfiguration, error) {
+	if len(config) < 1 {
+		return nil, errors.New("invalid configuration")
+	}
+	// config is a json declaration of our field configuration
+	typedConfig := UnpivotConfiguration{}
+	err := json.Unmarshal([]byte(config), &typedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if (len(typedConfig.ValueColumns) > 0) == (len(typedConfig.ValueColumnPattern) > 0) {
+		return nil, errors.New("either value columns or a value column pattern is needed in Unpivot configuration")
+	}
+	if len(typedConfig.ValueColumnPattern) > 0 {
+		if _, err := regexp.Compile(typedConfig.ValueColumnPattern); err != nil {
+			return nil, fmt.Errorf("invalid value column pattern “%s”: %s", typedConfig.ValueColumnPattern, err.Error())
+		}
+	}
+	for _, col := range typedConfig.ValueColumns {
+		if containsString(typedConfig.IDColumns, col) {
+			return nil, fmt.Errorf("column “%s” can't be both an id column and a value column", col)
+		}
+	}
+	if len(typedConfig.VariableColumnName) == 0 {
+		typedConfig.VariableColumnName = defaultVariableColumnName
+	}
+	if len(typedConfig.ValueColumnName) == 0 {
+		typedConfig.ValueColumnName = defaultValueColumnName
+	}
+	if typedConfig.VariableColumnName == typedConfig.ValueColumnName {
+		return nil, fmt.Errorf("variable and value columns can't both be called “%s”", typedConfig.ValueColumnName)
+	}
+	for _, col := range typedConfig.IDColumns {
+		if col == typedConfig.VariableColumnName || col == typedConfig.ValueColumnName {
+			return nil, fmt.Errorf("column “%s” already exists", col)
+		}
+	}
+
+	return &typedConfig, nil
+}
+
+func (t *UnpivotOperator) ValidateConfiguration(config string) (bool, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	return typedConfig != nil, err
+}
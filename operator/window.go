@@ -0,0 +1,482 @@
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+const (
+	WindowRowNumber    = "row_number"
+	WindowRank         = "rank"
+	WindowDenseRank    = "dense_rank"
+	WindowLag          = "lag"
+	WindowLead         = "lead"
+	WindowFirstValue   = "first_value"
+	WindowLastValue    = "last_value"
+	WindowRunningMin   = "running_min"
+	WindowRunningMax   = "running_max"
+	WindowRunningAvg   = "running_avg"
+	WindowRunningCount = "running_count"
+	WindowMovingSum    = "moving_sum"
+	WindowMovingAvg    = "moving_avg"
+)
+
+type WindowOperator struct {
+}
+
+// WindowFunction adds one new column to every row.
+// Offset is used by lag and lead (defaults to 1) and Default is returned when there is no such row.
+// Moving functions look at the current row and either the Rows-1 rows before it
+// or the rows whose first order by value is within Range (like 30d, 2w or 12h) of the current one
+type WindowFunction struct {
+	Function      string      `json:"function"`
+	Column        string      `json:"column"`
+	NewColumnName string      `json:"newColumnName"`
+	Offset        int         `json:"offset"`
+	Default       interface{} `json:"default"`
+	Rows          int         `json:"rows"`
+	Range         string      `json:"range"`
+}
+
+// WindowConfiguration splits the rows into partitions, orders every partition and
+// runs the functions over it. The rows keep their original order in the result
+type WindowConfiguration struct {
+	PartitionBy []string              `json:"partitionBy"`
+	OrderBy     []*OrderConfiguration `json:"orderBy"`
+	Functions   []*WindowFunction     `json:"functions"`
+}
+
+func (t *WindowOperator) Transform(dataset *types.DataSet, config string, _ map[string]*types.DataSet) (*types.DataSet, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, columnTypeMap := extractHeadersAndTypeMap(dataset)
+	for _, col := range typedConfig.PartitionBy {
+		if _, ok := columnTypeMap[col]; !ok {
+			return nil, buildColumnNotExistsError(col)
+		}
+	}
+	for _, o := range typedConfig.OrderBy {
+		if _, ok := columnTypeMap[o.ColumnName]; !ok {
+			return nil, buildColumnNotExistsError(o.ColumnName)
+		}
+	}
+	for _, f := range typedConfig.Functions {
+		if len(f.Column) > 0 {
+			if _, ok := columnTypeMap[f.Column]; !ok {
+				return nil, buildColumnNotExistsError(f.Column)
+			}
+		}
+		if _, ok := columnTypeMap[f.NewColumnName]; ok {
+			return nil, fmt.Errorf("column “%s” already exists", f.NewColumnName)
+		}
+		if len(f.Range) > 0 {
+			orderType := columnTypeMap[typedConfig.OrderBy[0].ColumnName]
+			if orderType != types.TimestampType && orderType != types.DateType && orderType != types.NilType {
+				return nil, fmt.Errorf("range windows need a date or timestamp column to order by - “%s” is not one", typedConfig.OrderBy[0].ColumnName)
+			}
+		}
+	}
+
+	partitions, err := buildOrderedPartitions(dataset.Rows, typedConfig.PartitionBy, typedConfig.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]*types.CellValue, len(typedConfig.Functions))
+	for fi, f := range typedConfig.Functions {
+		results[fi] = make([]*types.CellValue, len(dataset.Rows))
+		for _, partition := range partitions {
+			err = t.evaluate(f, dataset.Rows, partition, typedConfig.OrderBy, columnTypeMap, results[fi])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	newDataset := &types.DataSet{
+		Rows: make([]*types.DataRow, len(dataset.Rows)),
+	}
+	for i, row := range dataset.Rows {
+		newRow := &types.DataRow{
+			Key:     row.Key,
+			Columns: make([]*types.DataColumn, 0, len(row.Columns)+len(typedConfig.Functions)),
+		}
+		newRow.Columns = append(newRow.Columns, row.Columns...)
+		for fi, f := range typedConfig.Functions {
+			newRow.Columns = append(newRow.Columns, &types.DataColumn{
+				ColumnName: f.NewColumnName,
+				CellValue:  results[fi][i],
+			})
+		}
+		newDataset.Rows[i] = newRow
+	}
+
+	newDataset.Headers = buildHeaders(newDataset, dataset)
+	return newDataset, nil
+}
+
+// buildOrderedPartitions returns the ordered row indexes of every partition
+func buildOrderedPartitions(rows []*types.DataRow, partitionBy []string, orderBy []*OrderConfiguration) ([][]int, error) {
+	partitions := make([][]int, 0)
+	partitionIndex := make(map[string]int)
+	for i, r := range rows {
+		key := partitionKey(r, partitionBy)
+		pi, ok := partitionIndex[key]
+		if !ok {
+			pi = len(partitions)
+			partitionIndex[key] = pi
+			partitions = append(partitions, make([]int, 0))
+		}
+		partitions[pi] = append(partitions[pi], i)
+	}
+
+	if len(orderBy) == 0 {
+		return partitions, nil
+	}
+	var err error
+	for _, partition := range partitions {
+		sort.SliceStable(partition, func(i, j int) bool {
+			if err != nil {
+				return false
+			}
+			result, compareErr := compareWindowRows(rows[partition[i]], rows[partition[j]], orderBy)
+			if compareErr != nil {
+				err = compareErr
+				return false
+			}
+			return result < 0
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return partitions, nil
+}
+
+// compareWindowRows compares two rows by the order by columns - descending columns are flipped
+func compareWindowRows(r1 *types.DataRow, r2 *types.DataRow, orderBy []*OrderConfiguration) (int, error) {
+	sorter := &SortOperator{}
+	for _, o := range orderBy {
+		result, err := sorter.CompareColumns(r1.GetColumn(o.ColumnName), r2.GetColumn(o.ColumnName))
+		if err != nil {
+			return 0, err
+		}
+		if result != 0 {
+			if !o.Ascending {
+				return -result, nil
+			}
+			return result, nil
+		}
+	}
+	return 0, nil
+}
+
+func (t *WindowOperator) evaluate(f *WindowFunction, rows []*types.DataRow, partition []int, orderBy []*OrderConfiguration, columnTypeMap map[string]types.CellDataType, result []*types.CellValue) error {
+	switch f.Function {
+	case WindowRowNumber:
+		for k, ri := range partition {
+			result[ri] = &types.CellValue{DataType: types.LongType, LongValue: int64(k + 1)}
+		}
+	case WindowRank, WindowDenseRank:
+		rank, denseRank := 0, 0
+		for k, ri := range partition {
+			peer := false
+			if k > 0 {
+				c, err := compareWindowRows(rows[partition[k-1]], rows[ri], orderBy)
+				if err != nil {
+					return err
+				}
+				peer = c == 0
+			}
+			if !peer {
+				rank = k + 1
+				denseRank++
+			}
+			if f.Function == WindowRank {
+				result[ri] = &types.CellValue{DataType: types.LongType, LongValue: int64(rank)}
+			} else {
+				result[ri] = &types.CellValue{DataType: types.LongType, LongValue: int64(denseRank)}
+			}
+		}
+	case WindowLag, WindowLead:
+		defaultValue, err := buildWindowDefault(f.Default, columnTypeMap[f.Column])
+		if err != nil {
+			return err
+		}
+		offset := f.Offset
+		if offset < 1 {
+			offset = 1
+		}
+		if f.Function == WindowLag {
+			offset = -offset
+		}
+		for k, ri := range partition {
+			target := k + offset
+			if target < 0 || target >= len(partition) {
+				v := *defaultValue
+				result[ri] = &v
+				continue
+			}
+			result[ri] = windowCell(rows[partition[target]], f.Column)
+		}
+	case WindowFirstValue, WindowLastValue:
+		source := partition[0]
+		if f.Function == WindowLastValue {
+			source = partition[len(partition)-1]
+		}
+		for _, ri := range partition {
+			result[ri] = windowCell(rows[source], f.Column)
+		}
+	case WindowRunningMin, WindowRunningMax:
+		sorter := &SortOperator{}
+		var best *types.DataColumn
+		for _, ri := range partition {
+			col := rows[ri].GetColumn(f.Column)
+			if col != nil && col.CellValue.DataType != types.NilType {
+				if best == nil {
+					best = col
+				} else {
+					c, err := sorter.CompareColumns(col, best)
+					if err != nil {
+						return err
+					}
+					if (f.Function == WindowRunningMin && c < 0) || (f.Function == WindowRunningMax && c > 0) {
+						best = col
+					}
+				}
+			}
+			if best == nil {
+				result[ri] = &types.CellValue{DataType: types.NilType}
+				continue
+			}
+			v := *best.CellValue
+			result[ri] = &v
+		}
+	case WindowRunningAvg, WindowRunningCount:
+		var sum float64
+		var numericCount, count int64
+		for _, ri := range partition {
+			cell := windowCell(rows[ri], f.Column)
+			if cell.DataType != types.NilType {
+				count++
+			}
+			if cell.IsNumeric() {
+				sum += cell.GetNumericVal()
+				numericCount++
+			}
+			if f.Function == WindowRunningCount {
+				result[ri] = &types.CellValue{DataType: types.LongType, LongValue: count}
+			} else {
+				result[ri] = averageCell(sum, numericCount)
+			}
+		}
+	case WindowMovingSum, WindowMovingAvg:
+		var windowRange time.Duration
+		if len(f.Range) > 0 {
+			// it's already validated
+			windowRange, _ = parseWindowRange(f.Range)
+		}
+		for k, ri := range partition {
+			start := k
+			if f.Rows > 0 {
+				start = k - f.Rows + 1
+				if start < 0 {
+					start = 0
+				}
+			} else {
+				current := rows[ri].GetColumn(orderBy[0].ColumnName)
+				if current == nil || current.CellValue.DataType == types.NilType {
+					result[ri] = &types.CellValue{DataType: types.NilType}
+					continue
+				}
+				for start > 0 {
+					previous := rows[partition[start-1]].GetColumn(orderBy[0].ColumnName)
+					if previous == nil || previous.CellValue.DataType == types.NilType {
+						break
+					}
+					diff := current.CellValue.TimestampValue.Sub(previous.CellValue.TimestampValue)
+					if diff < 0 {
+						diff = -diff
+					}
+					if diff >= windowRange {
+						break
+					}
+					start--
+				}
+			}
+			var sum float64
+			var count int64
+			for _, wi := range partition[start : k+1] {
+				cell := windowCell(rows[wi], f.Column)
+				if cell.IsNumeric() {
+					sum += cell.GetNumericVal()
+					count++
+				}
+			}
+			if f.Function == WindowMovingSum && count > 0 {
+				result[ri] = &types.CellValue{DataType: types.DoubleType, DoubleValue: sum}
+			} else {
+				result[ri] = averageCell(sum, count)
+			}
+		}
+	}
+	return nil
+}
+
+func averageCell(sum float64, count int64) *types.CellValue {
+	if count == 0 {
+		return &types.CellValue{DataType: types.NilType}
+	}
+	return &types.CellValue{DataType: types.DoubleType, DoubleValue: sum / float64(count)}
+}
+
+// windowCell returns a copy of the cell so the new column doesn't share it with the source
+func windowCell(row *types.DataRow, column string) *types.CellValue {
+	col := row.GetColumn(column)
+	if col == nil || col.CellValue == nil {
+		return &types.CellValue{DataType: types.NilType}
+	}
+	v := *col.CellValue
+	return &v
+}
+
+// buildWindowDefault converts the json default of lag and lead to the type of the column
+func buildWindowDefault(value interface{}, dataType types.CellDataType) (*types.CellValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return &types.CellValue{DataType: types.NilType}, nil
+	case bool:
+		return &types.CellValue{DataType: types.BoolType, BoolValue: v}, nil
+	case float64:
+		switch dataType {
+		case types.IntType:
+			if v == math.Trunc(v) {
+				return &types.CellValue{DataType: types.IntType, IntValue: int32(v)}, nil
+			}
+		case types.LongType:
+			if v == math.Trunc(v) {
+				return &types.CellValue{DataType: types.LongType, LongValue: int64(v)}, nil
+			}
+		}
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: v}, nil
+	case string:
+		if dataType == types.TimestampType || dataType == types.DateType {
+			ts := tryParseDateAndTime(v)
+			if ts == nil {
+				return nil, fmt.Errorf("default value “%s” is not a valid date", v)
+			}
+			return &types.CellValue{DataType: dataType, TimestampValue: *ts}, nil
+		}
+		return &types.CellValue{DataType: types.StringType, StringValue: v}, nil
+	}
+	return nil, fmt.Errorf("unsupported default value %v", value)
+}
+
+// parseWindowRange accepts go durations plus days and weeks
+func parseWindowRange(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	unit := time.Duration(0)
+	if strings.HasSuffix(value, "d") {
+		unit = 24 * time.Hour
+	} else if strings.HasSuffix(value, "w") {
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	if unit > 0 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid window range “%s”", value)
+		}
+		d = time.Duration(n) * unit
+	} else {
+		var err error
+		d, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window range “%s”", value)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("window range “%s” must be positive", value)
+	}
+	return d, nil
+}
+
+func (t *WindowOperator) buildConfiguration(config string) (*WindowConfiguration, error) {
+	if len(config) < 1 {
+		return nil, errors.New("invalid configuration")
+	}
+	typedConfig := WindowConfiguration{}
+	err := json.Unmarshal([]byte(config), &typedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(typedConfig.Functions) == 0 {
+		return nil, errors.New("missing functions in Window configuration")
+	}
+	for _, o := range typedConfig.OrderBy {
+		if o == nil || len(o.ColumnName) < 1 {
+			return nil, errors.New("missing order by column name in Window configuration")
+		}
+	}
+	newColumns := make(map[string]bool)
+	for _, f := range typedConfig.Functions {
+		if f == nil {
+			return nil, errors.New("invalid function in Window configuration")
+		}
+		if len(f.NewColumnName) < 1 {
+			return nil, errors.New("missing new column name in Window configuration")
+		}
+		if newColumns[f.NewColumnName] {
+			return nil, fmt.Errorf("column “%s” is defined more than once", f.NewColumnName)
+		}
+		newColumns[f.NewColumnName] = true
+		if f.Offset < 0 {
+			return nil, fmt.Errorf("offset of “%s” can't be negative", f.NewColumnName)
+		}
+
+		switch f.Function {
+		case WindowRowNumber, WindowRank, WindowDenseRank:
+			continue
+		case WindowLag, WindowLead, WindowFirstValue, WindowLastValue, WindowRunningMin, WindowRunningMax, WindowRunningAvg, WindowRunningCount:
+		case WindowMovingSum, WindowMovingAvg:
+			if (f.Rows > 0) == (len(f.Range) > 0) {
+				return nil, fmt.Errorf("moving window “%s” needs either a number of rows or a range", f.NewColumnName)
+			}
+			if f.Rows < 0 {
+				return nil, fmt.Errorf("number of rows of “%s” can't be negative", f.NewColumnName)
+			}
+			if len(f.Range) > 0 {
+				if len(typedConfig.OrderBy) == 0 {
+					return nil, fmt.Errorf("range window “%s” needs an order by column", f.NewColumnName)
+				}
+				if _, err := parseWindowRange(f.Range); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown window function “%s”", f.Function)
+		}
+		if len(f.Column) < 1 {
+			return nil, fmt.Errorf("missing column name for %s in Window configuration", f.Function)
+		}
+	}
+
+	return &typedConfig, nil
+}
+
+func (t *WindowOperator) ValidateConfiguration(config string) (bool, error) {
+	typedConfig, err := t.buildConfiguration(config)
+	return typedConfig != nil, err
+}
@@ -0,0 +1,99 @@
+package parquetio
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func buildSampleDataset(rowCount int) *types.DataSet {
+	rows := make([]*types.DataRow, rowCount)
+	for i := range rows {
+		key := fmt.Sprintf("key-%d", i)
+		tradeDate := time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i)
+		price := dataset.DoubleColumn("Price", 12.5+float64(i))
+		if i%3 == 1 {
+			price = dataset.NilColumn("Price")
+		}
+		rows[i] = dataset.DataRow(&key,
+			dataset.StringColumn("Instrument", fmt.Sprintf("instrument %d", i)),
+			dataset.IntColumn("Quantity", int32(i*100)),
+			dataset.LongColumn("Notional", int64(i)*3000000000),
+			price,
+			dataset.BoolColumn("Active", i%2 == 0),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: tradeDate}},
+			dataset.TimestampColumn("Created", tradeDate.Add(15*time.Hour+4*time.Minute+5*time.Second+123*time.Nanosecond)),
+			&types.DataColumn{ColumnName: "Cut Off", CellValue: &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: time.Date(0, 1, 1, 17, 30, i%60, 0, time.UTC)}},
+			&types.DataColumn{ColumnName: "Details", CellValue: &types.CellValue{DataType: types.ObjectType, ObjectValue: map[string]interface{}{"desk": "equities"}}},
+		)
+	}
+	return dataset.New(rows)
+}
+
+func TestWriteAndReadBack(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(10)
+
+	var buf bytes.Buffer
+	assert.Nil(Write(&buf, ds, &WriterConfiguration{KeyColumn: "_key"}))
+
+	read, err := Read(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(err)
+	assert.Len(read.Rows, 10)
+	for _, h := range ds.Headers {
+		assert.Equal(h.DataType, read.Headers[h.ColumnName].DataType, h.ColumnName)
+	}
+	assert.Nil(read.Headers["_key"])
+	headers := read.OrderedHeaders()
+	assert.Equal("Instrument", headers[0].ColumnName)
+	assert.Equal("Details", headers[8].ColumnName)
+
+	for i, r := range read.Rows {
+		assert.Equal(*ds.Rows[i].Key, *r.Key)
+		for _, c := range r.Columns {
+			expected := ds.Rows[i].GetColumn(c.ColumnName).CellValue
+			if expected.DataType == types.NilType {
+				assert.Equal(types.NilType, c.CellValue.DataType)
+				continue
+			}
+			if expected.DataType == types.ObjectType {
+				assert.Equal(expected.ObjectValue, c.CellValue.ObjectValue)
+				continue
+			}
+			assert.True(expected.Equals(c.CellValue), c.ColumnName)
+		}
+	}
+	assert.Equal(123, read.Rows[0].GetColumn("Created").CellValue.TimestampValue.Nanosecond())
+}
+
+func TestReadSelectedColumnsAndRowGroups(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(25)
+
+	var buf bytes.Buffer
+	assert.Nil(Write(&buf, ds, &WriterConfiguration{RowGroupSize: 10}))
+
+	config := DefaultReaderConfiguration()
+	config.Columns = []string{"Quantity", "Instrument"}
+	config.RowGroups = []int{2}
+	read, err := Read(bytes.NewReader(buf.Bytes()), config)
+	assert.Nil(err)
+	assert.Len(read.Rows, 5)
+	assert.Len(read.Headers, 2)
+	assert.Equal("instrument 20", read.Rows[0].GetColumn("Instrument").CellValue.StringValue)
+	assert.Equal(int32(2400), read.Rows[4].GetColumn("Quantity").CellValue.IntValue)
+
+	config.RowGroups = []int{3}
+	_, err = Read(bytes.NewReader(buf.Bytes()), config)
+	assert.EqualError(err, "row group 3 doesn't exist - the file has 3 row groups")
+
+	config.RowGroups = nil
+	config.Columns = []string{"Missing"}
+	_, err = Read(bytes.NewReader(buf.Bytes()), config)
+	assert.EqualError(err, "column “Missing” doesn't exist in the parquet file")
+}
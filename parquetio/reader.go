@@ -0,0 +1,110 @@
+package parquetio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/file"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"github.com/liminaab/filtrify/arrowio"
+	"github.com/liminaab/filtrify/types"
+)
+
+type ReaderConfiguration struct {
+	// only these columns are read - all columns are read when it is empty
+	Columns []string
+	// only these row groups are read - all row groups are read when it is empty
+	RowGroups []int
+	// name of the column holding the row keys - files written with a KeyColumn don't need it
+	KeyColumn string
+	// number of rows decoded at once
+	BatchSize int64
+}
+
+func DefaultReaderConfiguration() *ReaderConfiguration {
+	return &ReaderConfiguration{
+		BatchSize: defaultRowGroupSize,
+	}
+}
+
+func Read(r parquet.ReaderAtSeeker, config *ReaderConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = DefaultReaderConfiguration()
+	}
+	pqReader, err := file.NewParquetReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer pqReader.Close()
+
+	batchSize := config.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultRowGroupSize
+	}
+	mem := memory.NewGoAllocator()
+	reader, err := pqarrow.NewFileReader(pqReader, pqarrow.ArrowReadProperties{BatchSize: batchSize}, mem)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := reader.Schema()
+	if err != nil {
+		return nil, err
+	}
+	var colIndices []int
+	if len(config.Columns) > 0 {
+		colIndices = make([]int, 0, len(config.Columns)+1)
+		selected := make(map[string]bool, len(config.Columns))
+		for _, c := range config.Columns {
+			indices := schema.FieldIndices(c)
+			if len(indices) == 0 {
+				return nil, fmt.Errorf("column “%s” doesn't exist in the parquet file", c)
+			}
+			selected[c] = true
+		}
+		for i, f := range schema.Fields() {
+			if arrowio.IsRowKeyField(f) || selected[f.Name] || f.Name == config.KeyColumn {
+				colIndices = append(colIndices, i)
+			}
+		}
+	}
+	rowGroups := config.RowGroups
+	for _, rg := range rowGroups {
+		if rg < 0 || rg >= pqReader.NumRowGroups() {
+			return nil, fmt.Errorf("row group %d doesn't exist - the file has %d row groups", rg, pqReader.NumRowGroups())
+		}
+	}
+
+	recordReader, err := reader.GetRecordReader(context.Background(), colIndices, rowGroups)
+	if err != nil {
+		return nil, err
+	}
+	defer recordReader.Release()
+
+	builder, err := arrowio.NewDataSetBuilder(recordReader.Schema(), &arrowio.ReaderConfiguration{KeyColumn: config.KeyColumn})
+	if err != nil {
+		return nil, err
+	}
+	for recordReader.Next() {
+		if err := builder.Append(recordReader.Record()); err != nil {
+			return nil, err
+		}
+	}
+	if err := recordReader.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return builder.DataSet(), nil
+}
+
+func ReadFile(filePath string, config *ReaderConfiguration) (*types.DataSet, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, config)
+}
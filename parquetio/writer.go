@@ -0,0 +1,78 @@
+package parquetio
+
+import (
+	"io"
+	"os"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/compress"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"github.com/liminaab/filtrify/arrowio"
+	"github.com/liminaab/filtrify/types"
+)
+
+const defaultRowGroupSize = 64 * 1024
+
+type WriterConfiguration struct {
+	// name of an extra column holding the row keys - row keys are not written when it is empty
+	KeyColumn string
+	// maximum number of rows per row group
+	RowGroupSize int
+	Compression  compress.Compression
+}
+
+func DefaultWriterConfiguration() *WriterConfiguration {
+	return &WriterConfiguration{
+		RowGroupSize: defaultRowGroupSize,
+		Compression:  compress.Codecs.Snappy,
+	}
+}
+
+// Write writes the dataset as a parquet file.
+// Columns are optional so nil cells are kept as nulls. The arrow schema is stored in the file
+// as well - this is how object columns and the row key column are recognised when reading back
+func Write(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	rowGroupSize := config.RowGroupSize
+	if rowGroupSize < 1 {
+		rowGroupSize = defaultRowGroupSize
+	}
+	arrowConfig := &arrowio.WriterConfiguration{KeyColumn: config.KeyColumn, BatchSize: rowGroupSize}
+	mem := memory.NewGoAllocator()
+
+	props := parquet.NewWriterProperties(
+		parquet.WithAllocator(mem),
+		parquet.WithCompression(config.Compression),
+		parquet.WithMaxRowGroupLength(int64(rowGroupSize)),
+		// nanosecond timestamps and times need the 2.6 format
+		parquet.WithVersion(parquet.V2_LATEST),
+	)
+	arrowProps := pqarrow.NewArrowWriterProperties(pqarrow.WithAllocator(mem), pqarrow.WithStoreSchema())
+	writer, err := pqarrow.NewFileWriter(arrowio.Schema(dataset, arrowConfig), w, props, arrowProps)
+	if err != nil {
+		return err
+	}
+	err = arrowio.EachRecord(dataset, arrowConfig, mem, func(rec arrow.Record) error {
+		return writer.Write(rec)
+	})
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func WriteFile(filePath string, dataset *types.DataSet, config *WriterConfiguration) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	err = Write(f, dataset, config)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,452 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+type DecoderConfiguration struct {
+	// string values and row keys share the memory of the snapshot instead of being copied.
+	// The snapshot must not be modified while the dataset is in use
+	ZeroCopy bool
+	// the checksum isn't verified - only for snapshots that were verified before
+	SkipChecksum bool
+}
+
+type decoder struct {
+	data     []byte
+	pos      int
+	zeroCopy bool
+	names    []string
+	// zones that can't be loaded keep their name and offset
+	fixedZones map[string]*time.Location
+	locations  map[string]*time.Location
+}
+
+// Verify checks the magic bytes, the version and the checksum of the snapshot without decoding it
+func Verify(data []byte) error {
+	if err := checkPreamble(data); err != nil {
+		return err
+	}
+	end := len(data) - 4
+	if crc32.Checksum(data[:end], crcTable) != binary.LittleEndian.Uint32(data[end:]) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+func checkPreamble(data []byte) error {
+	if len(data) < len(magic)+2+4 || string(data[:len(magic)]) != magic {
+		return ErrInvalidSnapshot
+	}
+	if v := binary.LittleEndian.Uint16(data[len(magic):]); v != Version {
+		return fmt.Errorf("%w %d", ErrUnsupportedVersion, v)
+	}
+	return nil
+}
+
+// Decode returns the dataset stored in the snapshot
+func Decode(data []byte, config *DecoderConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = &DecoderConfiguration{}
+	}
+	verify := Verify
+	if config.SkipChecksum {
+		verify = checkPreamble
+	}
+	if err := verify(data); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{
+		data:       data[:len(data)-4],
+		pos:        len(magic) + 2,
+		zeroCopy:   config.ZeroCopy,
+		fixedZones: make(map[string]*time.Location),
+		locations:  make(map[string]*time.Location),
+	}
+	ds, err := d.readDataSet()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected data after the rows", ErrInvalidSnapshot)
+	}
+	return ds, nil
+}
+
+func Read(r io.Reader, config *DecoderConfiguration) (*types.DataSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// the data isn't visible to the caller so it is safe to share it
+	if config == nil {
+		config = &DecoderConfiguration{}
+	}
+	shared := *config
+	shared.ZeroCopy = true
+	return Decode(data, &shared)
+}
+
+func ReadFile(filePath string, config *DecoderConfiguration) (*types.DataSet, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, config)
+}
+
+func (d *decoder) readDataSet() (*types.DataSet, error) {
+	nameCount, err := d.readCount()
+	if err != nil {
+		return nil, err
+	}
+	d.names = make([]string, nameCount)
+	for i := range d.names {
+		// names are few - they are always copied
+		b, err := d.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		d.names[i] = string(b)
+	}
+
+	headerCount, err := d.readCount()
+	if err != nil {
+		return nil, err
+	}
+	headers := make(types.HeaderMap, headerCount)
+	for i := 0; i < headerCount; i++ {
+		name, err := d.readName()
+		if err != nil {
+			return nil, err
+		}
+		dataType, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		order, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		headers[name] = &types.Header{ColumnName: name, DataType: types.CellDataType(dataType), Order: order}
+	}
+
+	rowCount, err := d.readCount()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*types.DataRow, rowCount)
+	for i := range rows {
+		row := &types.DataRow{}
+		hasKey, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if hasKey == 1 {
+			key, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			row.Key = &key
+		}
+		colCount, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		// all cells of the row are allocated at once
+		row.Columns = make([]*types.DataColumn, colCount)
+		columns := make([]types.DataColumn, colCount)
+		cells := make([]types.CellValue, colCount)
+		for ci := range row.Columns {
+			name, err := d.readName()
+			if err != nil {
+				return nil, err
+			}
+			if err := d.readCell(&cells[ci]); err != nil {
+				return nil, fmt.Errorf("column “%s”: %w", name, err)
+			}
+			columns[ci] = types.DataColumn{ColumnName: name, CellValue: &cells[ci]}
+			row.Columns[ci] = &columns[ci]
+		}
+		rows[i] = row
+	}
+	return &types.DataSet{Rows: rows, Headers: headers}, nil
+}
+
+func (d *decoder) readCell(cell *types.CellValue) error {
+	dataType, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	cell.DataType = types.CellDataType(dataType)
+	switch cell.DataType {
+	case types.IntType:
+		v, err := d.readVarint()
+		cell.IntValue = int32(v)
+		return err
+	case types.LongType:
+		cell.LongValue, err = d.readVarint()
+		return err
+	case types.DoubleType:
+		v, err := d.readUint64()
+		cell.DoubleValue = math.Float64frombits(v)
+		return err
+	case types.BoolType:
+		cell.BoolValue, err = d.readBool()
+		return err
+	case types.StringType:
+		cell.StringValue, err = d.readString()
+		return err
+	case types.TimestampType, types.DateType, types.TimeOfDayType:
+		cell.TimestampValue, err = d.readTime()
+		return err
+	case types.ObjectType:
+		v, err := d.readValue()
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: object value is not a map", ErrInvalidSnapshot)
+			}
+			cell.ObjectValue = obj
+		}
+		return nil
+	case types.NilType:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown data type %d", ErrInvalidSnapshot, dataType)
+}
+
+func (d *decoder) readValue() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagNil:
+		return nil, nil
+	case tagBool:
+		return d.readBool()
+	case tagString:
+		return d.readString()
+	case tagInt:
+		v, err := d.readVarint()
+		return int(v), err
+	case tagInt32:
+		v, err := d.readVarint()
+		return int32(v), err
+	case tagInt64:
+		return d.readVarint()
+	case tagFloat32:
+		v, err := d.readUint32()
+		return math.Float32frombits(v), err
+	case tagFloat64:
+		v, err := d.readUint64()
+		return math.Float64frombits(v), err
+	case tagTime:
+		return d.readTime()
+	case tagList:
+		n, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = d.readValue(); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case tagMap:
+		n, err := d.readCount()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			if m[k], err = d.readValue(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w: unknown value tag %d", ErrInvalidSnapshot, tag)
+}
+
+func (d *decoder) readTime() (time.Time, error) {
+	sec, err := d.readVarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsec, err := d.readUvarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, err := d.readVarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	locRef, err := d.readUvarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Unix(sec, int64(nsec))
+	switch locRef {
+	case locationUTC:
+		return t.UTC(), nil
+	case locationLocal:
+		return t.Local(), nil
+	}
+	if locRef-locationNamed >= uint64(len(d.names)) {
+		return time.Time{}, fmt.Errorf("%w: unknown location", ErrInvalidSnapshot)
+	}
+	name := d.names[locRef-locationNamed]
+	if loc := d.location(name); loc != nil {
+		if _, o := t.In(loc).Zone(); o == int(offset) {
+			return t.In(loc), nil
+		}
+	}
+	return t.In(d.fixedZone(name, int(offset))), nil
+}
+
+// location loads the named zone once - nil when the zone database doesn't know it
+func (d *decoder) location(name string) *time.Location {
+	if loc, ok := d.locations[name]; ok {
+		return loc
+	}
+	var loc *time.Location
+	// an empty name would load UTC
+	if len(name) > 0 {
+		if l, err := time.LoadLocation(name); err == nil {
+			loc = l
+		}
+	}
+	d.locations[name] = loc
+	return loc
+}
+
+func (d *decoder) fixedZone(name string, offset int) *time.Location {
+	key := fmt.Sprintf("%s/%d", name, offset)
+	if loc, ok := d.fixedZones[key]; ok {
+		return loc
+	}
+	loc := time.FixedZone(name, offset)
+	d.fixedZones[key] = loc
+	return loc
+}
+
+func (d *decoder) readName() (string, error) {
+	i, err := d.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if i >= uint64(len(d.names)) {
+		return "", fmt.Errorf("%w: unknown name", ErrInvalidSnapshot)
+	}
+	return d.names[i], nil
+}
+
+func (d *decoder) readString() (string, error) {
+	b, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	if d.zeroCopy {
+		return *(*string)(unsafe.Pointer(&b)), nil
+	}
+	return string(b), nil
+}
+
+func (d *decoder) readBytes() ([]byte, error) {
+	n, err := d.readCount()
+	if err != nil {
+		return nil, err
+	}
+	if n > len(d.data)-d.pos {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+var errTruncated = fmt.Errorf("%w: truncated data", ErrInvalidSnapshot)
+
+// readCount reads a length - it can't be more than the remaining bytes so corrupt data can't cause huge allocations
+func (d *decoder) readCount() (int, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) readBool() (bool, error) {
+	b, err := d.readByte()
+	return b == 1, err
+}
+
+func (d *decoder) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) readVarint() (int64, error) {
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) readUint32() (uint32, error) {
+	if len(d.data)-d.pos < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(d.data[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+func (d *decoder) readUint64() (uint64, error) {
+	if len(d.data)-d.pos < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(d.data[d.pos:])
+	d.pos += 8
+	return v, nil
+}
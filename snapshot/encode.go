@@ -0,0 +1,251 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+type encoder struct {
+	buf     []byte
+	scratch [binary.MaxVarintLen64]byte
+	names   map[string]uint64
+	// the name table is written before the body so the body is encoded separately
+	nameList []string
+}
+
+// Encode returns the snapshot of the dataset
+func Encode(dataset *types.DataSet) ([]byte, error) {
+	body := &encoder{names: make(map[string]uint64)}
+	if err := body.writeDataSet(dataset); err != nil {
+		return nil, err
+	}
+
+	e := &encoder{buf: make([]byte, 0, len(body.buf)+64)}
+	e.buf = append(e.buf, magic...)
+	e.writeUint16(Version)
+	e.writeUvarint(uint64(len(body.nameList)))
+	for _, n := range body.nameList {
+		e.writeString(n)
+	}
+	e.buf = append(e.buf, body.buf...)
+	e.writeUint32(crc32.Checksum(e.buf, crcTable))
+	return e.buf, nil
+}
+
+func Write(w io.Writer, dataset *types.DataSet) error {
+	data, err := Encode(dataset)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func WriteFile(filePath string, dataset *types.DataSet) error {
+	data, err := Encode(dataset)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+func (e *encoder) writeDataSet(dataset *types.DataSet) error {
+	headerNames := make([]string, 0, len(dataset.Headers))
+	for name, h := range dataset.Headers {
+		// nil headers carry nothing to restore
+		if h != nil {
+			headerNames = append(headerNames, name)
+		}
+	}
+	sort.Strings(headerNames)
+	e.writeUvarint(uint64(len(headerNames)))
+	for _, name := range headerNames {
+		h := dataset.Headers[name]
+		e.writeName(name)
+		e.buf = append(e.buf, byte(h.DataType))
+		e.writeVarint(h.Order)
+	}
+
+	e.writeUvarint(uint64(len(dataset.Rows)))
+	for _, r := range dataset.Rows {
+		if r.Key == nil {
+			e.buf = append(e.buf, 0)
+		} else {
+			e.buf = append(e.buf, 1)
+			e.writeString(*r.Key)
+		}
+		e.writeUvarint(uint64(len(r.Columns)))
+		for _, c := range r.Columns {
+			e.writeName(c.ColumnName)
+			if err := e.writeCell(c.CellValue); err != nil {
+				return fmt.Errorf("column “%s”: %w", c.ColumnName, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *encoder) writeCell(cell *types.CellValue) error {
+	if cell == nil {
+		e.buf = append(e.buf, byte(types.NilType))
+		return nil
+	}
+	e.buf = append(e.buf, byte(cell.DataType))
+	switch cell.DataType {
+	case types.IntType:
+		e.writeVarint(int64(cell.IntValue))
+	case types.LongType:
+		e.writeVarint(cell.LongValue)
+	case types.DoubleType:
+		e.writeUint64(math.Float64bits(cell.DoubleValue))
+	case types.BoolType:
+		e.writeBool(cell.BoolValue)
+	case types.StringType:
+		e.writeString(cell.StringValue)
+	case types.TimestampType, types.DateType, types.TimeOfDayType:
+		e.writeTime(cell.TimestampValue)
+	case types.ObjectType:
+		return e.writeValue(cell.ObjectValue)
+	case types.NilType:
+	default:
+		return fmt.Errorf("unknown data type %s", cell.DataType.String())
+	}
+	return nil
+}
+
+func (e *encoder) writeValue(v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		e.buf = append(e.buf, tagNil)
+	case bool:
+		e.buf = append(e.buf, tagBool)
+		e.writeBool(val)
+	case string:
+		e.buf = append(e.buf, tagString)
+		e.writeString(val)
+	case int:
+		e.buf = append(e.buf, tagInt)
+		e.writeVarint(int64(val))
+	case int32:
+		e.buf = append(e.buf, tagInt32)
+		e.writeVarint(int64(val))
+	case int64:
+		e.buf = append(e.buf, tagInt64)
+		e.writeVarint(val)
+	case float32:
+		e.buf = append(e.buf, tagFloat32)
+		e.writeUint32(math.Float32bits(val))
+	case float64:
+		e.buf = append(e.buf, tagFloat64)
+		e.writeUint64(math.Float64bits(val))
+	case time.Time:
+		e.buf = append(e.buf, tagTime)
+		e.writeTime(val)
+	case []interface{}:
+		e.buf = append(e.buf, tagList)
+		e.writeUvarint(uint64(len(val)))
+		for _, item := range val {
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if val == nil {
+			e.buf = append(e.buf, tagNil)
+			return nil
+		}
+		e.buf = append(e.buf, tagMap)
+		e.writeUvarint(uint64(len(val)))
+		// sorted keys keep the snapshot of the same dataset identical
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.writeString(k)
+			if err := e.writeValue(val[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedObjectValue, v)
+	}
+	return nil
+}
+
+// writeTime keeps the instant, the offset and the location of the time
+func (e *encoder) writeTime(t time.Time) {
+	e.writeVarint(t.Unix())
+	e.writeUvarint(uint64(t.Nanosecond()))
+	_, offset := t.Zone()
+	e.writeVarint(int64(offset))
+	switch loc := t.Location(); loc {
+	case time.UTC:
+		e.writeUvarint(locationUTC)
+	case time.Local:
+		e.writeUvarint(locationLocal)
+	default:
+		e.writeUvarint(locationNamed + e.nameIndex(loc.String()))
+	}
+}
+
+func (e *encoder) nameIndex(name string) uint64 {
+	if i, ok := e.names[name]; ok {
+		return i
+	}
+	i := uint64(len(e.nameList))
+	e.names[name] = i
+	e.nameList = append(e.nameList, name)
+	return i
+}
+
+func (e *encoder) writeName(name string) {
+	e.writeUvarint(e.nameIndex(name))
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeUvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) writeBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) writeUvarint(v uint64) {
+	n := binary.PutUvarint(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *encoder) writeVarint(v int64) {
+	n := binary.PutVarint(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *encoder) writeUint16(v uint16) {
+	binary.LittleEndian.PutUint16(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:2]...)
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	binary.LittleEndian.PutUint32(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:4]...)
+}
+
+func (e *encoder) writeUint64(v uint64) {
+	binary.LittleEndian.PutUint64(e.scratch[:], v)
+	e.buf = append(e.buf, e.scratch[:8]...)
+}
@@ -0,0 +1,48 @@
+// Package snapshot stores datasets in a compact binary format.
+//
+// A snapshot starts with the magic bytes and the format version, followed by the name table
+// (column and location names), the headers and the rows. It ends with a CRC-32 (Castagnoli) checksum
+// of everything before it.
+// Times keep their location and ObjectType values keep the exact Go types of their members
+package snapshot
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+const magic = "FLTS"
+
+// Version is the format version written by this package
+const Version uint16 = 1
+
+var (
+	ErrInvalidSnapshot        = errors.New("invalid snapshot")
+	ErrChecksumMismatch       = errors.New("snapshot checksum mismatch")
+	ErrUnsupportedVersion     = errors.New("unsupported snapshot version")
+	ErrUnsupportedObjectValue = errors.New("unsupported value in object column")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// value tags of the object members
+const (
+	tagNil byte = iota
+	tagBool
+	tagString
+	tagInt
+	tagInt32
+	tagInt64
+	tagFloat32
+	tagFloat64
+	tagTime
+	tagList
+	tagMap
+)
+
+// location references of the times - other locations point to the name table shifted by locationNamed
+const (
+	locationUTC uint64 = iota
+	locationLocal
+	locationNamed
+)
@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func buildSampleDataset(t *testing.T) *types.DataSet {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip("zone database isn't available")
+	}
+	key := "key-1"
+	ds := dataset.New([]*types.DataRow{
+		dataset.DataRow(&key,
+			dataset.StringColumn("Instrument", "ERIC B SS Equity"),
+			dataset.IntColumn("Quantity", -175000),
+			dataset.LongColumn("Notional", 3000000000),
+			dataset.DoubleColumn("Price", 12.5),
+			dataset.BoolColumn("Active", true),
+			dataset.TimestampColumn("Created", time.Date(2021, 3, 14, 15, 4, 5, 123, stockholm)),
+			&types.DataColumn{ColumnName: "Cut Off", CellValue: &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: time.Date(0, 1, 1, 17, 30, 0, 0, time.UTC)}},
+			&types.DataColumn{ColumnName: "Details", CellValue: &types.CellValue{DataType: types.ObjectType, ObjectValue: map[string]interface{}{
+				"desk":    "equities",
+				"limit":   int64(5),
+				"ratio":   0.25,
+				"tags":    []interface{}{"a", 1},
+				"updated": time.Date(2021, 6, 1, 8, 0, 0, 0, time.FixedZone("custom", 5400)),
+			}}},
+		),
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "AMZN US Equity"),
+			dataset.IntColumn("Quantity", 10),
+			dataset.NilColumn("Notional"),
+			dataset.DoubleColumn("Price", 3),
+			dataset.BoolColumn("Active", false),
+			dataset.TimestampColumn("Created", time.Date(2021, 11, 18, 9, 30, 0, 0, stockholm)),
+			dataset.NilColumn("Cut Off"),
+			&types.DataColumn{ColumnName: "Details", CellValue: &types.CellValue{DataType: types.ObjectType}},
+		),
+	})
+	ds.Headers = make(types.HeaderMap)
+	for i, c := range ds.Rows[0].Columns {
+		ds.Headers[c.ColumnName] = &types.Header{ColumnName: c.ColumnName, DataType: c.CellValue.DataType, Order: int64(i)}
+	}
+	return ds
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(t)
+
+	data, err := Encode(ds)
+	assert.Nil(err)
+	again, err := Encode(ds)
+	assert.Nil(err)
+	assert.Equal(data, again)
+
+	for _, zeroCopy := range []bool{false, true} {
+		decoded, err := Decode(data, &DecoderConfiguration{ZeroCopy: zeroCopy})
+		assert.Nil(err)
+		assert.Equal(ds.Headers, decoded.Headers)
+		assert.Equal(ds.Rows, decoded.Rows)
+		assert.Equal("Europe/Stockholm", decoded.Rows[0].GetColumn("Created").CellValue.TimestampValue.Location().String())
+	}
+}
+
+func TestEncodeNilHeaders(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(t)
+	ds.Headers["Missing"] = nil
+
+	data, err := Encode(ds)
+	assert.Nil(err)
+	decoded, err := Decode(data, nil)
+	assert.Nil(err)
+	assert.NotContains(decoded.Headers, "Missing")
+	assert.Equal(ds.Rows, decoded.Rows)
+}
+
+func TestReadAndWrite(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Write(&buf, ds))
+	decoded, err := Read(&buf, nil)
+	assert.Nil(err)
+	assert.Equal(ds.Rows, decoded.Rows)
+	assert.Equal([]*types.Header{ds.Headers["Instrument"], ds.Headers["Quantity"]}, decoded.OrderedHeaders()[:2])
+}
+
+func TestInvalidSnapshots(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset(t)
+	data, err := Encode(ds)
+	assert.Nil(err)
+
+	corrupt := append([]byte{}, data...)
+	corrupt[20] ^= 0xFF
+	_, err = Decode(corrupt, nil)
+	assert.True(errors.Is(err, ErrChecksumMismatch))
+
+	newer := append([]byte{}, data...)
+	newer[4] = 2
+	_, err = Decode(newer, nil)
+	assert.True(errors.Is(err, ErrUnsupportedVersion))
+
+	_, err = Decode(data[:len(data)/2], &DecoderConfiguration{SkipChecksum: true})
+	assert.True(errors.Is(err, ErrInvalidSnapshot))
+
+	_, err = Decode([]byte("not a snapshot"), nil)
+	assert.True(errors.Is(err, ErrInvalidSnapshot))
+
+	ds.Rows[1].GetColumn("Details").CellValue.ObjectValue = map[string]interface{}{"bad": struct{}{}}
+	_, err = Encode(ds)
+	assert.True(errors.Is(err, ErrUnsupportedObjectValue))
+}
@@ -0,0 +1,89 @@
+package sqlio
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+// Dialect holds the differences between the databases the sink needs to know about
+type Dialect struct {
+	Name string
+	// Placeholder returns the placeholder of the n-th parameter starting from 1
+	Placeholder     func(n int) string
+	QuoteIdentifier func(name string) string
+	ColumnTypes     map[types.CellDataType]string
+	// statements are split so they don't have more parameters than this
+	MaxParameters int
+}
+
+func questionMark(int) string {
+	return "?"
+}
+
+func quoteWith(q string) func(string) string {
+	return func(name string) string {
+		return q + strings.ReplaceAll(name, q, q+q) + q
+	}
+}
+
+var Postgres = &Dialect{
+	Name: "postgres",
+	Placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+	QuoteIdentifier: quoteWith(`"`),
+	ColumnTypes: map[types.CellDataType]string{
+		types.IntType:       "INTEGER",
+		types.LongType:      "BIGINT",
+		types.DoubleType:    "DOUBLE PRECISION",
+		types.BoolType:      "BOOLEAN",
+		types.StringType:    "TEXT",
+		types.DateType:      "DATE",
+		types.TimestampType: "TIMESTAMPTZ",
+		types.TimeOfDayType: "TIME",
+		types.ObjectType:    "JSONB",
+		types.NilType:       "TEXT",
+	},
+	MaxParameters: 65535,
+}
+
+var MySQL = &Dialect{
+	Name:            "mysql",
+	Placeholder:     questionMark,
+	QuoteIdentifier: quoteWith("`"),
+	ColumnTypes: map[types.CellDataType]string{
+		types.IntType:       "INT",
+		types.LongType:      "BIGINT",
+		types.DoubleType:    "DOUBLE",
+		types.BoolType:      "BOOLEAN",
+		types.StringType:    "TEXT",
+		types.DateType:      "DATE",
+		types.TimestampType: "DATETIME(6)",
+		types.TimeOfDayType: "TIME",
+		types.ObjectType:    "JSON",
+		types.NilType:       "TEXT",
+	},
+	MaxParameters: 65535,
+}
+
+var SQLite = &Dialect{
+	Name:            "sqlite",
+	Placeholder:     questionMark,
+	QuoteIdentifier: quoteWith(`"`),
+	// the declared types are what brings the types back when the table is read
+	ColumnTypes: map[types.CellDataType]string{
+		types.IntType:       "INT",
+		types.LongType:      "BIGINT",
+		types.DoubleType:    "DOUBLE",
+		types.BoolType:      "BOOLEAN",
+		types.StringType:    "TEXT",
+		types.DateType:      "DATE",
+		types.TimestampType: "TIMESTAMP",
+		types.TimeOfDayType: "TIME",
+		types.ObjectType:    "JSON",
+		types.NilType:       "TEXT",
+	},
+	MaxParameters: 999,
+}
@@ -0,0 +1,168 @@
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+// Execer is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type SinkConfiguration struct {
+	Dialect *Dialect
+	// rows per insert statement - it is lowered when the statement would have too many parameters
+	BatchSize int
+	// name of an extra column holding the row keys - row keys are not written when it is empty
+	KeyColumn string
+}
+
+func DefaultSinkConfiguration(dialect *Dialect) *SinkConfiguration {
+	return &SinkConfiguration{
+		Dialect:   dialect,
+		BatchSize: 500,
+	}
+}
+
+// CreateTableStatement builds the create table statement with a column for each header
+func CreateTableStatement(table string, dataset *types.DataSet, config *SinkConfiguration) (string, error) {
+	if config == nil || config.Dialect == nil {
+		return "", errors.New("sql dialect is required")
+	}
+	d := config.Dialect
+	columns := make([]string, 0, len(dataset.Headers)+1)
+	if len(config.KeyColumn) > 0 {
+		columns = append(columns, d.QuoteIdentifier(config.KeyColumn)+" "+d.ColumnTypes[types.StringType])
+	}
+	for _, h := range dataset.OrderedHeaders() {
+		columnType, ok := d.ColumnTypes[h.DataType]
+		if !ok {
+			return "", fmt.Errorf("column “%s” has a type %s that %s doesn't support", h.ColumnName, h.DataType.String(), d.Name)
+		}
+		columns = append(columns, d.QuoteIdentifier(h.ColumnName)+" "+columnType)
+	}
+	if len(columns) == 0 {
+		return "", errors.New("dataset doesn't have any columns")
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", d.QuoteIdentifier(table), strings.Join(columns, ", ")), nil
+}
+
+func CreateTable(ctx context.Context, db Execer, table string, dataset *types.DataSet, config *SinkConfiguration) error {
+	stmt, err := CreateTableStatement(table, dataset, config)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, stmt)
+	return err
+}
+
+// Insert writes all rows of the dataset with multi row insert statements.
+// Pass a *sql.Tx to make the whole insert atomic
+func Insert(ctx context.Context, db Execer, table string, dataset *types.DataSet, config *SinkConfiguration) (int64, error) {
+	if config == nil || config.Dialect == nil {
+		return 0, errors.New("sql dialect is required")
+	}
+	d := config.Dialect
+	headers := dataset.OrderedHeaders()
+	columns := make([]string, 0, len(headers)+1)
+	if len(config.KeyColumn) > 0 {
+		columns = append(columns, d.QuoteIdentifier(config.KeyColumn))
+	}
+	for _, h := range headers {
+		columns = append(columns, d.QuoteIdentifier(h.ColumnName))
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("dataset doesn't have any columns")
+	}
+
+	batchSize := config.BatchSize
+	if batchSize < 1 {
+		batchSize = 500
+	}
+	if d.MaxParameters > 0 && batchSize*len(columns) > d.MaxParameters {
+		batchSize = d.MaxParameters / len(columns)
+	}
+	if batchSize < 1 {
+		return 0, fmt.Errorf("%s can't insert %d columns in a single statement", d.Name, len(columns))
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", d.QuoteIdentifier(table), strings.Join(columns, ", "))
+	inserted := int64(0)
+	for start := 0; start < len(dataset.Rows); start += batchSize {
+		end := start + batchSize
+		if end > len(dataset.Rows) {
+			end = len(dataset.Rows)
+		}
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for ri, r := range dataset.Rows[start:end] {
+			if ri > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteByte('(')
+			if len(config.KeyColumn) > 0 {
+				if r.Key == nil {
+					args = append(args, nil)
+				} else {
+					args = append(args, *r.Key)
+				}
+			}
+			for _, h := range headers {
+				var cell *types.CellValue
+				if c := r.GetColumn(h.ColumnName); c != nil {
+					cell = c.CellValue
+				}
+				args = append(args, CellToSQLValue(cell))
+			}
+			for ci := range columns {
+				if ci > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.Placeholder(len(args) - len(columns) + ci + 1))
+			}
+			sb.WriteByte(')')
+		}
+		res, err := db.ExecContext(ctx, sb.String(), args...)
+		if err != nil {
+			return inserted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			// not all drivers report it
+			n = int64(end - start)
+		}
+		inserted += n
+	}
+	return inserted, nil
+}
+
+// CellToSQLValue returns the value passed to the driver for the cell
+func CellToSQLValue(cell *types.CellValue) interface{} {
+	if cell == nil {
+		return nil
+	}
+	switch cell.DataType {
+	case types.IntType:
+		return int64(cell.IntValue)
+	case types.LongType:
+		return cell.LongValue
+	case types.DoubleType:
+		return cell.DoubleValue
+	case types.BoolType:
+		return cell.BoolValue
+	case types.StringType:
+		return cell.StringValue
+	case types.DateType, types.TimestampType:
+		return cell.TimestampValue
+	case types.TimeOfDayType, types.ObjectType:
+		return cell.ToString()
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+)
+
+// Queryer is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type SourceConfiguration struct {
+	// types of these columns are not taken from the database
+	ColumnTypes map[string]types.CellDataType
+	// name of the result column holding the row keys - it isn't added to the dataset
+	KeyColumn string
+}
+
+// the type names of the common drivers - the size suffixes like VARCHAR(20) are removed before the lookup
+var databaseTypes = map[string]types.CellDataType{
+	"TINYINT":           types.IntType,
+	"SMALLINT":          types.IntType,
+	"MEDIUMINT":         types.IntType,
+	"INT":               types.IntType,
+	"INT2":              types.IntType,
+	"INT4":              types.IntType,
+	"SERIAL":            types.IntType,
+	"UNSIGNED TINYINT":  types.IntType,
+	"UNSIGNED SMALLINT": types.IntType,
+	// sqlite integers are always 64 bit
+	"INTEGER":           types.LongType,
+	"BIGINT":            types.LongType,
+	"INT8":              types.LongType,
+	"BIGSERIAL":         types.LongType,
+	"UNSIGNED INT":      types.LongType,
+	"UNSIGNED BIGINT":   types.LongType,
+	"REAL":              types.DoubleType,
+	"FLOAT":             types.DoubleType,
+	"FLOAT4":            types.DoubleType,
+	"FLOAT8":            types.DoubleType,
+	"DOUBLE":            types.DoubleType,
+	"DOUBLE PRECISION":  types.DoubleType,
+	"NUMERIC":           types.DoubleType,
+	"DECIMAL":           types.DoubleType,
+	"BOOL":              types.BoolType,
+	"BOOLEAN":           types.BoolType,
+	"DATE":              types.DateType,
+	"TIME":              types.TimeOfDayType,
+	"TIMETZ":            types.TimeOfDayType,
+	"DATETIME":          types.TimestampType,
+	"TIMESTAMP":         types.TimestampType,
+	"TIMESTAMPTZ":       types.TimestampType,
+	"JSON":              types.ObjectType,
+	"JSONB":             types.ObjectType,
+	"TEXT":              types.StringType,
+	"VARCHAR":           types.StringType,
+	"CHAR":              types.StringType,
+	"BPCHAR":            types.StringType,
+	"NVARCHAR":          types.StringType,
+	"NCHAR":             types.StringType,
+	"UUID":              types.StringType,
+	"CHARACTER VARYING": types.StringType,
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+var timeOfDayLayouts = []string{
+	"15:04:05.999999999",
+	"15:04:05.999999999Z07:00",
+	"15:04",
+}
+
+// Query runs the query and reads all of its rows
+func Query(ctx context.Context, db Queryer, config *SourceConfiguration, query string, args ...interface{}) (*types.DataSet, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return ReadRows(rows, config)
+}
+
+// ReadRows reads the remaining rows of the result - the rows are not closed
+func ReadRows(rows *sql.Rows, config *SourceConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = &SourceConfiguration{}
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndex := -1
+	columnNames := make([]string, len(columnTypes))
+	dataTypes := make([]types.CellDataType, len(columnTypes))
+	// columns without a known type get their type from the values
+	unknown := make([]bool, len(columnTypes))
+	seen := make(map[string]bool, len(columnTypes))
+	for i, ct := range columnTypes {
+		name := ct.Name()
+		if len(config.KeyColumn) > 0 && name == config.KeyColumn {
+			keyIndex = i
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column “%s” in query result", name)
+		}
+		seen[name] = true
+		columnNames[i] = name
+		if t, ok := config.ColumnTypes[name]; ok {
+			dataTypes[i] = t
+		} else if t, ok := databaseType(ct.DatabaseTypeName()); ok {
+			dataTypes[i] = t
+		} else {
+			unknown[i] = true
+		}
+	}
+
+	values := make([][]interface{}, 0)
+	for rows.Next() {
+		raw := make([]interface{}, len(columnTypes))
+		dest := make([]interface{}, len(columnTypes))
+		for i := range dest {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		values = append(values, raw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range columnTypes {
+		if unknown[i] {
+			dataTypes[i] = valueType(values, i)
+		}
+	}
+
+	headers := make(types.HeaderMap, len(columnTypes))
+	order := int64(0)
+	for i, name := range columnNames {
+		if i == keyIndex {
+			continue
+		}
+		headers[name] = &types.Header{ColumnName: name, DataType: dataTypes[i], Order: order}
+		order++
+	}
+
+	dataRows := make([]*types.DataRow, len(values))
+	for ri, raw := range values {
+		row := &types.DataRow{Columns: make([]*types.DataColumn, 0, len(headers))}
+		for i, v := range raw {
+			if i == keyIndex {
+				if v != nil {
+					key := toText(v)
+					row.Key = &key
+				}
+				continue
+			}
+			cell, err := toCell(v, dataTypes[i])
+			if err != nil {
+				return nil, fmt.Errorf("row %d column “%s”: %s", ri+1, columnNames[i], err.Error())
+			}
+			row.Columns = append(row.Columns, &types.DataColumn{ColumnName: columnNames[i], CellValue: cell})
+		}
+		dataRows[ri] = row
+	}
+	return &types.DataSet{Rows: dataRows, Headers: headers}, nil
+}
+
+func databaseType(name string) (types.CellDataType, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	t, ok := databaseTypes[name]
+	return t, ok
+}
+
+// valueType is used for expressions - the driver doesn't know the type of those
+func valueType(values [][]interface{}, col int) types.CellDataType {
+	dataType := types.NilType
+	for _, raw := range values {
+		var t types.CellDataType
+		switch raw[col].(type) {
+		case nil:
+			continue
+		case int64, int32, int:
+			t = types.LongType
+		case float64, float32:
+			t = types.DoubleType
+		case bool:
+			t = types.BoolType
+		case time.Time:
+			t = types.TimestampType
+		default:
+			t = types.StringType
+		}
+		if dataType == types.NilType {
+			dataType = t
+		} else if dataType != t {
+			if (dataType == types.LongType && t == types.DoubleType) || (dataType == types.DoubleType && t == types.LongType) {
+				dataType = types.DoubleType
+				continue
+			}
+			return types.StringType
+		}
+	}
+	if dataType == types.NilType {
+		return types.StringType
+	}
+	return dataType
+}
+
+func toCell(v interface{}, dataType types.CellDataType) (*types.CellValue, error) {
+	if v == nil {
+		return &types.CellValue{DataType: types.NilType}, nil
+	}
+	switch dataType {
+	case types.IntType:
+		i, err := toInt(v)
+		return &types.CellValue{DataType: types.IntType, IntValue: int32(i)}, err
+	case types.LongType:
+		i, err := toInt(v)
+		return &types.CellValue{DataType: types.LongType, LongValue: i}, err
+	case types.DoubleType:
+		f, err := toFloat(v)
+		return &types.CellValue{DataType: types.DoubleType, DoubleValue: f}, err
+	case types.BoolType:
+		b, err := toBool(v)
+		return &types.CellValue{DataType: types.BoolType, BoolValue: b}, err
+	case types.DateType:
+		t, err := toTime(v, timeLayouts)
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return &types.CellValue{DataType: types.DateType, TimestampValue: t}, err
+	case types.TimestampType:
+		t, err := toTime(v, timeLayouts)
+		return &types.CellValue{DataType: types.TimestampType, TimestampValue: t}, err
+	case types.TimeOfDayType:
+		t, err := toTime(v, timeOfDayLayouts)
+		t = time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		return &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: t}, err
+	case types.ObjectType:
+		obj := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(toText(v)), &obj); err != nil {
+			return nil, err
+		}
+		return &types.CellValue{DataType: types.ObjectType, ObjectValue: obj}, nil
+	}
+	return &types.CellValue{DataType: types.StringType, StringValue: toText(v)}, nil
+}
+
+func toText(v interface{}) string {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case int64:
+		return val, nil
+	case int32:
+		return int64(val), nil
+	case int:
+		return int64(val), nil
+	case float64:
+		return int64(val), nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return strconv.ParseInt(strings.TrimSpace(toText(v)), 10, 64)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case int32:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(toText(v)), 64)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case int64:
+		return val != 0, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(toText(v))) {
+	case "1", "t", "true", "y", "yes":
+		return true, nil
+	case "0", "f", "false", "n", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("“%s” is not a boolean", toText(v))
+}
+
+func toTime(v interface{}, layouts []string) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	text := strings.TrimSpace(toText(v))
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("“%s” is not a valid time", text)
+}
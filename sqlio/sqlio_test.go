@@ -0,0 +1,121 @@
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func buildSampleDataset() *types.DataSet {
+	key := "key-1"
+	ds := dataset.New([]*types.DataRow{
+		dataset.DataRow(&key,
+			dataset.StringColumn("Instrument", "ERIC B SS Equity"),
+			dataset.IntColumn("Quantity", 175000),
+			dataset.LongColumn("Notional", 3000000000),
+			dataset.DoubleColumn("Price", 12.5),
+			dataset.BoolColumn("Active", true),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 3, 14, 15, 4, 5, 0, time.UTC)),
+			&types.DataColumn{ColumnName: "Cut Off", CellValue: &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: time.Date(0, 1, 1, 17, 30, 0, 0, time.UTC)}},
+			&types.DataColumn{ColumnName: "Details", CellValue: &types.CellValue{DataType: types.ObjectType, ObjectValue: map[string]interface{}{"desk": "equities"}}},
+		),
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "AMZN US Equity"),
+			dataset.IntColumn("Quantity", -1500),
+			dataset.NilColumn("Notional"),
+			dataset.DoubleColumn("Price", 3),
+			dataset.BoolColumn("Active", false),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 11, 18, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 11, 18, 9, 30, 0, 0, time.UTC)),
+			dataset.NilColumn("Cut Off"),
+			dataset.NilColumn("Details"),
+		),
+	})
+	ds.Headers = make(types.HeaderMap)
+	for i, c := range ds.Rows[0].Columns {
+		ds.Headers[c.ColumnName] = &types.Header{ColumnName: c.ColumnName, DataType: c.CellValue.DataType, Order: int64(i)}
+	}
+	return ds
+}
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection of an in memory database is a different database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+	config := DefaultSinkConfiguration(Postgres)
+	config.KeyColumn = "row_key"
+	stmt, err := CreateTableStatement("positions", ds, config)
+	assert.Nil(err)
+	assert.Equal(`CREATE TABLE "positions" ("row_key" TEXT, "Instrument" TEXT, "Quantity" INTEGER, "Notional" BIGINT, "Price" DOUBLE PRECISION, "Active" BOOLEAN, "Trade Date" DATE, "Created" TIMESTAMPTZ, "Cut Off" TIME, "Details" JSONB)`, stmt)
+}
+
+func TestInsertAndQueryRoundTrip(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+	db := openDB(t)
+	ds := buildSampleDataset()
+
+	config := DefaultSinkConfiguration(SQLite)
+	config.KeyColumn = "row_key"
+	config.BatchSize = 1
+	assert.Nil(CreateTable(ctx, db, "positions", ds, config))
+	n, err := Insert(ctx, db, "positions", ds, config)
+	assert.Nil(err)
+	assert.Equal(int64(2), n)
+
+	read, err := Query(ctx, db, &SourceConfiguration{KeyColumn: "row_key"}, `SELECT * FROM positions ORDER BY "Quantity" DESC`)
+	assert.Nil(err)
+	assert.Len(read.Rows, 2)
+	for name, h := range ds.Headers {
+		assert.Equal(h.DataType, read.Headers[name].DataType, name)
+		assert.Equal(h.Order, read.Headers[name].Order, name)
+	}
+	assert.Equal("key-1", *read.Rows[0].Key)
+	assert.Nil(read.Rows[1].Key)
+	for i, r := range read.Rows {
+		for _, c := range r.Columns {
+			expected := ds.Rows[i].GetColumn(c.ColumnName).CellValue
+			switch expected.DataType {
+			case types.NilType:
+				assert.Equal(types.NilType, c.CellValue.DataType, c.ColumnName)
+			case types.ObjectType:
+				assert.Equal(expected.ObjectValue, c.CellValue.ObjectValue)
+			default:
+				assert.True(expected.Equals(c.CellValue), c.ColumnName)
+			}
+		}
+	}
+}
+
+func TestQueryExpressionTypes(t *testing.T) {
+	assert := assert2.New(t)
+	db := openDB(t)
+	ds, err := Query(context.Background(), db, &SourceConfiguration{ColumnTypes: map[string]types.CellDataType{"d": types.DateType}},
+		"SELECT 1 + 1 AS n, 2.5 * 2 AS f, 'x' AS s, NULL AS empty, '2021-03-14' AS d")
+	assert.Nil(err)
+	assert.Equal(types.LongType, ds.Headers["n"].DataType)
+	assert.Equal(types.DoubleType, ds.Headers["f"].DataType)
+	assert.Equal(types.StringType, ds.Headers["s"].DataType)
+	assert.Equal(types.StringType, ds.Headers["empty"].DataType)
+	assert.Equal(types.DateType, ds.Headers["d"].DataType)
+	assert.Equal(int64(2), ds.Rows[0].GetColumn("n").CellValue.LongValue)
+	assert.Equal(types.NilType, ds.Rows[0].GetColumn("empty").CellValue.DataType)
+	assert.Equal(time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC), ds.Rows[0].GetColumn("d").CellValue.TimestampValue)
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/require"
+)
+
+// TypedData converts the raw data with the headers on the first line and stops the test when it can't
+func TypedData(t *testing.T, rawData [][]string) *types.DataSet {
+	t.Helper()
+	data, err := filtrify.ConvertToTypedData(rawData, true, true, true)
+	require.NoError(t, err, "basic data conversion failed")
+	return data
+}
+
+// Step builds the transformation step of the operator with the configuration as JSON
+func Step(t *testing.T, operator types.TransformationOperatorType, conf interface{}) *types.TransformationStep {
+	t.Helper()
+	b, err := json.Marshal(conf)
+	require.NoError(t, err, "configuration can't be marshalled")
+	return &types.TransformationStep{
+		Operator:      operator,
+		Configuration: string(b),
+	}
+}
+
+// Transform converts the raw data and runs the steps on it
+func Transform(t *testing.T, rawData [][]string, otherSets map[string]*types.DataSet, steps ...*types.TransformationStep) (*types.DataSet, error) {
+	t.Helper()
+	return filtrify.Transform(TypedData(t, rawData), steps, otherSets)
+}
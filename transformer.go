@@ -41,6 +41,16 @@ func getOperator(step *types.TransformationStep) (types.TransformationOperator,
 		return &operator.ObjectifyOperator{}, nil
 	case types.CumulativeSum:
 		return &operator.CumulativeSumOperator{}, nil
+	case types.Query:
+		return &operator.QueryOperator{}, nil
+	case types.Window:
+		return &operator.WindowOperator{}, nil
+	case types.Pivot:
+		return &operator.PivotOperator{}, nil
+	case types.Unpivot:
+		return &operator.UnpivotOperator{}, nil
+	case types.Classify:
+		return &operator.ClassifyOperator{}, nil
 	default:
 		operator, ok := injectedOperators[step.Operator]
 		if !ok {
@@ -68,27 +78,46 @@ func validateStep(step *types.TransformationStep) error {
 	return nil
 }
 
-func processTransformation(dataset *types.DataSet, step *types.TransformationStep, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
+func processTransformation(dataset *types.DataSet, step *types.TransformationStep, otherSets map[string]*types.DataSet) (*types.DataSet, map[string]*types.DataSet, error) {
 	op, err := getOperator(step)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	state, err := op.ValidateConfiguration(step.Configuration)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if !state {
-		return nil, errors.New("invalid configuration")
+		return nil, nil, errors.New("invalid configuration")
+	}
+	// side datasets are emitted for empty datasets too - the next steps may use them
+	if sideSetOp, ok := op.(types.SideSetOperator); ok {
+		return sideSetOp.TransformWithSideSets(dataset, step.Configuration, otherSets)
 	}
 	if len(dataset.Rows) == 0 {
-		return dataset, nil
+		return dataset, nil, nil
 	}
 	transformedData, err := op.Transform(dataset, step.Configuration, otherSets)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return transformedData, nil
+	return transformedData, nil, nil
+}
+
+// withSideSets returns a copy of the other datasets with the side datasets of a step - the caller's map stays as it is
+func withSideSets(otherSets map[string]*types.DataSet, sideSets map[string]*types.DataSet) map[string]*types.DataSet {
+	if len(sideSets) == 0 {
+		return otherSets
+	}
+	sets := make(map[string]*types.DataSet, len(otherSets)+len(sideSets))
+	for name, set := range otherSets {
+		sets[name] = set
+	}
+	for name, set := range sideSets {
+		sets[name] = set
+	}
+	return sets
 }
 
 func InjectOperator(operatorCode types.TransformationOperatorType, operator types.TransformationOperator) {
@@ -102,17 +131,15 @@ func InjectSQLFunction(key string, op expr.CustomFunc) {
 func Transform(dataset *types.DataSet, transformations []*types.TransformationStep, otherSets map[string]*types.DataSet) (*types.DataSet, error) {
 	newData := dataset
 	var err error
-	if len(dataset.Rows) == 0 {
-		return newData, nil
-	}
-
 	for i, ts := range transformations {
-		newData, err = processTransformation(newData, ts, otherSets)
+		var sideSets map[string]*types.DataSet
+		newData, sideSets, err = processTransformation(newData, ts, otherSets)
 		// let's wrap this error message to give more details
 		if err != nil {
 			// wow we failed
 			return nil, fmt.Errorf("could not apply transformation: %s (%s operator, step %d)", err.Error(), ts.Operator.String(), i)
 		}
+		otherSets = withSideSets(otherSets, sideSets)
 	}
 
 	return newData, nil
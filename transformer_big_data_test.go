@@ -247,3 +247,78 @@ func TestBigDataAverageAggregate(t *testing.T) {
 	CheckAggrResults(t, aggregatedData, []string{"Region"}, []interface{}{"Middle East and North Africa"}, map[string]interface{}{"Units Sold": expectedFalseAggMarket})
 	CheckAggrResults(t, aggregatedData, []string{"Region"}, []interface{}{"Central America and the Caribbean"}, map[string]interface{}{"Units Sold": expectedTrueAggMarket})
 }
+
+// buildLookupSets creates a left set whose ids match the right set every second time
+func buildLookupSets(leftRows int, rightRows int) (*types.DataSet, *types.DataSet) {
+	left := &types.DataSet{Rows: make([]*types.DataRow, leftRows)}
+	for i := range left.Rows {
+		left.Rows[i] = &types.DataRow{
+			Columns: []*types.DataColumn{
+				{ColumnName: "ID", CellValue: &types.CellValue{DataType: types.LongType, LongValue: int64(i % (rightRows * 2))}},
+				{ColumnName: "Amount", CellValue: &types.CellValue{DataType: types.DoubleType, DoubleValue: float64(i)}},
+			},
+		}
+	}
+	right := &types.DataSet{Rows: make([]*types.DataRow, rightRows)}
+	for i := range right.Rows {
+		right.Rows[i] = &types.DataRow{
+			Columns: []*types.DataColumn{
+				// the ids are text on this side
+				{ColumnName: "ID", CellValue: &types.CellValue{DataType: types.StringType, StringValue: fmt.Sprintf("%d", i)}},
+				{ColumnName: "Name", CellValue: &types.CellValue{DataType: types.StringType, StringValue: fmt.Sprintf("name %d", i)}},
+			},
+		}
+	}
+	return left, right
+}
+
+func lookupStep() *types.TransformationStep {
+	conf := &operator.LookupConfiguration{
+		TargetDataset: "right",
+		Columns:       []*operator.JoinColumn{{Left: "ID", Right: "ID"}},
+		JoinType:      operator.LookupInnerJoin,
+	}
+	b1, err := json.Marshal(conf)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &types.TransformationStep{
+		Operator:      types.Lookup,
+		Configuration: string(b1),
+	}
+}
+
+func TestBigDataLookup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping testing in short mode")
+	}
+	left, right := buildLookupSets(200000, 50000)
+	start := time.Now()
+	joined, err := filtrify.Transform(left, []*types.TransformationStep{lookupStep()}, map[string]*types.DataSet{"right": right})
+	opTime := time.Since(start)
+	t.Log(fmt.Printf("Lookup took %s", opTime))
+	assert.NoError(t, err, "lookup operation failed")
+	assert.LessOrEqual(t, int64(opTime), int64(3*time.Second), "transform operation took longer than expected")
+
+	assert.Len(t, joined.Rows, 100000)
+	for _, r := range GetRandomResults(joined, 100) {
+		id := test.GetColumn(r, "ID").CellValue.LongValue
+		assert.Equal(t, fmt.Sprintf("name %d", id), test.GetColumn(r, "right.Name").CellValue.StringValue)
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for _, size := range [][]int{{1000, 500}, {10000, 5000}, {100000, 25000}} {
+		left, right := buildLookupSets(size[0], size[1])
+		otherSets := map[string]*types.DataSet{"right": right}
+		step := lookupStep()
+		b.Run(fmt.Sprintf("%dx%d", size[0], size[1]), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := filtrify.Transform(left, []*types.TransformationStep{step}, otherSets)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
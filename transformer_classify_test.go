@@ -0,0 +1,135 @@
+package filtrify_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/liminaab/filtrify/operator"
+	"github.com/liminaab/filtrify/test"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var classifyTestData [][]string = [][]string{
+	{"Instrument", "Amount", "Currency"},
+	{"ERIC B SS Equity", "-50", "SEK"},
+	{"T 0 12/31/21", "0", "USD"},
+	{"US Treasury 2031", "1000", "USD"},
+	{"ESZ1 Index", "1000000", "EUR"},
+	{"USD Cash", "", "USD"},
+}
+
+func classifyLabels(result *types.DataSet, column string) []string {
+	labels := make([]string, len(result.Rows))
+	for i, r := range result.Rows {
+		labels[i] = test.GetColumn(r, column).CellValue.ToString()
+	}
+	return labels
+}
+
+func TestClassifyRanges(t *testing.T) {
+	other := "Other"
+	result, err := test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Amount",
+		NewColumnName: "Band",
+		Rules: []*operator.ClassifyRule{
+			{Label: "Negative", To: "0"},
+			{Label: "Small", From: "0", To: "1000", ToInclusive: true},
+			{Label: "Large", From: "1000", FromExclusive: true},
+		},
+		ElseValue: &other,
+	}))
+	require.NoError(t, err, "classify operation failed")
+	assert.Equal(t, []string{"Negative", "Small", "Small", "Large", "Other"}, classifyLabels(result, "Band"))
+	assert.Equal(t, types.StringType, result.Headers["Band"].DataType)
+
+	// the first matching rule wins
+	result, err = test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Amount",
+		NewColumnName: "Band",
+		Rules: []*operator.ClassifyRule{
+			{Label: "Any", From: "-100"},
+			{Label: "Positive", From: "0"},
+		},
+	}))
+	require.NoError(t, err, "classify operation failed")
+	assert.Equal(t, []string{"Any", "Any", "Any", "Any", ""}, classifyLabels(result, "Band"))
+	assert.Equal(t, types.NilType, test.GetColumn(result.Rows[4], "Band").CellValue.DataType)
+}
+
+func TestClassifyPatterns(t *testing.T) {
+	cash := "Cash"
+	result, err := test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Instrument",
+		NewColumnName: "Asset class",
+		Rules: []*operator.ClassifyRule{
+			{Label: "Equity", Pattern: " equity$", CaseInsensitive: true},
+			{Label: "Bond", Pattern: `^T \d+ \d{2}/\d{2}/\d{2}$`},
+			{Label: "Bond", Prefix: "us treasury", CaseInsensitive: true},
+			{Label: "Derivative", Criteria: &operator.FilterCriteria{
+				Criteria: &operator.Criteria{FieldName: "Currency", Operator: "=", Value: "EUR"},
+			}},
+		},
+		ElseValue: &cash,
+	}))
+	require.NoError(t, err, "classify operation failed")
+	assert.Equal(t, []string{"Equity", "Bond", "Bond", "Derivative", "Cash"}, classifyLabels(result, "Asset class"))
+	// the rows keep their columns
+	assert.Len(t, result.Rows[0].Columns, 4)
+	assert.Len(t, result.Headers, 4)
+}
+
+func TestClassifyDates(t *testing.T) {
+	result, err := test.Transform(t, [][]string{
+		{"Trade date"},
+		{"2021-01-31"},
+		{"2021-03-31"},
+		{"2021-06-30"},
+	}, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Trade date",
+		NewColumnName: "Quarter",
+		Rules: []*operator.ClassifyRule{
+			{Label: "Q1", From: "2021-01-01", To: "2021-04-01"},
+			{Label: "Q2", From: "2021-04-01", To: "2021-07-01"},
+		},
+	}))
+	require.NoError(t, err, "classify operation failed")
+	assert.Equal(t, []string{"Q1", "Q1", "Q2"}, classifyLabels(result, "Quarter"))
+}
+
+func TestClassifyInvalidConfiguration(t *testing.T) {
+	op := &operator.ClassifyOperator{}
+	for _, conf := range []*operator.ClassifyConfiguration{
+		{ColumnName: "Amount", Rules: []*operator.ClassifyRule{{Label: "A", From: "0"}}},
+		{ColumnName: "Amount", NewColumnName: "Band"},
+		{ColumnName: "Amount", NewColumnName: "Band", Rules: []*operator.ClassifyRule{{Label: "A"}}},
+		{ColumnName: "Amount", NewColumnName: "Band", Rules: []*operator.ClassifyRule{{Label: "A", From: "0", Prefix: "1"}}},
+		{ColumnName: "Amount", NewColumnName: "Band", Rules: []*operator.ClassifyRule{{Label: "A", Pattern: "[0-"}}},
+		{NewColumnName: "Band", Rules: []*operator.ClassifyRule{{Label: "A", From: "0"}}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+
+	_, err := test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Amount",
+		NewColumnName: "Band",
+		Rules:         []*operator.ClassifyRule{{Label: "A", From: "zero"}},
+	}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Amount",
+		NewColumnName: "Currency",
+		Rules:         []*operator.ClassifyRule{{Label: "A", From: "0"}},
+	}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, classifyTestData, nil, test.Step(t, types.Classify, &operator.ClassifyConfiguration{
+		ColumnName:    "Price",
+		NewColumnName: "Band",
+		Rules:         []*operator.ClassifyRule{{Label: "A", From: "0"}},
+	}))
+	assert.Error(t, err)
+}
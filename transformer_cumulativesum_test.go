@@ -3,12 +3,16 @@ package filtrify_test
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"testing"
+
 	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/dataset"
 	"github.com/liminaab/filtrify/operator"
 	"github.com/liminaab/filtrify/test"
 	"github.com/liminaab/filtrify/types"
 	"github.com/stretchr/testify/assert"
-	"testing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBasicCumulativeSum(t *testing.T) {
@@ -93,3 +97,120 @@ func TestBasicCumulativeSumWithRowKey(t *testing.T) {
 	}
 
 }
+
+func TestPartitionedCumulativeSum(t *testing.T) {
+	newData, err := test.Transform(t, windowTestData, nil, test.Step(t, types.CumulativeSum, &operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Running",
+		PartitionBy:   []string{"Account"},
+		OrderBy:       []*operator.OrderConfiguration{{ColumnName: "Trade date", Ascending: true}},
+	}))
+	require.NoError(t, err, "cumulative sum operation failed")
+	assert.Equal(t, types.LongType, newData.Headers["Running"].DataType, "integer columns should stay integers")
+
+	// rows keep their order - totals follow the trade dates of every account
+	expected := []int64{60, 5, 10, 30, 5, 80}
+	for i, r := range newData.Rows {
+		newCol := test.GetColumn(r, "Running")
+		assert.Equal(t, types.LongType, newCol.CellValue.DataType)
+		assert.Equal(t, expected[i], newCol.CellValue.LongValue, "invalid running total in row %d", i)
+	}
+}
+
+func TestCumulativeProductMaxAndReset(t *testing.T) {
+	outputType := types.DoubleType
+	newData, err := test.Transform(t, windowTestData, nil, test.Step(t, types.CumulativeSum, &operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Product",
+		PartitionBy:   []string{"Account"},
+		OrderBy:       []*operator.OrderConfiguration{{ColumnName: "Trade date", Ascending: true}},
+		Aggregation:   operator.CumulativeProductAggregation,
+		OutputType:    &outputType,
+		NullHandling:  operator.CumulativeResetOnNull,
+	}))
+	require.NoError(t, err, "cumulative sum operation failed")
+	expected := []float64{6000, 5, 10, 200, 0, 120000}
+	for i, r := range newData.Rows {
+		newCol := test.GetColumn(r, "Product")
+		if i == 4 {
+			assert.Equal(t, types.NilType, newCol.CellValue.DataType, "null should reset the product")
+			continue
+		}
+		assert.Equal(t, types.DoubleType, newCol.CellValue.DataType)
+		assert.Equal(t, expected[i], newCol.CellValue.DoubleValue, "invalid running product in row %d", i)
+	}
+
+	newData, err = test.Transform(t, windowTestData, nil, test.Step(t, types.CumulativeSum, &operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Max",
+		OrderBy:       []*operator.OrderConfiguration{{ColumnName: "Trade date", Ascending: false}},
+		Aggregation:   operator.CumulativeMaxAggregation,
+	}))
+	require.NoError(t, err, "cumulative sum operation failed")
+	// latest dates first - only 2021-01-10 comes before the 30
+	expectedMax := []int64{30, 30, 30, 30, 30, 20}
+	for i, r := range newData.Rows {
+		assert.Equal(t, expectedMax[i], test.GetColumn(r, "Max").CellValue.LongValue, "invalid running max in row %d", i)
+	}
+}
+
+func TestCumulativeSumLargeIntegers(t *testing.T) {
+	run := func(conf *operator.CumulativeSumConfiguration, values ...int64) (*types.DataSet, error) {
+		rows := make([]*types.DataRow, len(values))
+		for i, v := range values {
+			rows[i] = dataset.DataRow(nil, dataset.LongColumn("Amount", v))
+		}
+		step := test.Step(t, types.CumulativeSum, conf)
+		return filtrify.Transform(dataset.New(rows), []*types.TransformationStep{step}, nil)
+	}
+
+	// 2^53 + 1 has no exact double
+	newData, err := run(&operator.CumulativeSumConfiguration{Column: "Amount", NewColumnName: "Running"}, 1<<53+1, 1, -2)
+	assert.NoError(t, err)
+	expected := []int64{1<<53 + 1, 1<<53 + 2, 1 << 53}
+	for i, r := range newData.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Running").CellValue.LongValue, "invalid running total in row %d", i)
+	}
+
+	_, err = run(&operator.CumulativeSumConfiguration{Column: "Missing", NewColumnName: "Running"}, 1, 2)
+	assert.Error(t, err, "the summed column doesn't exist")
+	_, err = run(&operator.CumulativeSumConfiguration{Column: "Amount", NewColumnName: "Running"}, math.MaxInt64, 1)
+	assert.Error(t, err, "the sum overflows a long")
+	_, err = run(&operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Running",
+		Aggregation:   operator.CumulativeProductAggregation,
+	}, 1<<32, 1<<31)
+	assert.Error(t, err, "the product overflows a long")
+
+	intType := types.IntType
+	newData, err = run(&operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Running",
+		OutputType:    &intType,
+	}, math.MaxInt32-1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(math.MaxInt32), test.GetColumn(newData.Rows[1], "Running").CellValue.IntValue)
+	_, err = run(&operator.CumulativeSumConfiguration{
+		Column:        "Amount",
+		NewColumnName: "Running",
+		OutputType:    &intType,
+	}, math.MaxInt32, 1)
+	assert.Error(t, err, "the sum doesn't fit in an integer")
+}
+
+func TestCumulativeSumInvalidConfiguration(t *testing.T) {
+	op := &operator.CumulativeSumOperator{}
+	stringType := types.StringType
+	for _, conf := range []*operator.CumulativeSumConfiguration{
+		{Column: "Amount"},
+		{Column: "Amount", NewColumnName: "x", Aggregation: "median"},
+		{Column: "Amount", NewColumnName: "x", NullHandling: "zero"},
+		{Column: "Amount", NewColumnName: "x", OutputType: &stringType},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
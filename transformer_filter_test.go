@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/filter"
 	"github.com/liminaab/filtrify/operator"
 	"github.com/liminaab/filtrify/test"
 	"github.com/liminaab/filtrify/types"
@@ -608,3 +609,96 @@ func TestBasicWhereCriteriaWithRowKey(t *testing.T) {
 	}
 
 }
+
+func filteredNames(t *testing.T, criteria *operator.FilterCriteria) []string {
+	ds, err := filtrify.ConvertToTypedData(basicData, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+	result, err := filter.Filter(ds, criteria)
+	if err != nil {
+		assert.NoError(t, err, "filter operation failed")
+		return nil
+	}
+	names := make([]string, len(result.Rows))
+	for i, r := range result.Rows {
+		names[i] = test.GetColumn(r, "name").CellValue.StringValue
+	}
+	return names
+}
+
+func TestTextWhereCriteria(t *testing.T) {
+	assert.Equal(t, []string{"bahadir", "boris"}, filteredNames(t, filter.StartsWith("name", "b")))
+	assert.Equal(t, []string{"andreas", "boris"}, filteredNames(t, filter.EndsWith("name", "s")))
+	assert.Equal(t, []string{"joakim"}, filteredNames(t, filter.EqIgnoreCase("name", "JoAkim")))
+	assert.Equal(t, []string{"andreas", "nisan", "joakim", "bahadir"}, filteredNames(t, filter.ContainsIgnoreCase("name", "A")))
+	assert.Equal(t, []string{"nisan", "boris"}, filteredNames(t, filter.Like("name", "%is%")))
+	assert.Equal(t, []string{"nisan"}, filteredNames(t, filter.Like("name", "_is%")))
+	assert.Equal(t, []string{"joakim", "ricky"}, filteredNames(t, filter.RegexMatch("name", `^[jr]\w+$`)))
+	// quotes are part of the value
+	assert.Empty(t, filteredNames(t, filter.StartsWith("name", "and'")))
+
+	// text operators need a text column
+	ds, err := filtrify.ConvertToTypedData(basicData, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+	_, err = filter.Filter(ds, filter.StartsWith("is_active", "t"))
+	assert.Error(t, err)
+	_, err = filter.Filter(ds, filter.RegexMatch("name", "[a-"))
+	assert.Error(t, err)
+}
+
+func TestListAndRangeWhereCriteria(t *testing.T) {
+	assert.Equal(t, []string{"andreas", "boris"}, filteredNames(t, filter.In("name", "boris", "andreas", "nobody")))
+	assert.Equal(t, []string{"nisan", "joakim", "bahadir", "ricky", "george"}, filteredNames(t, filter.NotIn("name", "boris", "andreas")))
+	assert.Equal(t, []string{"andreas", "nisan", "boris"}, filteredNames(t, filter.Between("weight", "0.08", "0.74")))
+	assert.Equal(t, []string{"nisan", "boris"}, filteredNames(t, filter.BetweenExclusive("weight", "0.08", "0.9")))
+	assert.Equal(t, []string{"andreas", "nisan", "joakim"}, filteredNames(t, filter.Between("created_at", "2010-01-01T00:00:00", "2020-01-01T00:00:00")))
+	assert.Equal(t, []string{"andreas", "joakim", "bahadir"}, filteredNames(t, filter.IsTrue("is_active")))
+	assert.Equal(t, []string{"nisan", "ricky", "george", "boris"}, filteredNames(t, filter.IsFalse("is_active")))
+
+	ds, err := filtrify.ConvertToTypedData(basicData, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+	_, err = filter.Filter(ds, &operator.FilterCriteria{
+		Criteria: &operator.Criteria{FieldName: "weight", Operator: "BETWEEN", Values: []string{"0.1"}},
+	})
+	assert.Error(t, err)
+	_, err = filter.Filter(ds, filter.In("name"))
+	assert.Error(t, err)
+	_, err = filter.Filter(ds, filter.IsTrue("name"))
+	assert.Error(t, err)
+}
+
+func TestQuotedWhereCriteria(t *testing.T) {
+	data := [][]string{{"name"}, {"O'Neil"}, {`a\b`}, {`back\`}, {"(a.b,axb)"}, {"a.b"}, {`say "hi"`}, {"plain"}}
+	ds, err := filtrify.ConvertToTypedData(data, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+	names := func(criteria *operator.FilterCriteria) []string {
+		result, err := filter.Filter(ds, criteria)
+		if err != nil {
+			assert.NoError(t, err, "filter operation failed")
+			return nil
+		}
+		names := make([]string, len(result.Rows))
+		for i, r := range result.Rows {
+			names[i] = test.GetColumn(r, "name").CellValue.StringValue
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"O'Neil", "plain"}, names(filter.In("name", "O'Neil", "plain")))
+	// a single value is never split like a list
+	assert.Equal(t, []string{"(a.b,axb)"}, names(filter.In("name", "(a.b,axb)")))
+	assert.Equal(t, []string{"(a.b,axb)"}, names(filter.EqIgnoreCase("name", "(A.B,AXB)")))
+	assert.Equal(t, []string{`a\b`, `back\`, "(a.b,axb)", "a.b", `say "hi"`}, names(filter.NotIn("name", "O'Neil", "plain")))
+	assert.Equal(t, []string{`a\b`, `back\`}, names(filter.ContainsIgnoreCase("name", `\`)))
+	assert.Equal(t, []string{`back\`}, names(filter.EndsWith("name", `k\`)))
+	assert.Equal(t, []string{`a\b`, `back\`}, names(filter.RegexMatch("name", `\\`)))
+	assert.Equal(t, []string{`a\b`}, names(filter.RegexMatch("name", `^\w\\\w$`)))
+	assert.Equal(t, []string{`say "hi"`}, names(filter.Like("name", `%"hi"`)))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/liminaab/filtrify/test"
 	"github.com/liminaab/filtrify/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func findFirstRowWithCriteria(dataset *types.DataSet, vals []*types.DataColumn) *types.DataRow {
@@ -404,3 +405,568 @@ func TestBasicLookupWithRowKey(t *testing.T) {
 		assert.NotNil(t, r.Key, "Key assignment failed on lookup operator")
 	}
 }
+
+func runLookup(t *testing.T, left *types.DataSet, right *types.DataSet, conf *operator.LookupConfiguration) (*types.DataSet, error) {
+	step := test.Step(t, types.Lookup, conf)
+	return filtrify.Transform(left, []*types.TransformationStep{step}, map[string]*types.DataSet{conf.TargetDataset: right})
+}
+
+func lookupTestSets(t *testing.T) (*types.DataSet, *types.DataSet) {
+	return test.TypedData(t, test.UATLookupTestDataFormatted), test.TypedData(t, test.UATLookupJoinTestDataFormatted)
+}
+
+func TestLookupJoinTypes(t *testing.T) {
+	byID := []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}}
+	for _, tc := range []struct {
+		joinType  string
+		leftRows  int
+		rightFrom int
+		rightTo   int
+		expected  int
+	}{
+		{operator.LookupInnerJoin, 6, 0, 3, 3},
+		{operator.LookupLeftJoin, 6, 0, 3, 6},
+		{operator.LookupSemiJoin, 6, 0, 3, 3},
+		{operator.LookupAntiJoin, 6, 0, 3, 3},
+		{operator.LookupRightJoin, 2, 0, 5, 5},
+		{operator.LookupFullJoin, 2, 1, 5, 5},
+	} {
+		left, right := lookupTestSets(t)
+		left.Rows = left.Rows[:tc.leftRows]
+		right.Rows = right.Rows[tc.rightFrom:tc.rightTo]
+		joined, err := runLookup(t, left, right, &operator.LookupConfiguration{
+			TargetDataset: "Instrument Data",
+			Columns:       byID,
+			JoinType:      tc.joinType,
+		})
+		require.NoError(t, err, "%s join failed", tc.joinType)
+		assert.Len(t, joined.Rows, tc.expected, "%s join returned an invalid number of rows", tc.joinType)
+
+		switch tc.joinType {
+		case operator.LookupSemiJoin, operator.LookupAntiJoin:
+			// no columns of the right dataset
+			assert.Len(t, joined.Headers, 5)
+		default:
+			assert.Len(t, joined.Headers, 10)
+		}
+	}
+
+	// an empty left side only keeps the right rows of right and full joins
+	for _, tc := range []struct {
+		joinType string
+		expected int
+	}{
+		{operator.LookupInnerJoin, 0},
+		{operator.LookupLeftJoin, 0},
+		{operator.LookupSemiJoin, 0},
+		{operator.LookupAntiJoin, 0},
+		{operator.LookupRightJoin, 2},
+		{operator.LookupFullJoin, 2},
+	} {
+		left, right := lookupTestSets(t)
+		left.Rows = left.Rows[:0]
+		right.Rows = right.Rows[:2]
+		joined, err := runLookup(t, left, right, &operator.LookupConfiguration{
+			TargetDataset: "Instrument Data",
+			Columns:       byID,
+			JoinType:      tc.joinType,
+		})
+		assert.NoError(t, err, "%s join failed", tc.joinType)
+		if !assert.Len(t, joined.Rows, tc.expected, "%s join returned an invalid number of rows", tc.joinType) || tc.expected == 0 {
+			continue
+		}
+		assert.Len(t, joined.Headers, 10)
+		// left columns keep the types of the left headers
+		assert.Equal(t, left.Headers["Instrument name"].DataType, joined.Headers["Instrument name"].DataType)
+		for i, r := range joined.Rows {
+			assert.Equal(t, test.GetColumn(right.Rows[i], "Instrument ID").CellValue.ToString(), test.GetColumn(r, "Instrument ID").CellValue.ToString())
+			assert.Equal(t, types.NilType, test.GetColumn(r, "Instrument name").CellValue.DataType)
+			assert.Equal(t, test.GetColumn(right.Rows[i], "Instrument name").CellValue.StringValue, test.GetColumn(r, "Instrument Data.Instrument name").CellValue.StringValue)
+		}
+	}
+
+	// right rows without a match keep their join values in the left columns
+	left, right := lookupTestSets(t)
+	left.Rows = left.Rows[:2]
+	right.Rows = right.Rows[1:]
+	joined, err := runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       byID,
+		JoinType:      operator.LookupFullJoin,
+	})
+	require.NoError(t, err, "full join failed")
+	first := joined.Rows[0]
+	assert.Equal(t, types.NilType, test.GetColumn(first, "Instrument Data.Region").CellValue.DataType)
+	last := joined.Rows[4]
+	assert.Nil(t, last.Key)
+	assert.Equal(t, int64(5), int64(test.GetColumn(last, "Instrument ID").CellValue.GetNumericVal()))
+	assert.Equal(t, types.NilType, test.GetColumn(last, "Instrument name").CellValue.DataType)
+	assert.Equal(t, "ERIC B LN Equity", test.GetColumn(last, "Instrument Data.Instrument name").CellValue.StringValue)
+}
+
+func TestLookupMultiMatchPolicies(t *testing.T) {
+	byCurrency := []*operator.JoinColumn{{Left: "Currency", Right: "Currency"}}
+
+	left, right := lookupTestSets(t)
+	joined, err := runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       byCurrency,
+		MultiMatch:    operator.LookupAllMatches,
+	})
+	require.NoError(t, err, "lookup failed")
+	// every USD row matches 3 instruments
+	assert.Len(t, joined.Rows, 14)
+	assert.Equal(t, "2", test.GetColumn(joined.Rows[1], "Instrument Data.Instrument ID").CellValue.ToString())
+	assert.Equal(t, "4", test.GetColumn(joined.Rows[3], "Instrument Data.Instrument ID").CellValue.ToString())
+
+	left, right = lookupTestSets(t)
+	joined, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       byCurrency,
+		MultiMatch:    operator.LookupLastMatch,
+	})
+	require.NoError(t, err, "lookup failed")
+	assert.Len(t, joined.Rows, 6)
+	assert.Equal(t, "4", test.GetColumn(joined.Rows[1], "Instrument Data.Instrument ID").CellValue.ToString())
+
+	left, right = lookupTestSets(t)
+	_, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       byCurrency,
+		MultiMatch:    operator.LookupErrorOnMultipleMatches,
+	})
+	assert.Error(t, err)
+
+	// semi and anti joins only check if there is a match
+	for _, tc := range []struct {
+		joinType string
+		expected int
+	}{{operator.LookupSemiJoin, 6}, {operator.LookupAntiJoin, 0}} {
+		left, right = lookupTestSets(t)
+		joined, err = runLookup(t, left, right, &operator.LookupConfiguration{
+			TargetDataset: "Instrument Data",
+			Columns:       byCurrency,
+			JoinType:      tc.joinType,
+			MultiMatch:    operator.LookupErrorOnMultipleMatches,
+		})
+		if assert.NoError(t, err, "%s join failed", tc.joinType) {
+			assert.Len(t, joined.Rows, tc.expected, "%s join returned an invalid number of rows", tc.joinType)
+		}
+	}
+
+	op := &operator.LookupOperator{}
+	for _, conf := range []*operator.LookupConfiguration{
+		{TargetDataset: "Instrument Data", Columns: byCurrency, JoinType: "cross"},
+		{TargetDataset: "Instrument Data", Columns: byCurrency, MultiMatch: "random"},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
+
+var lookupTradesTestData [][]string = [][]string{
+	{"Trade", "Instrument", "Price", "Trade date", "Currency"},
+	{"1", " ERIC  B ", "100.004", "2021-01-05", "SEK"},
+	{"2", "AMZN", "50", "2021-01-03", "USD"},
+	{"3", "amzn", "49.5", "2020-12-30", "USD"},
+}
+
+var lookupRatesTestData [][]string = [][]string{
+	{"Currency", "Rate date", "Rate"},
+	{"SEK", "2021-01-01", "0.11"},
+	{"SEK", "2021-01-04", "0.12"},
+	{"SEK", "2021-01-06", "0.13"},
+	{"USD", "2021-01-01", "1.0"},
+}
+
+var lookupInstrumentsTestData [][]string = [][]string{
+	{"Name", "Price", "Valid from", "Valid to"},
+	{"ERIC B", "100", "", ""},
+	{"amzn", "50", "2021-01-01", "2021-01-31"},
+}
+
+func runModeLookup(t *testing.T, rightData [][]string, conf *operator.LookupConfiguration) *types.DataSet {
+	left := test.TypedData(t, lookupTradesTestData)
+	right := test.TypedData(t, rightData)
+	conf.TargetDataset = "right"
+	conf.RemoveRightDatasetPrefix = true
+	joined, err := runLookup(t, left, right, conf)
+	require.NoError(t, err, "lookup failed")
+	return joined
+}
+
+func joinedTrades(joined *types.DataSet) []string {
+	trades := make([]string, len(joined.Rows))
+	for i, r := range joined.Rows {
+		trades[i] = test.GetColumn(r, "Trade").CellValue.ToString()
+	}
+	return trades
+}
+
+func TestLookupMatchModes(t *testing.T) {
+	joined := runModeLookup(t, lookupInstrumentsTestData, &operator.LookupConfiguration{
+		Columns:  []*operator.JoinColumn{{Left: "Instrument", Right: "Name", Mode: operator.CaseInsensitiveMatch}},
+		JoinType: operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"2", "3"}, joinedTrades(joined))
+
+	joined = runModeLookup(t, lookupInstrumentsTestData, &operator.LookupConfiguration{
+		Columns:  []*operator.JoinColumn{{Left: "Instrument", Right: "Name", Mode: operator.TrimmedMatch}},
+		JoinType: operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"1", "3"}, joinedTrades(joined))
+
+	joined = runModeLookup(t, lookupInstrumentsTestData, &operator.LookupConfiguration{
+		Columns:  []*operator.JoinColumn{{Left: "Price", Right: "Price", Mode: operator.NumericToleranceMatch, Tolerance: 0.01}},
+		JoinType: operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"1", "2"}, joinedTrades(joined))
+
+	// open bounds match everything
+	joined = runModeLookup(t, lookupInstrumentsTestData, &operator.LookupConfiguration{
+		Columns:    []*operator.JoinColumn{{Left: "Trade date", Right: "Valid from", RightEnd: "Valid to", Mode: operator.BetweenMatch}},
+		JoinType:   operator.LookupInnerJoin,
+		MultiMatch: operator.LookupAllMatches,
+	})
+	assert.Equal(t, []string{"1", "1", "2", "2", "3"}, joinedTrades(joined))
+
+	joined = runModeLookup(t, lookupInstrumentsTestData, &operator.LookupConfiguration{
+		Columns: []*operator.JoinColumn{
+			{Left: "Instrument", Right: "Name", Mode: operator.CaseInsensitiveMatch},
+			{Left: "Trade date", Right: "Valid from", RightEnd: "Valid to", Mode: operator.BetweenMatch},
+		},
+		JoinType: operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"2"}, joinedTrades(joined))
+}
+
+func TestLookupAsOf(t *testing.T) {
+	joined := runModeLookup(t, lookupRatesTestData, &operator.LookupConfiguration{
+		Columns: []*operator.JoinColumn{
+			{Left: "Currency", Right: "Currency"},
+			{Left: "Trade date", Right: "Rate date", Mode: operator.AsOfMatch},
+		},
+		RemoveRightMatchColumn: true,
+		MultiMatch:             operator.LookupErrorOnMultipleMatches,
+	})
+	assert.Len(t, joined.Rows, 3)
+	// the latest rate on or before the trade date
+	assert.Equal(t, 0.12, test.GetColumn(joined.Rows[0], "Rate").CellValue.GetNumericVal())
+	assert.Equal(t, 1.0, test.GetColumn(joined.Rows[1], "Rate").CellValue.GetNumericVal())
+	// no rate before the trade
+	assert.Equal(t, types.NilType, test.GetColumn(joined.Rows[2], "Rate").CellValue.DataType)
+
+	op := &operator.LookupOperator{}
+	for _, columns := range [][]*operator.JoinColumn{
+		{{Left: "Trade date", Right: "Rate date", Mode: "nearest"}},
+		{{Left: "Trade date", Right: "Rate date", Mode: operator.BetweenMatch}},
+		{{Left: "Price", Right: "Rate", Mode: operator.NumericToleranceMatch, Tolerance: -1}},
+		{{Left: "Trade date", Right: "Rate date", Mode: operator.AsOfMatch}, {Left: "Trade", Right: "Rate", Mode: operator.AsOfMatch}},
+	} {
+		b, _ := json.Marshal(&operator.LookupConfiguration{TargetDataset: "right", Columns: columns})
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
+
+func TestLookupColumnNaming(t *testing.T) {
+	byID := []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}}
+
+	left, right := lookupTestSets(t)
+	joined, err := runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:     "Instrument Data",
+		Columns:           byID,
+		SelectedColumns:   []string{"Region", "ISIN", "Currency"},
+		RightColumnPrefix: "inst_",
+		RightColumnSuffix: " (ref)",
+		RightColumnNames:  map[string]string{"Region": "Instrument region"},
+	})
+	require.NoError(t, err, "lookup failed")
+	// selected columns keep their order
+	names := make([]string, 0)
+	for _, c := range joined.Rows[0].Columns[len(left.Rows[0].Columns):] {
+		names = append(names, c.ColumnName)
+	}
+	assert.Equal(t, []string{"Instrument region", "inst_ISIN (ref)", "inst_Currency (ref)"}, names)
+	assert.Equal(t, "Europe", test.GetColumn(joined.Rows[0], "Instrument region").CellValue.StringValue)
+
+	// taken names get a counter by default
+	left, right = lookupTestSets(t)
+	joined, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:            "Instrument Data",
+		Columns:                  byID,
+		RemoveRightDatasetPrefix: true,
+		SelectedColumns:          []string{"Instrument ID", "Region"},
+	})
+	require.NoError(t, err, "lookup failed")
+	assert.Contains(t, joined.Headers, "Instrument ID_1")
+	assert.Contains(t, joined.Headers, "Region")
+
+	left, right = lookupTestSets(t)
+	_, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:            "Instrument Data",
+		Columns:                  byID,
+		RemoveRightDatasetPrefix: true,
+		OnCollision:              operator.LookupErrorOnCollision,
+	})
+	assert.Error(t, err)
+
+	// selected columns have to match exactly
+	left, right = lookupTestSets(t)
+	_, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:   "Instrument Data",
+		Columns:         byID,
+		SelectedColumns: []string{"region"},
+	})
+	assert.Error(t, err)
+
+	op := &operator.LookupOperator{}
+	for _, conf := range []*operator.LookupConfiguration{
+		{TargetDataset: "Instrument Data", Columns: byID, OnCollision: "skip"},
+		{TargetDataset: "Instrument Data", Columns: byID, RightColumnNames: map[string]string{"Region": ""}},
+		{TargetDataset: "Instrument Data", Columns: byID, SelectedColumns: []string{"Region", "Region"}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
+
+func TestLookupStatistics(t *testing.T) {
+	byID := []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}}
+	op := &operator.LookupOperator{}
+
+	left, right := lookupTestSets(t)
+	right.Rows = right.Rows[:3]
+	_, stats, err := op.TransformWithStatistics(left, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       byID,
+	}, map[string]*types.DataSet{"Instrument Data": right})
+	require.NoError(t, err, "lookup failed")
+	assert.Equal(t, operator.LookupStatistics{LeftRows: 6, RightRows: 3, Matched: 3, Unmatched: 3}, *stats)
+
+	left, right = lookupTestSets(t)
+	_, stats, err = op.TransformWithStatistics(left, &operator.LookupConfiguration{
+		TargetDataset: "Instrument Data",
+		Columns:       []*operator.JoinColumn{{Left: "Currency", Right: "Currency"}},
+		MultiMatch:    operator.LookupAllMatches,
+	}, map[string]*types.DataSet{"Instrument Data": right})
+	require.NoError(t, err, "lookup failed")
+	assert.Equal(t, operator.LookupStatistics{LeftRows: 6, RightRows: 5, Matched: 6, MultiMatched: 4}, *stats)
+}
+
+func TestLookupStatisticsDatasets(t *testing.T) {
+	left, right := lookupTestSets(t)
+	left.Rows = left.Rows[:2]
+	right.Rows = right.Rows[1:]
+	// the same key twice
+	right.Rows = append(right.Rows, right.Rows[3])
+	conf := &operator.LookupConfiguration{
+		TargetDataset:     "Instrument Data",
+		Columns:           []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}},
+		StatisticsDataset: "Lookup statistics",
+		UnmatchedDataset:  "Unmatched instruments",
+	}
+	b, _ := json.Marshal(conf)
+	otherSets := map[string]*types.DataSet{"Instrument Data": right}
+	op := &operator.LookupOperator{}
+	joined, sideSets, err := op.TransformWithSideSets(left, string(b), otherSets)
+	require.NoError(t, err, "lookup failed")
+	assert.Len(t, joined.Rows, 2)
+	assert.Len(t, otherSets, 1)
+	assert.Len(t, sideSets, 2)
+
+	statistics := sideSets["Lookup statistics"]
+	if assert.NotNil(t, statistics) {
+		assert.Len(t, statistics.Rows, 1)
+		assert.Equal(t, int64(2), test.GetColumn(statistics.Rows[0], "Left rows").CellValue.LongValue)
+		assert.Equal(t, int64(5), test.GetColumn(statistics.Rows[0], "Right rows").CellValue.LongValue)
+		assert.Equal(t, int64(1), test.GetColumn(statistics.Rows[0], "Matched").CellValue.LongValue)
+		assert.Equal(t, int64(1), test.GetColumn(statistics.Rows[0], "Unmatched").CellValue.LongValue)
+		assert.Equal(t, int64(4), test.GetColumn(statistics.Rows[0], "Unused right rows").CellValue.LongValue)
+		assert.Equal(t, types.LongType, statistics.Headers["Matched"].DataType)
+	}
+
+	unmatched := sideSets["Unmatched instruments"]
+	if assert.NotNil(t, unmatched) {
+		// left keys come first and the repeated right key is counted
+		expected := []struct {
+			side string
+			id   string
+			rows int64
+		}{{"left", "1", 1}, {"right", "3", 1}, {"right", "4", 1}, {"right", "5", 2}}
+		assert.Len(t, unmatched.Rows, len(expected))
+		for i, e := range expected {
+			r := unmatched.Rows[i]
+			assert.Equal(t, e.side, test.GetColumn(r, "Side").CellValue.StringValue)
+			assert.Equal(t, e.id, test.GetColumn(r, "Instrument ID").CellValue.ToString())
+			assert.Equal(t, e.rows, test.GetColumn(r, "Rows").CellValue.LongValue)
+		}
+	}
+
+	// the next step joins the unmatched keys while the caller's datasets stay as they are
+	left, _ = lookupTestSets(t)
+	left.Rows = left.Rows[:2]
+	joined, err = filtrify.Transform(left, []*types.TransformationStep{
+		test.Step(t, types.Lookup, conf),
+		test.Step(t, types.Lookup, &operator.LookupConfiguration{
+			TargetDataset:     "Unmatched instruments",
+			Columns:           []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID", Mode: operator.ExactMatch}},
+			SelectedColumns:   []string{"Side"},
+			RightColumnPrefix: "Unmatched ",
+		}),
+	}, otherSets)
+	require.NoError(t, err, "lookup failed")
+	assert.Len(t, otherSets, 1)
+	if assert.Len(t, joined.Rows, 2) {
+		assert.Equal(t, "left", test.GetColumn(joined.Rows[0], "Unmatched Side").CellValue.ToString())
+		assert.Equal(t, types.NilType, test.GetColumn(joined.Rows[1], "Unmatched Side").CellValue.DataType)
+	}
+
+	// side datasets don't replace the caller's datasets
+	otherSets["Lookup statistics"] = right
+	_, _, err = op.TransformWithSideSets(left, string(b), otherSets)
+	assert.Error(t, err)
+}
+
+func TestLookupStatisticsDatasetsOfEmptySet(t *testing.T) {
+	left, right := lookupTestSets(t)
+	left.Rows = left.Rows[:0]
+	first := test.Step(t, types.Lookup, &operator.LookupConfiguration{
+		TargetDataset:     "Instrument Data",
+		Columns:           []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}},
+		StatisticsDataset: "Lookup statistics",
+		UnmatchedDataset:  "Unmatched instruments",
+	})
+	otherSets := map[string]*types.DataSet{"Instrument Data": right}
+	next := func(target string, column string) []*types.TransformationStep {
+		return []*types.TransformationStep{first, test.Step(t, types.Lookup, &operator.LookupConfiguration{
+			TargetDataset: target,
+			Columns:       []*operator.JoinColumn{{Left: "Instrument ID", Right: column}},
+			JoinType:      operator.LookupRightJoin,
+		})}
+	}
+
+	// the side datasets of an empty left side reach the next steps
+	joined, err := filtrify.Transform(left, next("Lookup statistics", "Left rows"), otherSets)
+	assert.NoError(t, err)
+	if assert.NotNil(t, joined) && assert.Len(t, joined.Rows, 1) {
+		assert.Equal(t, int64(0), test.GetColumn(joined.Rows[0], "Lookup statistics.Left rows").CellValue.LongValue)
+		assert.Equal(t, int64(len(right.Rows)), test.GetColumn(joined.Rows[0], "Lookup statistics.Unused right rows").CellValue.LongValue)
+	}
+	joined, err = filtrify.Transform(left, next("Unmatched instruments", "Instrument ID"), otherSets)
+	assert.NoError(t, err)
+	if assert.NotNil(t, joined) && assert.Len(t, joined.Rows, len(right.Rows)) {
+		for _, r := range joined.Rows {
+			assert.Equal(t, "right", test.GetColumn(r, "Unmatched instruments.Side").CellValue.StringValue)
+		}
+	}
+	_, err = filtrify.Transform(left, next("Missing statistics", "Left rows"), otherSets)
+	assert.Error(t, err)
+}
+
+func TestLookupMatchQuality(t *testing.T) {
+	byID := []*operator.JoinColumn{{Left: "Instrument ID", Right: "Instrument ID"}}
+	limit := func(v float64) *float64 { return &v }
+
+	// 3 of 6 rows are unmatched
+	left, right := lookupTestSets(t)
+	right.Rows = right.Rows[:3]
+	_, err := runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:       "Instrument Data",
+		Columns:             byID,
+		MaxUnmatchedPercent: limit(50),
+	})
+	require.NoError(t, err, "lookup failed")
+	left, right = lookupTestSets(t)
+	right.Rows = right.Rows[:3]
+	_, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:       "Instrument Data",
+		Columns:             byID,
+		MaxUnmatchedPercent: limit(40),
+	})
+	assert.Error(t, err)
+
+	left, right = lookupTestSets(t)
+	_, err = runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:          "Instrument Data",
+		Columns:                []*operator.JoinColumn{{Left: "Currency", Right: "Currency"}},
+		MaxMultiMatchedPercent: limit(0),
+	})
+	assert.Error(t, err)
+
+	op := &operator.LookupOperator{}
+	for _, conf := range []*operator.LookupConfiguration{
+		{TargetDataset: "Instrument Data", Columns: byID, MaxUnmatchedPercent: limit(-1)},
+		{TargetDataset: "Instrument Data", Columns: byID, MaxMultiMatchedPercent: limit(101)},
+		{TargetDataset: "Instrument Data", Columns: byID, StatisticsDataset: "Instrument Data"},
+		{TargetDataset: "Instrument Data", Columns: byID, StatisticsDataset: "Stats", UnmatchedDataset: "Stats"},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
+
+func TestLookupFilters(t *testing.T) {
+	byCurrency := []*operator.JoinColumn{{Left: "Currency", Right: "Currency"}}
+
+	// the rates before the trade date, the last one wins
+	joined := runModeLookup(t, lookupRatesTestData, &operator.LookupConfiguration{
+		Columns:         byCurrency,
+		PostJoinFilters: []*operator.PostJoinFilter{{Left: "Trade date", Operator: ">=", Right: "Rate date"}},
+		MultiMatch:      operator.LookupLastMatch,
+	})
+	assert.Len(t, joined.Rows, 3)
+	assert.Equal(t, 0.12, test.GetColumn(joined.Rows[0], "Rate").CellValue.GetNumericVal())
+	assert.Equal(t, 1.0, test.GetColumn(joined.Rows[1], "Rate").CellValue.GetNumericVal())
+	assert.Equal(t, types.NilType, test.GetColumn(joined.Rows[2], "Rate").CellValue.DataType)
+
+	joined = runModeLookup(t, lookupRatesTestData, &operator.LookupConfiguration{
+		Columns: byCurrency,
+		RightFilters: []*operator.FilterCriteria{
+			{Criteria: &operator.Criteria{FieldName: "Rate", Operator: ">", Value: "0.115"}},
+			{Criteria: &operator.Criteria{FieldName: "Currency", Operator: "=", Value: "SEK"}},
+		},
+		JoinType: operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"1"}, joinedTrades(joined))
+	assert.Equal(t, 0.12, test.GetColumn(joined.Rows[0], "Rate").CellValue.GetNumericVal())
+
+	// json filters still work
+	filter, _ := json.Marshal(&operator.FilterConfiguration{
+		FilterCriteria: &operator.FilterCriteria{Criteria: &operator.Criteria{FieldName: "Currency", Operator: "=", Value: "USD"}},
+	})
+	joined = runModeLookup(t, lookupRatesTestData, &operator.LookupConfiguration{
+		Columns:              byCurrency,
+		TargetDatasetFilters: map[string]operator.LookupFilter{"usd": {Filter: string(filter)}},
+		JoinType:             operator.LookupInnerJoin,
+	})
+	assert.Equal(t, []string{"2", "3"}, joinedTrades(joined))
+
+	left := test.TypedData(t, lookupTradesTestData)
+	right := test.TypedData(t, lookupRatesTestData)
+	_, err := runLookup(t, left, right, &operator.LookupConfiguration{
+		TargetDataset:   "right",
+		Columns:         byCurrency,
+		PostJoinFilters: []*operator.PostJoinFilter{{Left: "Trade date", Operator: ">=", Right: "Valid from"}},
+	})
+	assert.Error(t, err)
+
+	op := &operator.LookupOperator{}
+	for _, conf := range []*operator.LookupConfiguration{
+		{TargetDataset: "right", Columns: byCurrency, RightFilters: []*operator.FilterCriteria{nil}},
+		{TargetDataset: "right", Columns: byCurrency, PostJoinFilters: []*operator.PostJoinFilter{{Left: "Trade date", Operator: "~", Right: "Rate date"}}},
+		{TargetDataset: "right", Columns: byCurrency, PostJoinFilters: []*operator.PostJoinFilter{{Left: "Trade date", Operator: ">"}}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+}
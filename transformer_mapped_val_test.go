@@ -10,6 +10,7 @@ import (
 	"github.com/liminaab/filtrify/test"
 	"github.com/liminaab/filtrify/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBasicMappedValue(t *testing.T) {
@@ -220,3 +221,153 @@ func TestBasicMappedValueWithRowKey(t *testing.T) {
 		assert.NotNil(t, r.Key, "Key assignment failed on mappedvalue operator")
 	}
 }
+
+var mappedValueBrokerTestData [][]string = [][]string{
+	{"Broker", "Desk", "Rating", "Since"},
+	{"1", "EQ", "5", "2020-01-02"},
+	{"1", "FI", "3", "2021-06-30"},
+	{"2", "EQ", "4", "2019-11-15"},
+}
+
+func TestMappedValueDefaults(t *testing.T) {
+	missing := "Unknown"
+	otherSets := map[string]*types.DataSet{}
+	mapped, err := test.Transform(t, test.UATMappedValueTestDataFormatted, otherSets, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetData:       test.UATMappedValueMapEmbeddedTestDataFormatted[1:],
+		MappedColumnName: "Broker ID",
+		NewColumnName:    "Broker",
+		DefaultValue:     &missing,
+	}))
+	require.NoError(t, err, "mapped value operation failed")
+	// the embedded table doesn't end up in the other datasets
+	assert.Len(t, otherSets, 0)
+	expected := []string{"Unknown", "UBS", "Unknown", "Unknown", "Unknown", "Credit Suisse"}
+	for i, r := range mapped.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Broker").CellValue.StringValue)
+	}
+
+	mapped, err = test.Transform(t, test.UATMappedValueTestDataFormatted, otherSets, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetData:        test.UATMappedValueMapEmbeddedTestDataFormatted[1:],
+		MappedColumnName:  "Broker ID",
+		NewColumnName:     "Broker",
+		KeepUnmappedValue: true,
+	}))
+	require.NoError(t, err, "mapped value operation failed")
+	expected = []string{"1", "UBS", "1", "1", "", "Credit Suisse"}
+	for i, r := range mapped.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Broker").CellValue.ToString())
+	}
+	assert.Equal(t, types.StringType, mapped.Headers["Broker"].DataType)
+
+	// kept values can't be converted to other types than text
+	double := types.DoubleType
+	_, err = test.Transform(t, test.UATMappedValueTestDataFormatted, otherSets, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetData:        test.UATMappedValueMapEmbeddedTestDataFormatted[1:],
+		MappedColumnName:  "Broker ID",
+		NewColumnName:     "Broker",
+		KeepUnmappedValue: true,
+		DataType:          &double,
+	}))
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "can't be converted")
+}
+
+func TestMappedValueEmptyMapTable(t *testing.T) {
+	otherSets := map[string]*types.DataSet{
+		"brokers": {Rows: []*types.DataRow{}, Headers: types.HeaderMap{}},
+	}
+	missing := "none"
+	mapped, err := test.Transform(t, test.UATMappedValueTestDataFormatted, otherSets, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetDataset:    "brokers",
+		MappedColumnName: "Broker ID",
+		NewColumnName:    "Broker",
+		DefaultValue:     &missing,
+	}))
+	require.NoError(t, err, "mapped value operation failed")
+	for _, r := range mapped.Rows {
+		assert.Equal(t, "none", test.GetColumn(r, "Broker").CellValue.StringValue)
+	}
+	assert.Equal(t, types.StringType, mapped.Headers["Broker"].DataType)
+
+	mapped, err = test.Transform(t, test.UATMappedValueTestDataFormatted, otherSets, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetDataset:     "brokers",
+		MappedColumnName:  "Broker ID",
+		NewColumnName:     "Broker",
+		KeepUnmappedValue: true,
+	}))
+	require.NoError(t, err, "mapped value operation failed")
+	expected := []string{"1", "2", "1", "1", "", "3"}
+	for i, r := range mapped.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Broker").CellValue.ToString())
+	}
+}
+
+func TestMappedValueCompositeKeys(t *testing.T) {
+	brokers := test.TypedData(t, mappedValueBrokerTestData)
+	data := test.TypedData(t, test.UATMappedValueTestDataFormatted)
+	for i, r := range data.Rows {
+		desk := "EQ"
+		if i == 2 {
+			desk = "FI"
+		}
+		r.Columns = append(r.Columns, &types.DataColumn{ColumnName: "Desk", CellValue: &types.CellValue{DataType: types.StringType, StringValue: desk}})
+	}
+	longType := types.LongType
+	conf := &operator.MappedValueConfiguration{
+		TargetDataset:     "Brokers",
+		MappedColumnNames: []string{"Broker ID", "Desk"},
+		KeyColumns:        []string{"Broker", "Desk"},
+		Outputs: []*operator.MappedValueOutput{
+			{ValueColumn: "Rating", NewColumnName: "Broker rating", DataType: &longType},
+			{ValueColumn: "Since", NewColumnName: "Broker since"},
+		},
+	}
+	mapped, err := filtrify.Transform(data, []*types.TransformationStep{test.Step(t, types.MappedValue, conf)}, map[string]*types.DataSet{"Brokers": brokers})
+	require.NoError(t, err, "mapped value operation failed")
+	assert.Len(t, mapped.Rows, len(data.Rows))
+	assert.Equal(t, types.LongType, mapped.Headers["Broker rating"].DataType)
+
+	ratings := []int64{5, 4, 3, 5}
+	for i, rating := range ratings {
+		cell := test.GetColumn(mapped.Rows[i], "Broker rating").CellValue
+		assert.Equal(t, types.LongType, cell.DataType)
+		assert.Equal(t, rating, cell.LongValue)
+	}
+	assert.Equal(t, "2021-06-30", test.GetColumn(mapped.Rows[2], "Broker since").CellValue.ToString()[:10])
+	// no broker 3 on the EQ desk
+	assert.Equal(t, types.NilType, test.GetColumn(mapped.Rows[5], "Broker rating").CellValue.DataType)
+	assert.Equal(t, types.NilType, test.GetColumn(mapped.Rows[5], "Broker since").CellValue.DataType)
+}
+
+func TestMappedValueInvalidConfiguration(t *testing.T) {
+	missing := "Unknown"
+	op := &operator.MappedValueOperator{}
+	for _, conf := range []*operator.MappedValueConfiguration{
+		{TargetDataset: "Brokers", MappedColumnNames: []string{"Broker ID", "Desk"}, NewColumnName: "Broker"},
+		{TargetDataset: "Brokers", MappedColumnName: "Broker ID", MappedColumnNames: []string{"Broker ID"}, NewColumnName: "Broker"},
+		{TargetDataset: "Brokers", MappedColumnName: "Broker ID", NewColumnName: "Broker", DefaultValue: &missing, KeepUnmappedValue: true},
+		{TargetDataset: "Brokers", MappedColumnName: "Broker ID", Outputs: []*operator.MappedValueOutput{{NewColumnName: "A"}, {NewColumnName: "A"}}},
+		{TargetDataset: "Brokers", MappedColumnName: "Broker ID", Outputs: []*operator.MappedValueOutput{{NewColumnName: "A"}}, DefaultValue: &missing},
+		{TargetData: [][]string{{"1", "a"}}, MappedColumnName: "Broker ID", NewColumnName: "Broker", TargetDataHeaders: []string{"Id", "Value"}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+
+	doubleType := types.DoubleType
+	_, err := test.Transform(t, test.UATMappedValueTestDataFormatted, nil, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetData:       test.UATMappedValueMapEmbeddedTestDataFormatted,
+		MappedColumnName: "Broker ID",
+		NewColumnName:    "Broker",
+		DataType:         &doubleType,
+	}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, test.UATMappedValueTestDataFormatted, nil, test.Step(t, types.MappedValue, &operator.MappedValueConfiguration{
+		TargetData:       test.UATMappedValueMapEmbeddedTestDataFormatted,
+		MappedColumnName: "Broker ID",
+		NewColumnName:    "Quantity",
+	}))
+	assert.Error(t, err)
+}
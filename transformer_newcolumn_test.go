@@ -1233,3 +1233,213 @@ func TestNewColumnLongMedianOperator(t *testing.T) {
 		assert.Equal(t, int64(3), newCol.CellValue.LongValue, "new column wasn't processed correctly")
 	}
 }
+
+func TestMultiStatementNewColumn(t *testing.T) {
+	ds, err := filtrify.ConvertToTypedData(test.UAT1TestDataFormatted, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+
+	// the last statement uses the columns of the previous ones
+	s1 := "`Market Value (Base)` * 2 AS `Double MV`, SUMX(`Market Value (Base)`) AS `Total MV`, `Instrument Type` AS `Type Copy`, `Double MV` / `Total MV` AS `Share`"
+
+	newColStep1 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s1 + "\"}",
+	}
+	for i := range ds.Rows {
+		key := fmt.Sprintf("row-%d", i)
+		ds.Rows[i].Key = &key
+	}
+
+	newData, err := filtrify.Transform(ds, []*types.TransformationStep{newColStep1}, nil)
+	if err != nil {
+		assert.NoError(t, err, "multi statement new column operation failed")
+	}
+	assert.Len(t, newData.Rows, len(ds.Rows), "multi statement new column operation failed. invalid number of rows")
+	for _, col := range []string{"Double MV", "Total MV", "Type Copy", "Share"} {
+		assert.Contains(t, newData.Headers, col, "new column header is missing")
+	}
+	for _, r := range newData.Rows {
+		assert.NotNil(t, r.Key, "Key assignment failed on newColumn operator")
+		mv := test.GetColumn(r, "Market Value (Base)")
+		if mv.CellValue.DataType == types.NilType {
+			continue
+		}
+		assert.Equal(t, mv.CellValue.DoubleValue*2, test.GetColumn(r, "Double MV").CellValue.DoubleValue, "new column wasn't calculated properly")
+		assert.Equal(t, float64(21255000.00), test.GetColumn(r, "Total MV").CellValue.DoubleValue, "new column wasn't calculated properly")
+		assert.InDelta(t, mv.CellValue.DoubleValue*2/21255000.00, test.GetColumn(r, "Share").CellValue.DoubleValue, 0.000001, "new column wasn't calculated properly")
+		assert.Equal(t, test.GetColumn(r, "Instrument Type").CellValue.StringValue, test.GetColumn(r, "Type Copy").CellValue.StringValue, "new column wasn't copied properly")
+	}
+
+	s2 := "`Quantity` AS `Copy`, `Instrument Type` AS `copy`"
+	newColStep2 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s2 + "\"}",
+	}
+	_, err = filtrify.Transform(ds, []*types.TransformationStep{newColStep2}, nil)
+	assert.Error(t, err, "columns with the same name should have failed")
+}
+
+func TestFloatLiteralNewColumn(t *testing.T) {
+	ds, err := filtrify.ConvertToTypedData(test.UAT1TestDataFormatted, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+
+	s1 := "1.5 * `Market Value (Base)` AS `Scaled`, (`Market Value (Base)` + 1) * 0.5 AS `Grouped`, 2.0 AS `Constant`, 1.5 * 2 AS `Folded`"
+
+	newColStep1 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s1 + "\"}",
+	}
+	newData, err := filtrify.Transform(ds, []*types.TransformationStep{newColStep1}, nil)
+	if err != nil {
+		assert.NoError(t, err, "float new column operation failed")
+	}
+	for _, col := range []string{"Scaled", "Grouped", "Constant", "Folded"} {
+		assert.Equal(t, types.DoubleType, newData.Headers[col].DataType, "float expressions should be doubles")
+	}
+	for _, r := range newData.Rows {
+		assert.Equal(t, float64(2), test.GetColumn(r, "Constant").CellValue.DoubleValue, "new column wasn't calculated properly")
+		assert.Equal(t, float64(3), test.GetColumn(r, "Folded").CellValue.DoubleValue, "new column wasn't calculated properly")
+		mv := test.GetColumn(r, "Market Value (Base)")
+		if mv.CellValue.DataType == types.NilType {
+			continue
+		}
+		assert.Equal(t, mv.CellValue.DoubleValue*1.5, test.GetColumn(r, "Scaled").CellValue.DoubleValue, "new column wasn't calculated properly")
+		assert.Equal(t, (mv.CellValue.DoubleValue+1)*0.5, test.GetColumn(r, "Grouped").CellValue.DoubleValue, "new column wasn't calculated properly")
+	}
+
+	// numbers in texts and names don't count, float arguments do
+	s2 := "CONCAT('v', '1.5') AS `Version`, LENGTH(`Instrument Type`) + 1 AS `Next 2.0`, plus(1, 2.0) AS `Sum`"
+	newColStep2 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s2 + "\"}",
+	}
+	newData, err = filtrify.Transform(ds, []*types.TransformationStep{newColStep2}, nil)
+	if err != nil {
+		assert.NoError(t, err, "float new column operation failed")
+	}
+	assert.Equal(t, types.StringType, newData.Headers["Version"].DataType)
+	assert.NotEqual(t, types.DoubleType, newData.Headers["Next 2.0"].DataType, "integer expressions should stay integers")
+	assert.Equal(t, types.DoubleType, newData.Headers["Sum"].DataType, "float expressions should be doubles")
+	assert.Equal(t, float64(3), test.GetColumn(newData.Rows[0], "Sum").CellValue.DoubleValue)
+}
+
+func TestNewColumnAliasParsing(t *testing.T) {
+	ds, err := filtrify.ConvertToTypedData(test.UAT1TestDataFormatted, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+
+	// lowercase as, an alias without backticks and an alias like text in a literal
+	s1 := "CONCAT(`Instrument Type`, ' AS `Fake`, x') as `Label`, `Instrument name` AS Name2, LEFT(`Instrument name`, 2) As `Short, Name`"
+
+	newColStep1 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s1 + "\"}",
+	}
+	newData, err := filtrify.Transform(ds, []*types.TransformationStep{newColStep1}, nil)
+	if err != nil {
+		assert.NoError(t, err, "new column operation failed")
+	}
+	assert.NotContains(t, newData.Headers, "Fake", "alias was found inside a literal")
+	for _, r := range newData.Rows {
+		instType := test.GetColumn(r, "Instrument Type").CellValue.StringValue
+		assert.Equal(t, instType+" AS `Fake`, x", test.GetColumn(r, "Label").CellValue.StringValue, "new column wasn't calculated properly")
+		name := test.GetColumn(r, "Instrument name").CellValue.StringValue
+		assert.Equal(t, name, test.GetColumn(r, "Name2").CellValue.StringValue, "new column wasn't copied properly")
+		assert.Equal(t, name[:2], test.GetColumn(r, "Short, Name").CellValue.StringValue, "new column wasn't calculated properly")
+	}
+
+	// columns starting with a sign or a number
+	s2 := "-`Quantity` AS `Short`, 2 * `Quantity` AS `Twice`, 7 AS `Seven`"
+	newColStep2 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s2 + "\"}",
+	}
+	newData, err = filtrify.Transform(ds, []*types.TransformationStep{newColStep2}, nil)
+	if err != nil {
+		assert.NoError(t, err, "new column operation failed")
+	}
+	for _, r := range newData.Rows {
+		quantity := test.GetColumn(r, "Quantity").CellValue
+		if quantity.DataType == types.NilType {
+			continue
+		}
+		assert.Equal(t, -quantity.DoubleValue, test.GetColumn(r, "Short").CellValue.GetNumericVal(), "new column wasn't calculated properly")
+		assert.Equal(t, 2*quantity.DoubleValue, test.GetColumn(r, "Twice").CellValue.GetNumericVal(), "new column wasn't calculated properly")
+		assert.Equal(t, float64(7), test.GetColumn(r, "Seven").CellValue.GetNumericVal(), "new column wasn't calculated properly")
+	}
+
+	for _, s := range []string{
+		"`Instrument name`",
+		"`Quantity` + 1 AS `Instrument Type`",
+		"`Quantity` + 1 AS `quantity`",
+		"LEFT(`Instrument name`, 2 AS `Broken`",
+		"`Quantity` + 1 AS `Next` FROM `other`",
+		"`Quantity` + 1 AS `Next` WHERE `Quantity` > 1",
+		"`Quantity` + 1 AS `Next",
+		"CONCAT(`Instrument name`, 'x) AS `Broken`",
+		"(`Quantity` + 1 AS `Broken`",
+		"`Quantity` + 1) AS `Broken`",
+		"`Quantity` + 1 AS `Next`,",
+		"`Quantity` + 1 AS `Next`,, `Quantity` AS `Copy`",
+	} {
+		step := &types.TransformationStep{
+			Operator:      types.NewColumn,
+			Configuration: "{\"statement\": \"" + s + "\"}",
+		}
+		_, err = filtrify.Transform(ds, []*types.TransformationStep{step}, nil)
+		if assert.Error(t, err, s) {
+			// errors are about the statement as it was written
+			assert.NotContains(t, err.Error(), "EVAL", s)
+		}
+	}
+}
+
+func TestPartitionedAggNewColumn(t *testing.T) {
+	ds, err := filtrify.ConvertToTypedData(test.UATAggregateTestDataFormatted, true, true, true)
+	if err != nil {
+		assert.NoError(t, err, "basic data conversion failed")
+	}
+
+	s1 := "SUMX(`Market Value (Base)`) AS `Total`, `Market Value (Base)` / `Total` AS `Share`"
+	columnCount := len(ds.Headers)
+
+	newColStep1 := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s1 + "\", \"partitionBy\": [\"Currency\", \"EU Sanction listed\"]}",
+	}
+	newData, err := filtrify.Transform(ds, []*types.TransformationStep{newColStep1}, nil)
+	if err != nil {
+		assert.NoError(t, err, "partitioned new column operation failed")
+	}
+	// all of the rows and columns are kept
+	assert.Len(t, newData.Rows, len(ds.Rows), "partitioned new column operation failed. invalid number of rows")
+	assert.Len(t, newData.Headers, columnCount+2, "partitioned new column operation failed. invalid number of columns")
+
+	expected := map[string]float64{
+		"ERIC B SS Equity": 2000000,
+		"AMZN US Equity":   6000000,
+		"T 0 12/31/21":     8255000,
+		"ESZ1":             8255000,
+		"USD Cash":         5000000,
+	}
+	for _, r := range newData.Rows {
+		name := test.GetColumn(r, "Instrument name").CellValue.StringValue
+		total := test.GetColumn(r, "Total")
+		assert.NotNil(t, total, "test column was not found")
+		assert.Equal(t, expected[name], total.CellValue.DoubleValue, "new column wasn't calculated properly for "+name)
+		mv := test.GetColumn(r, "Market Value (Base)").CellValue.DoubleValue
+		assert.InDelta(t, mv/expected[name], test.GetColumn(r, "Share").CellValue.DoubleValue, 0.000001, "new column wasn't calculated properly for "+name)
+	}
+
+	badStep := &types.TransformationStep{
+		Operator:      types.NewColumn,
+		Configuration: "{\"statement\": \"" + s1 + "\", \"partitionBy\": [\"Region\"]}",
+	}
+	_, err = filtrify.Transform(ds, []*types.TransformationStep{badStep}, nil)
+	assert.Error(t, err, "partition column doesn't exist")
+}
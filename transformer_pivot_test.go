@@ -0,0 +1,117 @@
+package filtrify_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/liminaab/filtrify/operator"
+	"github.com/liminaab/filtrify/test"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pivotTestData [][]string = [][]string{
+	{"Account", "Month", "Value"},
+	{"B", "2021-02", "7.5"},
+	{"A", "2021-01", "10.0"},
+	{"A", "2021-02", "20.5"},
+	{"A", "2021-01", "5.0"},
+	{"B", "2021-03", "1.0"},
+}
+
+func TestPivot(t *testing.T) {
+	result, err := test.Transform(t, pivotTestData, nil, test.Step(t, types.Pivot, &operator.PivotConfiguration{
+		RowKeys:     []string{"Account"},
+		PivotColumn: "Month",
+		ValueColumn: "Value",
+	}))
+	require.NoError(t, err, "pivot operation failed")
+	assert.Len(t, result.Rows, 2)
+	assert.Len(t, result.Headers, 4)
+	assert.Equal(t, types.DoubleType, result.Headers["2021-01"].DataType)
+
+	// rows follow the first appearance of the accounts and columns follow the months
+	b := result.Rows[0]
+	assert.Equal(t, "B", test.GetColumn(b, "Account").CellValue.StringValue)
+	assert.Equal(t, types.NilType, test.GetColumn(b, "2021-01").CellValue.DataType)
+	assert.Equal(t, 7.5, test.GetColumn(b, "2021-02").CellValue.DoubleValue)
+	names := make([]string, 0)
+	for _, c := range b.Columns {
+		names = append(names, c.ColumnName)
+	}
+	assert.Equal(t, []string{"Account", "2021-01", "2021-02", "2021-03"}, names)
+
+	a := result.Rows[1]
+	assert.Equal(t, 15.0, test.GetColumn(a, "2021-01").CellValue.DoubleValue)
+	assert.Equal(t, 20.5, test.GetColumn(a, "2021-02").CellValue.DoubleValue)
+}
+
+func TestPivotFixedValuesAndTemplate(t *testing.T) {
+	result, err := test.Transform(t, pivotTestData, nil, test.Step(t, types.Pivot, &operator.PivotConfiguration{
+		RowKeys:            []string{"Account"},
+		PivotColumn:        "Month",
+		ValueColumn:        "Value",
+		Method:             "average",
+		ColumnNameTemplate: "{column} {value}",
+		PivotValues:        []string{"2021-01", "2021-04"},
+	}))
+	require.NoError(t, err, "pivot operation failed")
+	assert.Len(t, result.Headers, 3)
+	assert.Contains(t, result.Headers, "Value 2021-04")
+	assert.NotContains(t, result.Headers, "Value 2021-02")
+	// the fixed column without values is still typed
+	assert.Equal(t, types.DoubleType, result.Headers["Value 2021-04"].DataType)
+	assert.Equal(t, 7.5, test.GetColumn(result.Rows[1], "Value 2021-01").CellValue.DoubleValue)
+}
+
+func TestPivotInvalidConfiguration(t *testing.T) {
+	op := &operator.PivotOperator{}
+	for _, conf := range []*operator.PivotConfiguration{
+		{ValueColumn: "Value"},
+		{PivotColumn: "Month"},
+		{PivotColumn: "Month", ValueColumn: "Value", Method: "concat"},
+		{RowKeys: []string{"Month"}, PivotColumn: "Month", ValueColumn: "Value"},
+		{PivotColumn: "Month", ValueColumn: "Value", PivotValues: []string{"a", "a"}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+
+	_, err := test.Transform(t, pivotTestData, nil, test.Step(t, types.Pivot, &operator.PivotConfiguration{PivotColumn: "Month", ValueColumn: "Missing"}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, pivotTestData, nil, test.Step(t, types.Pivot, &operator.PivotConfiguration{RowKeys: []string{"Account"}, PivotColumn: "Month", ValueColumn: "Value", ColumnNameTemplate: "Total"}))
+	assert.Error(t, err)
+}
+
+func TestPivotNumberRowKeys(t *testing.T) {
+	result, err := test.Transform(t, [][]string{
+		{"Portfolio", "Month", "Value"},
+		{"1", "2021-01", "1.0"},
+		{"2", "2021-01", "2.0"},
+		{"1", "2021-02", "3.0"},
+		{"", "2021-02", "4.0"},
+	}, nil, test.Step(t, types.Pivot, &operator.PivotConfiguration{
+		RowKeys:     []string{"Portfolio"},
+		PivotColumn: "Month",
+		ValueColumn: "Value",
+	}))
+	require.NoError(t, err, "pivot operation failed")
+
+	// the aggregated numbers must find the rows of their keys whatever their numeric type
+	assert.Len(t, result.Rows, 3)
+	expected := [][2]interface{}{{1.0, 3.0}, {2.0, nil}, {nil, 4.0}}
+	for i, r := range result.Rows {
+		assert.NotNil(t, r)
+		for j, name := range []string{"2021-01", "2021-02"} {
+			c := test.GetColumn(r, name).CellValue
+			if expected[i][j] == nil {
+				assert.Equal(t, types.NilType, c.DataType)
+			} else {
+				assert.Equal(t, expected[i][j], c.DoubleValue)
+			}
+		}
+	}
+}
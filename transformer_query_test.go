@@ -0,0 +1,211 @@
+package filtrify_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/operator"
+	"github.com/liminaab/filtrify/test"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryStep(t *testing.T, query string) *types.TransformationStep {
+	return test.Step(t, types.Query, &operator.QueryConfiguration{Query: query})
+}
+
+func instrumentsTable(t *testing.T) map[string]*types.DataSet {
+	return map[string]*types.DataSet{"Instruments": test.TypedData(t, test.UATLookupJoinTestDataFormatted)}
+}
+
+func TestQueryWhereOrderLimit(t *testing.T) {
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT `Instrument name`, Quantity FROM ext WHERE Currency = 'USD' ORDER BY Quantity DESC LIMIT 3"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 3)
+	assert.Len(t, result.Headers, 2)
+	assert.Equal(t, types.StringType, result.Headers["Instrument name"].DataType)
+
+	expected := []string{"T 0 12/31/21", "USD Cash", "AMZN US Equity"}
+	for i, r := range result.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Instrument name").CellValue.StringValue)
+	}
+	// numbers are ordered by value, not as text
+	assert.Greater(t, test.GetColumn(result.Rows[1], "Quantity").CellValue.DoubleValue, test.GetColumn(result.Rows[2], "Quantity").CellValue.DoubleValue)
+}
+
+func TestQueryGroupByHaving(t *testing.T) {
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT Currency, count(*) AS cnt, sum(Quantity) AS total FROM ext GROUP BY Currency HAVING count(*) > 1"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 1)
+	assert.Equal(t, "USD", test.GetColumn(result.Rows[0], "Currency").CellValue.StringValue)
+	assert.Equal(t, 4.0, test.GetColumn(result.Rows[0], "cnt").CellValue.DoubleValue)
+}
+
+func TestQueryDistinct(t *testing.T) {
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT DISTINCT Currency FROM ext ORDER BY Currency"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 2)
+	assert.Equal(t, "SEK", test.GetColumn(result.Rows[0], "Currency").CellValue.StringValue)
+	assert.Equal(t, "USD", test.GetColumn(result.Rows[1], "Currency").CellValue.StringValue)
+
+	// the limit counts the distinct rows
+	result, err = test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT DISTINCT Currency FROM ext ORDER BY 1 DESC LIMIT 1"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 1)
+	assert.Equal(t, "USD", test.GetColumn(result.Rows[0], "Currency").CellValue.StringValue)
+}
+
+func TestQueryInnerJoin(t *testing.T) {
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, instrumentsTable(t), queryStep(t, "SELECT e.`Instrument name`, e.Quantity, i.Region, i.ISIN FROM ext AS e INNER JOIN instruments AS i ON e.`Instrument name` = i.`Instrument name` WHERE i.Region = 'Americas' ORDER BY e.Quantity"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 3)
+	assert.Len(t, result.Headers, 4)
+	assert.Equal(t, types.DoubleType, result.Headers["Quantity"].DataType)
+
+	expected := []string{"ESZ1", "AMZN US Equity", "T 0 12/31/21"}
+	for i, r := range result.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Instrument name").CellValue.StringValue)
+		assert.Equal(t, "Americas", test.GetColumn(r, "Region").CellValue.StringValue)
+	}
+	assert.Equal(t, "US0231351067", test.GetColumn(result.Rows[1], "ISIN").CellValue.StringValue)
+}
+
+func TestQueryLeftJoin(t *testing.T) {
+	otherSets := instrumentsTable(t)
+	otherSets["Instruments"].Rows = otherSets["Instruments"].Rows[:2]
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, otherSets, queryStep(t, "SELECT e.`Instrument name`, i.Region FROM ext e LEFT JOIN instruments i ON e.`Instrument name` = i.`Instrument name`"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 5)
+
+	matched := 0
+	for _, r := range result.Rows {
+		if test.GetColumn(r, "Region").CellValue.DataType != types.NilType {
+			matched++
+		}
+	}
+	assert.Equal(t, 2, matched)
+}
+
+func TestQueryOtherTableName(t *testing.T) {
+	lookup := instrumentsTable(t)["Instruments"]
+	otherSets := map[string]*types.DataSet{"Listed Instruments": lookup}
+	// table names are matched case insensitively like in joins
+	for _, from := range []string{"`Listed Instruments`", "`listed instruments`", "`LISTED INSTRUMENTS` AS `Listed Instruments`"} {
+		result, err := test.Transform(t, test.UATAggregateTestDataFormatted, otherSets, queryStep(t, "SELECT `Instrument name`, Region FROM "+from+" WHERE Region = 'Americas'"))
+		if assert.NoError(t, err, from) {
+			assert.Len(t, result.Rows, 3, from)
+			assert.Len(t, result.Headers, 2, from)
+		}
+	}
+}
+
+func TestQueryJoinColumnNames(t *testing.T) {
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, instrumentsTable(t), queryStep(t, "SELECT * FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name`"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 4)
+	// columns of both tables keep their table alias
+	assert.Contains(t, result.Headers, "e.Currency")
+	assert.Contains(t, result.Headers, "i.Currency")
+	assert.Contains(t, result.Headers, "Region")
+
+	_, err = test.Transform(t, test.UATAggregateTestDataFormatted, instrumentsTable(t), queryStep(t, "SELECT Currency FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name`"))
+	assert.Error(t, err)
+
+	result, err = test.Transform(t, test.UATAggregateTestDataFormatted, instrumentsTable(t), queryStep(t, "SELECT i.Region, count(*) AS cnt FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` GROUP BY i.Region ORDER BY cnt DESC"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 2)
+	assert.Equal(t, "Americas", test.GetColumn(result.Rows[0], "Region").CellValue.StringValue)
+
+	// the aggregate of HAVING doesn't need to be selected
+	result, err = test.Transform(t, test.UATAggregateTestDataFormatted, instrumentsTable(t), queryStep(t, "SELECT i.Region FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` GROUP BY i.Region HAVING sum(e.Quantity) > 1000000"))
+	require.NoError(t, err)
+	assert.Len(t, result.Rows, 1)
+	assert.Len(t, result.Rows[0].Columns, 1)
+	assert.Equal(t, "Americas", test.GetColumn(result.Rows[0], "Region").CellValue.StringValue)
+}
+
+func TestQueryJoinKeyTypes(t *testing.T) {
+	left := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil, dataset.LongColumn("ID", 1), dataset.StringColumn("Name", "one")),
+		dataset.DataRow(nil, dataset.LongColumn("ID", 2), dataset.StringColumn("Name", "two")),
+		dataset.DataRow(nil, dataset.LongColumn("ID", 3), dataset.StringColumn("Name", "three")),
+	})
+	right := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil, dataset.StringColumn("Code", "01"), dataset.StringColumn("Label", "first")),
+		dataset.DataRow(nil, dataset.StringColumn("Code", " 2.0"), dataset.StringColumn("Label", "second")),
+		dataset.DataRow(nil, dataset.StringColumn("Code", "x"), dataset.StringColumn("Label", "none")),
+	})
+	op := &operator.QueryOperator{}
+	result, err := op.TransformWithConfig(left, &operator.QueryConfiguration{
+		Query: "SELECT l.Name, c.Label FROM ext l JOIN codes c ON l.ID = c.Code ORDER BY l.Name",
+	}, map[string]*types.DataSet{"codes": right})
+	require.NoError(t, err)
+	// texts are compared as numbers with a number column
+	if assert.Len(t, result.Rows, 2) {
+		assert.Equal(t, "first", test.GetColumn(result.Rows[0], "Label").CellValue.StringValue)
+		assert.Equal(t, "second", test.GetColumn(result.Rows[1], "Label").CellValue.StringValue)
+	}
+}
+
+func TestQueryReservedColumnNames(t *testing.T) {
+	// a selected column is never taken for a hidden HAVING column
+	result, err := test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT Currency, count(*) AS `__having1` FROM ext GROUP BY Currency HAVING sum(Quantity) > 0"))
+	require.NoError(t, err)
+	for _, r := range result.Rows {
+		assert.NotNil(t, test.GetColumn(r, "__having1"))
+		assert.Len(t, r.Columns, 2)
+	}
+
+	_, err = test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT Currency, count(*) AS `__filtrify_having_1` FROM ext GROUP BY Currency HAVING sum(Quantity) > 0"))
+	assert.Error(t, err)
+	reserved := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil, dataset.LongColumn("__filtrify_having_1", 1)),
+	})
+	_, err = test.Transform(t, test.UATAggregateTestDataFormatted, map[string]*types.DataSet{"Reserved": reserved}, queryStep(t, "SELECT * FROM ext"))
+	assert.Error(t, err)
+}
+
+func TestQueryInvalidConfiguration(t *testing.T) {
+	op := &operator.QueryOperator{}
+	for _, q := range []string{
+		"",
+		"DELETE FROM ext",
+		"SELECT * FROM ext e RIGHT JOIN instruments i ON e.Currency = i.Currency",
+		"SELECT * FROM ext e JOIN instruments i",
+		"SELECT * FROM ext e FULL JOIN instruments i ON e.Currency = i.Currency",
+		"SELECT * FROM ext e JOIN instruments i ON e.Currency = i.Currency OR e.Quantity = i.Quantity",
+		"SELECT * FROM ext e JOIN instruments i ON e.Currency > i.Currency",
+		"SELECT * FROM ext, instruments",
+		"SELECT `Instrument name` FROM ext WHERE Currency IN (SELECT Currency FROM instruments)",
+		"SELECT x.y FROM (SELECT Currency AS y FROM ext) AS x",
+		"SELECT Currency FROM ext UNION SELECT Currency FROM instruments",
+		"SELECT `Instrument name` FROM ext ORDER BY Quantity",
+		"SELECT `Instrument name` FROM ext ORDER BY 2",
+		"SELECT e.`Instrument name` FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` ORDER BY e.Quantity",
+		"SELECT e.`Instrument name` FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` ORDER BY lower(e.Currency)",
+		"SELECT e.`Instrument name` FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` LIMIT x",
+	} {
+		b, _ := json.Marshal(&operator.QueryConfiguration{Query: q})
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid, q)
+		assert.Error(t, err, q)
+	}
+
+	// the result columns are known by their aliases, names or positions
+	for _, q := range []string{
+		"SELECT `Instrument name` AS name, Quantity FROM ext ORDER BY name, 2 DESC",
+		"SELECT e.`Instrument name`, i.Region AS region FROM ext e JOIN instruments i ON e.`Instrument name` = i.`Instrument name` ORDER BY e.`Instrument name`, region",
+		"SELECT * FROM ext ORDER BY Quantity",
+		"SELECT Currency, sum(Quantity) FROM ext GROUP BY Currency ORDER BY Currency",
+	} {
+		b, _ := json.Marshal(&operator.QueryConfiguration{Query: q})
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.True(t, valid, q)
+		require.NoError(t, err, q)
+	}
+
+	_, err := test.Transform(t, test.UATAggregateTestDataFormatted, nil, queryStep(t, "SELECT * FROM ext e JOIN missing m ON e.Currency = m.Currency"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package filtrify_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/operator"
+	"github.com/liminaab/filtrify/test"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var unpivotTestData [][]string = [][]string{
+	{"Account", "Q1 2021", "Q2 2021", "Comment"},
+	{"A", "10", "20.5", "ok"},
+	{"B", "5", "", "late"},
+}
+
+func TestUnpivotPattern(t *testing.T) {
+	data := test.TypedData(t, unpivotTestData)
+	for i, r := range data.Rows {
+		key := fmt.Sprintf("%s%d", test.GetColumn(r, "Account").CellValue.StringValue, i)
+		r.Key = &key
+	}
+	result, err := filtrify.Transform(data, []*types.TransformationStep{test.Step(t, types.Unpivot, &operator.UnpivotConfiguration{
+		IDColumns:          []string{"Account"},
+		ValueColumnPattern: "^Q[1-4] ",
+		VariableColumnName: "Quarter",
+	})}, nil)
+	require.NoError(t, err, "unpivot operation failed")
+	assert.Len(t, result.Rows, 4)
+	assert.Len(t, result.Headers, 3)
+	// the integer and the double column share the double type
+	assert.Equal(t, types.DoubleType, result.Headers["Value"].DataType)
+
+	first := result.Rows[0]
+	assert.Equal(t, "A", test.GetColumn(first, "Account").CellValue.StringValue)
+	assert.Equal(t, "Q1 2021", test.GetColumn(first, "Quarter").CellValue.StringValue)
+	assert.Equal(t, types.DoubleType, test.GetColumn(first, "Value").CellValue.DataType)
+	assert.Equal(t, 10.0, test.GetColumn(first, "Value").CellValue.DoubleValue)
+	assert.Equal(t, "A0|Q1 2021", *first.Key)
+	assert.Equal(t, "B1|Q2 2021", *result.Rows[3].Key)
+	assert.Equal(t, types.NilType, test.GetColumn(result.Rows[3], "Value").CellValue.DataType)
+}
+
+func TestUnpivotMixedTypes(t *testing.T) {
+	result, err := test.Transform(t, unpivotTestData, nil, test.Step(t, types.Unpivot, &operator.UnpivotConfiguration{
+		IDColumns:    []string{"Account"},
+		ValueColumns: []string{"Comment", "Q1 2021", "Q2 2021"},
+		SkipNulls:    true,
+	}))
+	require.NoError(t, err, "unpivot operation failed")
+	// the empty Q2 of B is skipped
+	assert.Len(t, result.Rows, 5)
+	assert.Equal(t, types.StringType, result.Headers["Value"].DataType)
+	assert.Nil(t, result.Rows[0].Key)
+
+	// value columns follow the dataset order
+	expected := []string{"10", "20.5", "ok", "5", "late"}
+	for i, r := range result.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "Value").CellValue.StringValue)
+	}
+}
+
+func TestUnpivotInvalidConfiguration(t *testing.T) {
+	op := &operator.UnpivotOperator{}
+	for _, conf := range []*operator.UnpivotConfiguration{
+		{IDColumns: []string{"Account"}},
+		{ValueColumns: []string{"Q1 2021"}, ValueColumnPattern: "Q"},
+		{ValueColumnPattern: "Q[1-"},
+		{IDColumns: []string{"Account"}, ValueColumns: []string{"Account"}},
+		{IDColumns: []string{"Value"}, ValueColumns: []string{"Q1 2021"}},
+		{ValueColumns: []string{"Q1 2021"}, VariableColumnName: "x", ValueColumnName: "x"},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+
+	_, err := test.Transform(t, unpivotTestData, nil, test.Step(t, types.Unpivot, &operator.UnpivotConfiguration{ValueColumnPattern: "^Q5"}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, unpivotTestData, nil, test.Step(t, types.Unpivot, &operator.UnpivotConfiguration{ValueColumns: []string{"Q3 2021"}}))
+	assert.Error(t, err)
+}
+
+func TestUnpivotCopiesIDColumns(t *testing.T) {
+	data := test.TypedData(t, unpivotTestData)
+	// B has no account column
+	data.Rows[1].Columns = data.Rows[1].Columns[1:]
+	step := test.Step(t, types.Unpivot, &operator.UnpivotConfiguration{
+		IDColumns:    []string{"Account"},
+		ValueColumns: []string{"Q1 2021", "Q2 2021"},
+	})
+	result, err := filtrify.Transform(data, []*types.TransformationStep{step}, nil)
+	require.NoError(t, err, "unpivot operation failed")
+	assert.Len(t, result.Rows, 4)
+	// the rows of A don't share the account cell with each other or the input
+	test.GetColumn(result.Rows[0], "Account").CellValue.StringValue = "changed"
+	assert.Equal(t, "A", test.GetColumn(result.Rows[1], "Account").CellValue.StringValue)
+	assert.Equal(t, "A", test.GetColumn(data.Rows[0], "Account").CellValue.StringValue)
+	for _, r := range result.Rows[2:] {
+		account := test.GetColumn(r, "Account")
+		if assert.NotNil(t, account) {
+			assert.Equal(t, types.NilType, account.CellValue.DataType)
+		}
+	}
+}
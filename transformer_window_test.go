@@ -0,0 +1,163 @@
+package filtrify_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/liminaab/filtrify"
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/operator"
+	"github.com/liminaab/filtrify/test"
+	"github.com/liminaab/filtrify/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var windowTestData [][]string = [][]string{
+	{"Account", "Trade date", "Amount"},
+	{"A", "2021-01-05", "30"},
+	{"B", "2021-01-01", "5"},
+	{"A", "2021-01-01", "10"},
+	{"A", "2021-01-02", "20"},
+	{"B", "2021-01-03", ""},
+	{"A", "2021-01-10", "20"},
+}
+
+// tradeDateStep converts the trade dates of the window test data to dates
+func tradeDateStep(t *testing.T) *types.TransformationStep {
+	return test.Step(t, types.ChangeColumnType, &operator.ChangeColumnTypeConfiguration{
+		Columns: map[string]operator.ConversionConfiguration{
+			"Trade date": {TargetType: types.DateType, StringDate: &operator.StringDateConfiguration{DateFormat: "yyyy-MM-dd"}},
+		},
+	})
+}
+
+func TestWindowRanking(t *testing.T) {
+	result, err := test.Transform(t, windowTestData, nil, tradeDateStep(t), test.Step(t, types.Window, &operator.WindowConfiguration{
+		PartitionBy: []string{"Account"},
+		OrderBy:     []*operator.OrderConfiguration{{ColumnName: "Amount", Ascending: false}},
+		Functions: []*operator.WindowFunction{
+			{Function: operator.WindowRowNumber, NewColumnName: "row"},
+			{Function: operator.WindowRank, NewColumnName: "rank"},
+			{Function: operator.WindowDenseRank, NewColumnName: "dense"},
+		},
+	}))
+	require.NoError(t, err, "window operation failed")
+	assert.Len(t, result.Rows, 6)
+	assert.Equal(t, types.LongType, result.Headers["rank"].DataType)
+
+	// rows keep their original order
+	expected := [][]int64{{1, 1, 1}, {1, 1, 1}, {4, 4, 3}, {2, 2, 2}, {2, 2, 2}, {3, 2, 2}}
+	for i, r := range result.Rows {
+		assert.Equal(t, expected[i][0], test.GetColumn(r, "row").CellValue.LongValue, "invalid row number in row %d", i)
+		assert.Equal(t, expected[i][1], test.GetColumn(r, "rank").CellValue.LongValue, "invalid rank in row %d", i)
+		assert.Equal(t, expected[i][2], test.GetColumn(r, "dense").CellValue.LongValue, "invalid dense rank in row %d", i)
+	}
+}
+
+func TestWindowLagLeadAndValues(t *testing.T) {
+	result, err := test.Transform(t, windowTestData, nil, tradeDateStep(t), test.Step(t, types.Window, &operator.WindowConfiguration{
+		PartitionBy: []string{"Account"},
+		OrderBy:     []*operator.OrderConfiguration{{ColumnName: "Trade date", Ascending: true}},
+		Functions: []*operator.WindowFunction{
+			{Function: operator.WindowLag, Column: "Amount", NewColumnName: "previous", Default: 0},
+			{Function: operator.WindowLead, Column: "Amount", NewColumnName: "after next", Offset: 2},
+			{Function: operator.WindowFirstValue, Column: "Amount", NewColumnName: "first"},
+			{Function: operator.WindowLastValue, Column: "Trade date", NewColumnName: "last date"},
+			{Function: operator.WindowRunningCount, Column: "Amount", NewColumnName: "count"},
+			{Function: operator.WindowRunningMax, Column: "Amount", NewColumnName: "max"},
+		},
+	}))
+	require.NoError(t, err, "window operation failed")
+
+	a := result.Rows[3] // A 2021-01-02
+	assert.Equal(t, 10.0, test.GetColumn(a, "previous").CellValue.GetNumericVal())
+	assert.Equal(t, 20.0, test.GetColumn(a, "after next").CellValue.GetNumericVal())
+	assert.Equal(t, 10.0, test.GetColumn(a, "first").CellValue.GetNumericVal())
+	assert.Equal(t, 10, test.GetColumn(a, "last date").CellValue.TimestampValue.Day())
+	assert.Equal(t, int64(2), test.GetColumn(a, "count").CellValue.LongValue)
+	assert.Equal(t, 20.0, test.GetColumn(a, "max").CellValue.GetNumericVal())
+
+	first := result.Rows[2] // A 2021-01-01
+	assert.Equal(t, 0.0, test.GetColumn(first, "previous").CellValue.GetNumericVal())
+	assert.Equal(t, types.NilType, test.GetColumn(result.Rows[5], "after next").CellValue.DataType)
+
+	// nil values aren't counted
+	b := result.Rows[4] // B 2021-01-03
+	assert.Equal(t, int64(1), test.GetColumn(b, "count").CellValue.LongValue)
+	assert.Equal(t, 5.0, test.GetColumn(b, "max").CellValue.GetNumericVal())
+}
+
+func TestWindowMovingAverages(t *testing.T) {
+	result, err := test.Transform(t, windowTestData, nil, tradeDateStep(t), test.Step(t, types.Window, &operator.WindowConfiguration{
+		PartitionBy: []string{"Account"},
+		OrderBy:     []*operator.OrderConfiguration{{ColumnName: "Trade date", Ascending: true}},
+		Functions: []*operator.WindowFunction{
+			{Function: operator.WindowMovingSum, Column: "Amount", NewColumnName: "sum 2 rows", Rows: 2},
+			{Function: operator.WindowMovingSum, Column: "Amount", NewColumnName: "sum 1 row", Rows: 1},
+			{Function: operator.WindowMovingAvg, Column: "Amount", NewColumnName: "avg 7 days", Range: "7d"},
+			{Function: operator.WindowRunningAvg, Column: "Amount", NewColumnName: "running avg"},
+		},
+	}))
+	require.NoError(t, err, "window operation failed")
+	assert.Equal(t, types.DoubleType, result.Headers["sum 2 rows"].DataType)
+
+	// A 2021-01-05 - 20 + 30
+	assert.Equal(t, 50.0, test.GetColumn(result.Rows[0], "sum 2 rows").CellValue.DoubleValue)
+	// 01-01, 01-02 and 01-05 are within a week
+	assert.Equal(t, 20.0, test.GetColumn(result.Rows[0], "avg 7 days").CellValue.DoubleValue)
+	// 01-10 only reaches back to 01-05
+	assert.Equal(t, 25.0, test.GetColumn(result.Rows[5], "avg 7 days").CellValue.DoubleValue)
+	assert.Equal(t, 20.0, test.GetColumn(result.Rows[5], "running avg").CellValue.DoubleValue)
+	assert.Equal(t, 5.0, test.GetColumn(result.Rows[4], "running avg").CellValue.DoubleValue)
+	// a window without numbers has no sum
+	assert.Equal(t, types.NilType, test.GetColumn(result.Rows[4], "sum 1 row").CellValue.DataType)
+	assert.Equal(t, 5.0, test.GetColumn(result.Rows[1], "sum 1 row").CellValue.DoubleValue)
+}
+
+func TestWindowPartitionKeys(t *testing.T) {
+	// empty texts, nulls and equal texts of other types are separate partitions
+	data := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil, dataset.StringColumn("Group", ""), dataset.LongColumn("Amount", 1)),
+		dataset.DataRow(nil, dataset.NilColumn("Group"), dataset.LongColumn("Amount", 2)),
+		dataset.DataRow(nil, dataset.StringColumn("Group", "1"), dataset.LongColumn("Amount", 3)),
+		dataset.DataRow(nil, dataset.LongColumn("Group", 1), dataset.LongColumn("Amount", 4)),
+		dataset.DataRow(nil, dataset.StringColumn("Group", ""), dataset.LongColumn("Amount", 5)),
+	})
+	result, err := filtrify.Transform(data, []*types.TransformationStep{test.Step(t, types.Window, &operator.WindowConfiguration{
+		PartitionBy: []string{"Group"},
+		Functions:   []*operator.WindowFunction{{Function: operator.WindowRowNumber, NewColumnName: "row"}},
+	})}, nil)
+	require.NoError(t, err)
+	expected := []int64{1, 1, 1, 1, 2}
+	for i, r := range result.Rows {
+		assert.Equal(t, expected[i], test.GetColumn(r, "row").CellValue.LongValue, "invalid row number in row %d", i)
+	}
+}
+
+func TestWindowInvalidConfiguration(t *testing.T) {
+	op := &operator.WindowOperator{}
+	for _, conf := range []*operator.WindowConfiguration{
+		{},
+		{Functions: []*operator.WindowFunction{{Function: "median", Column: "Amount", NewColumnName: "x"}}},
+		{Functions: []*operator.WindowFunction{{Function: operator.WindowLag, NewColumnName: "x"}}},
+		{Functions: []*operator.WindowFunction{{Function: operator.WindowMovingSum, Column: "Amount", NewColumnName: "x"}}},
+		{Functions: []*operator.WindowFunction{{Function: operator.WindowMovingSum, Column: "Amount", NewColumnName: "x", Range: "7d"}}},
+		{Functions: []*operator.WindowFunction{{Function: operator.WindowRank, NewColumnName: "x"}, {Function: operator.WindowRank, NewColumnName: "x"}}},
+	} {
+		b, _ := json.Marshal(conf)
+		valid, err := op.ValidateConfiguration(string(b))
+		assert.False(t, valid)
+		assert.Error(t, err)
+	}
+
+	_, err := test.Transform(t, windowTestData, nil, tradeDateStep(t), test.Step(t, types.Window, &operator.WindowConfiguration{
+		OrderBy:   []*operator.OrderConfiguration{{ColumnName: "Account", Ascending: true}},
+		Functions: []*operator.WindowFunction{{Function: operator.WindowMovingSum, Column: "Amount", NewColumnName: "x", Range: "7d"}},
+	}))
+	assert.Error(t, err)
+	_, err = test.Transform(t, windowTestData, nil, tradeDateStep(t), test.Step(t, types.Window, &operator.WindowConfiguration{
+		Functions: []*operator.WindowFunction{{Function: operator.WindowRank, NewColumnName: "Amount"}},
+	}))
+	assert.Error(t, err)
+}
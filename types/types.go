@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -28,7 +29,12 @@ const (
 	JSON
 	Objectify
 	CumulativeSum
-	GroupBy = 13
+	GroupBy  = 13
+	Query    = 14
+	Window   = 15
+	Pivot    = 16
+	Unpivot  = 17
+	Classify = 18
 )
 
 func (t TransformationOperatorType) String() string {
@@ -59,6 +65,16 @@ func (t TransformationOperatorType) String() string {
 		return "Objectify"
 	case CumulativeSum:
 		return "CumulativeSum"
+	case Query:
+		return "Query"
+	case Window:
+		return "Window"
+	case Pivot:
+		return "Pivot"
+	case Unpivot:
+		return "Unpivot"
+	case Classify:
+		return "Classify"
 	}
 	return "Unknown"
 }
@@ -118,6 +134,13 @@ type TransformationOperator interface {
 	ValidateConfiguration(config string) (bool, error)
 }
 
+// SideSetOperator is an operator which can output more datasets besides the transformed one.
+// The next steps of a transformation get them as other datasets by their names
+type SideSetOperator interface {
+	TransformationOperator
+	TransformWithSideSets(dataset *DataSet, config string, otherSets map[string]*DataSet) (*DataSet, map[string]*DataSet, error)
+}
+
 // type DataSet struct {
 // 	RawData                  [][]string
 // 	RawDataFirstLineIsHeader bool
@@ -159,6 +182,67 @@ func (t *DataSet) ToRawData() [][]string {
 	return rawData
 }
 
+// OrderedHeaders returns the headers of the dataset in column order.
+// Header.Order decides the order when it is set - otherwise the column order of the first row is used.
+// Columns that are missing from the header map get a header built from their first non nil value
+func (t *DataSet) OrderedHeaders() []*Header {
+	positions := make(map[string]int)
+	names := make([]string, 0)
+	if len(t.Rows) > 0 {
+		for i, c := range t.Rows[0].Columns {
+			positions[c.ColumnName] = i
+			names = append(names, c.ColumnName)
+		}
+	}
+
+	headers := make([]*Header, 0, len(names))
+	hasOrder := false
+	for _, name := range names {
+		h, ok := t.Headers[name]
+		if !ok {
+			h = &Header{ColumnName: name, DataType: t.columnType(positions[name])}
+		}
+		if h.Order != 0 {
+			hasOrder = true
+		}
+		headers = append(headers, h)
+	}
+	// headers without any column in the first row go to the end
+	extra := make([]*Header, 0)
+	for name, h := range t.Headers {
+		if _, ok := positions[name]; ok {
+			continue
+		}
+		if h.Order != 0 {
+			hasOrder = true
+		}
+		extra = append(extra, h)
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return extra[i].ColumnName < extra[j].ColumnName
+	})
+	headers = append(headers, extra...)
+
+	if hasOrder {
+		sort.SliceStable(headers, func(i, j int) bool {
+			return headers[i].Order < headers[j].Order
+		})
+	}
+	return headers
+}
+
+func (t *DataSet) columnType(index int) CellDataType {
+	for _, r := range t.Rows {
+		if len(r.Columns) <= index || r.Columns[index].CellValue == nil {
+			continue
+		}
+		if r.Columns[index].CellValue.DataType != NilType {
+			return r.Columns[index].CellValue.DataType
+		}
+	}
+	return NilType
+}
+
 type DataRow struct {
 	Key     *string
 	Columns []*DataColumn
@@ -0,0 +1,584 @@
+package xlsxio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liminaab/filtrify/conversion"
+	"github.com/liminaab/filtrify/types"
+	"github.com/xuri/excelize/v2"
+)
+
+const defaultColumnName string = "Column"
+
+type ReaderConfiguration struct {
+	// name of the sheet to read - the first sheet is used when it is empty
+	Sheet string
+	// cell range like "B2:F100" - the used range of the sheet is read when it is empty
+	Range             string
+	FirstLineIsHeader bool
+	// number of rows the header spans - merged group headers are combined with the cells below them
+	HeaderRows      int
+	HeaderSeparator string
+}
+
+func DefaultReaderConfiguration() *ReaderConfiguration {
+	return &ReaderConfiguration{
+		FirstLineIsHeader: true,
+		HeaderRows:        1,
+		HeaderSeparator:   " ",
+	}
+}
+
+type cellKind int
+
+const (
+	numberCell cellKind = iota
+	dateCell
+	timeOfDayCell
+	timestampCell
+)
+
+const (
+	intNumberFormat  = "0"
+	longNumberFormat = "0;-0;0"
+	// whole numbers are shown with a decimal so they still look like doubles
+	doubleNumberFormat = "0.0##############"
+)
+
+type sheetReader struct {
+	f         *excelize.File
+	sheet     string
+	styleKind map[int]cellKind
+	styleType map[int]types.CellDataType
+}
+
+func Read(r io.Reader, config *ReaderConfiguration) (*types.DataSet, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWorkbook(f, config)
+}
+
+func ReadFile(filePath string, config *ReaderConfiguration) (*types.DataSet, error) {
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWorkbook(f, config)
+}
+
+// ReadWorkbook reads a sheet of an already opened workbook into a typed dataset.
+// Numbers, booleans and dates are taken from the native cell types - text cells stay text
+func ReadWorkbook(f *excelize.File, config *ReaderConfiguration) (*types.DataSet, error) {
+	if config == nil {
+		config = DefaultReaderConfiguration()
+	}
+	sheet := config.Sheet
+	if len(sheet) == 0 {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("workbook doesn't have any sheets")
+		}
+		sheet = sheets[0]
+	} else if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		return nil, fmt.Errorf("sheet “%s” not found", sheet)
+	}
+
+	sr := &sheetReader{
+		f:         f,
+		sheet:     sheet,
+		styleKind: make(map[int]cellKind),
+		styleType: make(map[int]types.CellDataType),
+	}
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	startCol, startRow, endCol, endRow, err := sr.bounds(rows, config.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	headerRows := 0
+	if config.FirstLineIsHeader {
+		headerRows = config.HeaderRows
+		if headerRows < 1 {
+			headerRows = 1
+		}
+	}
+	if endRow-startRow+1 < headerRows || endCol < startCol {
+		return nil, errors.New("empty sheet range")
+	}
+
+	headers, err := sr.buildHeaders(rows, startCol, startRow, endCol, headerRows, config.HeaderSeparator)
+	if err != nil {
+		return nil, err
+	}
+
+	// first we read all the cells - the column type can only be decided after seeing all of them
+	dataRowCount := endRow - startRow + 1 - headerRows
+	cells := make([][]*types.CellValue, dataRowCount)
+	typeHints := make([][]types.CellDataType, dataRowCount)
+	for ri := 0; ri < dataRowCount; ri++ {
+		rowNumber := startRow + headerRows + ri
+		cells[ri] = make([]*types.CellValue, len(headers))
+		typeHints[ri] = make([]types.CellDataType, len(headers))
+		for ci := range headers {
+			cell, hint, err := sr.readCell(rows, startCol+ci, rowNumber)
+			if err != nil {
+				return nil, err
+			}
+			cells[ri][ci] = cell
+			typeHints[ri][ci] = hint
+		}
+	}
+
+	typedHeaders := make(types.HeaderMap, len(headers))
+	for ci, h := range headers {
+		dataType := reconcileColumn(cells, typeHints, ci)
+		typedHeaders[h] = &types.Header{
+			ColumnName: h,
+			DataType:   dataType,
+			Order:      int64(ci),
+		}
+	}
+
+	dataRows := make([]*types.DataRow, dataRowCount)
+	for ri := range cells {
+		cols := make([]*types.DataColumn, len(headers))
+		for ci, h := range headers {
+			cols[ci] = &types.DataColumn{
+				ColumnName: h,
+				CellValue:  cells[ri][ci],
+			}
+		}
+		dataRows[ri] = &types.DataRow{Columns: cols}
+	}
+
+	return &types.DataSet{
+		Rows:    dataRows,
+		Headers: typedHeaders,
+	}, nil
+}
+
+// bounds returns 1 based coordinates of the area to read
+func (t *sheetReader) bounds(rows [][]string, cellRange string) (int, int, int, int, error) {
+	if len(cellRange) > 0 {
+		parts := strings.Split(cellRange, ":")
+		if len(parts) != 2 {
+			return 0, 0, 0, 0, fmt.Errorf("invalid range “%s”", cellRange)
+		}
+		startCol, startRow, err := excelize.CellNameToCoordinates(parts[0])
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(parts[1])
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		if endCol < startCol || endRow < startRow {
+			return 0, 0, 0, 0, fmt.Errorf("invalid range “%s”", cellRange)
+		}
+		return startCol, startRow, endCol, endRow, nil
+	}
+
+	// the used range - leading empty rows are skipped
+	startRow := 1
+	for startRow <= len(rows) && isEmptyRow(rows[startRow-1]) {
+		startRow++
+	}
+	endCol := 0
+	for _, r := range rows {
+		if len(r) > endCol {
+			endCol = len(r)
+		}
+	}
+	return 1, startRow, endCol, len(rows), nil
+}
+
+func isEmptyRow(row []string) bool {
+	for _, c := range row {
+		if len(c) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func rawValue(rows [][]string, col int, row int) string {
+	if row-1 >= len(rows) || col-1 >= len(rows[row-1]) {
+		return ""
+	}
+	return rows[row-1][col-1]
+}
+
+func (t *sheetReader) buildHeaders(rows [][]string, startCol int, startRow int, endCol int, headerRows int, separator string) ([]string, error) {
+	headers := make([]string, endCol-startCol+1)
+	if headerRows == 0 {
+		for i := range headers {
+			headers[i] = fmt.Sprintf("%s%d", defaultColumnName, i)
+		}
+		return headers, nil
+	}
+
+	// merged cells only hold the value in their top left cell
+	// we spread that value over the whole merged area of the header
+	mergedValues := make(map[string]string)
+	merges, err := t.f.GetMergeCells(t.sheet)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range merges {
+		fromCol, fromRow, err := excelize.CellNameToCoordinates(m.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+		toCol, toRow, err := excelize.CellNameToCoordinates(m.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+		for r := fromRow; r <= toRow; r++ {
+			for c := fromCol; c <= toCol; c++ {
+				mergedValues[fmt.Sprintf("%d:%d", c, r)] = m.GetCellValue()
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range headers {
+		parts := make([]string, 0, headerRows)
+		for r := startRow; r < startRow+headerRows; r++ {
+			val, merged := mergedValues[fmt.Sprintf("%d:%d", startCol+i, r)]
+			if !merged {
+				val = rawValue(rows, startCol+i, r)
+			}
+			val = strings.TrimSpace(val)
+			// vertically merged cells would repeat the same text
+			if len(val) == 0 || (len(parts) > 0 && parts[len(parts)-1] == val) {
+				continue
+			}
+			parts = append(parts, val)
+		}
+		header := strings.Join(parts, separator)
+		if len(header) == 0 {
+			header = fmt.Sprintf("%s%d", defaultColumnName, i)
+		}
+		if seen[header] {
+			return nil, fmt.Errorf("duplicate column “%s” in header row", header)
+		}
+		seen[header] = true
+		headers[i] = header
+	}
+	return headers, nil
+}
+
+// readCell returns the cell and the number type its number format asks for - NilType when there is none
+func (t *sheetReader) readCell(rows [][]string, col int, row int) (*types.CellValue, types.CellDataType, error) {
+	raw := rawValue(rows, col, row)
+	if len(raw) == 0 {
+		return &types.CellValue{DataType: types.NilType}, types.NilType, nil
+	}
+	cellName, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return nil, types.NilType, err
+	}
+	cellType, err := t.f.GetCellType(t.sheet, cellName)
+	if err != nil {
+		return nil, types.NilType, err
+	}
+
+	switch cellType {
+	case excelize.CellTypeBool:
+		return &types.CellValue{DataType: types.BoolType, BoolValue: raw == "1" || strings.EqualFold(raw, "true")}, types.NilType, nil
+	case excelize.CellTypeError:
+		return &types.CellValue{DataType: types.NilType}, types.NilType, nil
+	case excelize.CellTypeSharedString, excelize.CellTypeInlineString, excelize.CellTypeFormula:
+		return &types.CellValue{DataType: types.StringType, StringValue: raw}, types.NilType, nil
+	case excelize.CellTypeDate:
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return &types.CellValue{DataType: types.StringType, StringValue: raw}, types.NilType, nil
+		}
+		return &types.CellValue{DataType: types.TimestampType, TimestampValue: ts}, types.NilType, nil
+	}
+
+	// everything else is a number - or a cached formula result without a type
+	number, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return &types.CellValue{DataType: types.StringType, StringValue: raw}, types.NilType, nil
+	}
+	kind, hint, err := t.numberKind(cellName)
+	if err != nil {
+		return nil, types.NilType, err
+	}
+	return numberToCell(number, kind), hint, nil
+}
+
+func numberToCell(number float64, kind cellKind) *types.CellValue {
+	switch kind {
+	case dateCell, timestampCell:
+		ts := conversion.ExcelDateToTime(number)
+		dataType := types.TimestampType
+		if kind == dateCell && number == math.Trunc(number) {
+			dataType = types.DateType
+		}
+		return &types.CellValue{DataType: dataType, TimestampValue: ts}
+	case timeOfDayCell:
+		_, fraction := math.Modf(number)
+		nanos := time.Duration(math.Round(fraction*86400*1000)) * time.Millisecond
+		return &types.CellValue{DataType: types.TimeOfDayType, TimestampValue: time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(nanos)}
+	}
+	return &types.CellValue{DataType: types.DoubleType, DoubleValue: number}
+}
+
+// numberKind uses the number format of the cell to find out if the number is a date
+// and which number type the format stands for
+func (t *sheetReader) numberKind(cellName string) (cellKind, types.CellDataType, error) {
+	styleID, err := t.f.GetCellStyle(t.sheet, cellName)
+	if err != nil {
+		return numberCell, types.NilType, err
+	}
+	if kind, ok := t.styleKind[styleID]; ok {
+		return kind, t.styleType[styleID], nil
+	}
+	kind := numberCell
+	hint := types.NilType
+	style, err := t.f.GetStyle(styleID)
+	if err == nil && style != nil {
+		if style.CustomNumFmt != nil {
+			kind = formatKind(*style.CustomNumFmt)
+			hint = numberTypeHint(*style.CustomNumFmt)
+		} else {
+			kind = builtInFormatKind(style.NumFmt)
+			hint = builtInNumberTypeHint(style.NumFmt)
+		}
+	}
+	t.styleKind[styleID] = kind
+	t.styleType[styleID] = hint
+	return kind, hint, nil
+}
+
+// numberTypeHint returns the number type of a number format - formats with decimals are doubles
+// and the formats of the writer tell ints and longs apart
+func numberTypeHint(format string) types.CellDataType {
+	switch format {
+	case intNumberFormat:
+		return types.IntType
+	case longNumberFormat:
+		return types.LongType
+	}
+	section := format
+	if i := strings.Index(section, ";"); i >= 0 {
+		section = section[:i]
+	}
+	inQuotes := false
+	for i := 0; i < len(section); i++ {
+		switch c := section[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '\\':
+			i++
+		case c == '.' && i+1 < len(section) && (section[i+1] == '0' || section[i+1] == '#'):
+			return types.DoubleType
+		}
+	}
+	return types.NilType
+}
+
+func builtInNumberTypeHint(numFmt int) types.CellDataType {
+	switch numFmt {
+	case 1:
+		return types.IntType
+	case 2, 4, 10, 11:
+		return types.DoubleType
+	}
+	return types.NilType
+}
+
+func builtInFormatKind(numFmt int) cellKind {
+	switch {
+	case numFmt >= 14 && numFmt <= 17, numFmt >= 27 && numFmt <= 31, numFmt >= 34 && numFmt <= 36, numFmt >= 50 && numFmt <= 58:
+		return dateCell
+	case numFmt == 22:
+		return timestampCell
+	case numFmt >= 18 && numFmt <= 21, numFmt >= 32 && numFmt <= 33, numFmt >= 45 && numFmt <= 47:
+		return timeOfDayCell
+	}
+	return numberCell
+}
+
+// formatKind checks the date and time tokens of a custom number format
+func formatKind(format string) cellKind {
+	// only the first section is relevant - the others are for negative numbers, zero and text
+	inQuotes := false
+	inBrackets := false
+	hasDate := false
+	hasTime := false
+	var lastToken byte
+	lower := strings.ToLower(format)
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '\\' || c == '_' || c == '*':
+			i++
+		case c == '[':
+			inBrackets = true
+			// elapsed time like [h]:mm
+			if i+1 < len(lower) && (lower[i+1] == 'h' || lower[i+1] == 'm' || lower[i+1] == 's') {
+				hasTime = true
+				lastToken = 'h'
+			}
+		case c == ']':
+			inBrackets = false
+		case inBrackets:
+		case c == ';':
+			i = len(lower)
+		case c == 'y' || c == 'd':
+			hasDate = true
+			lastToken = c
+		case c == 'h' || c == 's':
+			hasTime = true
+			lastToken = c
+		case c == 'm':
+			// m means minutes right after hours or right before seconds - otherwise it is a month
+			if lastToken == 'h' || nextToken(lower, i) == 's' {
+				hasTime = true
+			} else {
+				hasDate = true
+			}
+			for i+1 < len(lower) && lower[i+1] == 'm' {
+				i++
+			}
+			lastToken = 'm'
+		}
+	}
+	switch {
+	case hasDate && hasTime:
+		return timestampCell
+	case hasDate:
+		return dateCell
+	case hasTime:
+		return timeOfDayCell
+	}
+	return numberCell
+}
+
+// reconcileColumn decides the type of a column and converts the cells that don't fit that type.
+// Numbers follow the type their number formats ask for when every number of the column agrees
+func reconcileColumn(cells [][]*types.CellValue, typeHints [][]types.CellDataType, ci int) types.CellDataType {
+	columnType := types.NilType
+	allIntegral := true
+	mixed := false
+	numberHint := types.NilType
+	hintsAgree := true
+	for ri, r := range cells {
+		c := r[ci]
+		if c.DataType == types.NilType {
+			continue
+		}
+		if c.DataType == types.DoubleType {
+			if hint := typeHints[ri][ci]; hint == types.NilType || (numberHint != types.NilType && numberHint != hint) {
+				hintsAgree = false
+			} else {
+				numberHint = hint
+			}
+		}
+		if c.DataType == types.DoubleType && (c.DoubleValue != math.Trunc(c.DoubleValue) || math.Abs(c.DoubleValue) > math.MaxInt64) {
+			allIntegral = false
+		}
+		if columnType == types.NilType {
+			columnType = c.DataType
+			continue
+		}
+		if columnType == c.DataType {
+			continue
+		}
+		// dates with a time part and plain dates can live in the same column
+		if isTimeType(columnType) && isTimeType(c.DataType) && columnType != types.TimeOfDayType && c.DataType != types.TimeOfDayType {
+			columnType = types.TimestampType
+			continue
+		}
+		mixed = true
+	}
+
+	if mixed {
+		// there is no common native type - we keep the text
+		for _, r := range cells {
+			if r[ci].DataType != types.NilType && r[ci].DataType != types.StringType {
+				r[ci] = &types.CellValue{DataType: types.StringType, StringValue: r[ci].ToString()}
+			}
+		}
+		return types.StringType
+	}
+
+	switch columnType {
+	case types.DoubleType:
+		if !allIntegral || (hintsAgree && numberHint == types.DoubleType) {
+			return types.DoubleType
+		}
+		// the numbers are integral - let's narrow them like the text conversion does
+		targetType := types.IntType
+		if hintsAgree && numberHint == types.LongType {
+			targetType = types.LongType
+		}
+		for _, r := range cells {
+			c := r[ci]
+			if c.DataType != types.NilType && (c.DoubleValue > math.MaxInt32 || c.DoubleValue < math.MinInt32) {
+				targetType = types.LongType
+				break
+			}
+		}
+		for _, r := range cells {
+			c := r[ci]
+			if c.DataType == types.NilType {
+				continue
+			}
+			if targetType == types.IntType {
+				r[ci] = &types.CellValue{DataType: types.IntType, IntValue: int32(c.DoubleValue)}
+			} else {
+				r[ci] = &types.CellValue{DataType: types.LongType, LongValue: int64(c.DoubleValue)}
+			}
+		}
+		return targetType
+	case types.TimestampType:
+		for _, r := range cells {
+			if r[ci].DataType == types.DateType {
+				r[ci].DataType = types.TimestampType
+			}
+		}
+	case types.NilType:
+		return types.StringType
+	}
+	return columnType
+}
+
+func nextToken(format string, from int) byte {
+	for i := from + 1; i < len(format); i++ {
+		switch format[i] {
+		case 'y', 'd', 'h', 's':
+			return format[i]
+		case 'm':
+			if format[i-1] != 'm' {
+				return 'm'
+			}
+		}
+	}
+	return 0
+}
+
+func isTimeType(t types.CellDataType) bool {
+	return t == types.TimestampType || t == types.DateType || t == types.TimeOfDayType
+}
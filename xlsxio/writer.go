@@ -0,0 +1,178 @@
+package xlsxio
+
+import (
+	"io"
+	"time"
+
+	"github.com/liminaab/filtrify/types"
+	"github.com/xuri/excelize/v2"
+)
+
+const defaultSheetName = "Sheet1"
+
+// the number formats of the number types differ so the reader can tell them apart - see numberTypeHint
+var defaultNumberFormats = map[types.CellDataType]string{
+	types.IntType:       intNumberFormat,
+	types.LongType:      longNumberFormat,
+	types.DoubleType:    doubleNumberFormat,
+	types.DateType:      "yyyy-mm-dd",
+	types.TimestampType: "yyyy-mm-dd hh:mm:ss",
+	types.TimeOfDayType: "hh:mm:ss",
+}
+
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+type WriterConfiguration struct {
+	Sheet      string
+	OmitHeader bool
+	// number format per column - columns without one get the default format of their type
+	NumberFormats map[string]string
+}
+
+func DefaultWriterConfiguration() *WriterConfiguration {
+	return &WriterConfiguration{
+		Sheet: defaultSheetName,
+	}
+}
+
+func Write(w io.Writer, dataset *types.DataSet, config *WriterConfiguration) error {
+	f, err := newWorkbook(dataset, config)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteTo(w)
+	return err
+}
+
+func WriteFile(filePath string, dataset *types.DataSet, config *WriterConfiguration) error {
+	f, err := newWorkbook(dataset, config)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.SaveAs(filePath)
+}
+
+func newWorkbook(dataset *types.DataSet, config *WriterConfiguration) (*excelize.File, error) {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	f := excelize.NewFile()
+	// let's rename the default sheet instead of adding an extra one
+	if len(config.Sheet) > 0 && config.Sheet != defaultSheetName {
+		if err := f.SetSheetName(defaultSheetName, config.Sheet); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := WriteToWorkbook(f, dataset, config); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteToWorkbook writes the dataset to a sheet of the workbook - the sheet is created when it doesn't exist.
+// Cells keep their native type and get a number format that matches their column type
+func WriteToWorkbook(f *excelize.File, dataset *types.DataSet, config *WriterConfiguration) error {
+	if config == nil {
+		config = DefaultWriterConfiguration()
+	}
+	sheet := config.Sheet
+	if len(sheet) == 0 {
+		sheet = defaultSheetName
+	}
+	if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+	}
+
+	headers := dataset.OrderedHeaders()
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	styles := make([]int, len(headers))
+	for i, h := range headers {
+		format, ok := config.NumberFormats[h.ColumnName]
+		if !ok {
+			format, ok = defaultNumberFormats[h.DataType]
+		}
+		if !ok {
+			continue
+		}
+		styles[i], err = f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+		if err != nil {
+			return err
+		}
+	}
+
+	rowNumber := 1
+	if !config.OmitHeader {
+		values := make([]interface{}, len(headers))
+		for i, h := range headers {
+			values[i] = h.ColumnName
+		}
+		if err := sw.SetRow("A1", values); err != nil {
+			return err
+		}
+		rowNumber++
+	}
+
+	for _, r := range dataset.Rows {
+		colIndex := make(map[string]*types.DataColumn, len(r.Columns))
+		for _, c := range r.Columns {
+			colIndex[c.ColumnName] = c
+		}
+		values := make([]interface{}, len(headers))
+		for i, h := range headers {
+			c, ok := colIndex[h.ColumnName]
+			if !ok || c.CellValue == nil || c.CellValue.DataType == types.NilType {
+				continue
+			}
+			values[i] = excelize.Cell{StyleID: styles[i], Value: cellToValue(c.CellValue)}
+		}
+		cellName, err := excelize.CoordinatesToCellName(1, rowNumber)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetRow(cellName, values); err != nil {
+			return err
+		}
+		rowNumber++
+	}
+
+	return sw.Flush()
+}
+
+func cellToValue(cell *types.CellValue) interface{} {
+	switch cell.DataType {
+	case types.IntType:
+		return cell.IntValue
+	case types.LongType:
+		return cell.LongValue
+	case types.DoubleType:
+		return cell.DoubleValue
+	case types.BoolType:
+		return cell.BoolValue
+	case types.StringType:
+		return cell.StringValue
+	case types.TimestampType, types.DateType:
+		return timeToSerial(cell.TimestampValue)
+	case types.TimeOfDayType:
+		t := cell.TimestampValue
+		seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+		return (float64(seconds) + float64(t.Nanosecond())/1e9) / 86400
+	}
+	// objects are written as their JSON text
+	return cell.ToString()
+}
+
+// timeToSerial keeps the wall clock of the time - spreadsheets don't know about time zones
+func timeToSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return (float64(wall.Unix()-excelEpoch.Unix()) + float64(wall.Nanosecond())/1e9) / 86400
+}
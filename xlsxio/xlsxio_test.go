@@ -0,0 +1,146 @@
+package xlsxio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/liminaab/filtrify/dataset"
+	"github.com/liminaab/filtrify/types"
+	assert2 "github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func buildSampleDataset() *types.DataSet {
+	ds := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "ERIC B SS Equity"),
+			dataset.IntColumn("Quantity", 175000),
+			dataset.DoubleColumn("Price", 12.5),
+			dataset.BoolColumn("Active", true),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 3, 14, 15, 4, 5, 0, time.UTC)),
+		),
+		dataset.DataRow(nil,
+			dataset.StringColumn("Instrument", "AMZN US Equity"),
+			dataset.IntColumn("Quantity", -1500),
+			dataset.NilColumn("Price"),
+			dataset.BoolColumn("Active", false),
+			&types.DataColumn{ColumnName: "Trade Date", CellValue: &types.CellValue{DataType: types.DateType, TimestampValue: time.Date(2021, 11, 18, 0, 0, 0, 0, time.UTC)}},
+			dataset.TimestampColumn("Created", time.Date(2021, 11, 18, 9, 30, 0, 0, time.UTC)),
+		),
+	})
+	return ds
+}
+
+func TestWriteAndReadBack(t *testing.T) {
+	assert := assert2.New(t)
+	ds := buildSampleDataset()
+
+	var buf bytes.Buffer
+	err := Write(&buf, ds, &WriterConfiguration{Sheet: "Positions", NumberFormats: map[string]string{"Price": "0.00"}})
+	assert.Nil(err)
+
+	read, err := Read(bytes.NewReader(buf.Bytes()), &ReaderConfiguration{Sheet: "Positions", FirstLineIsHeader: true})
+	assert.Nil(err)
+	assert.Len(read.Rows, 2)
+	assert.Equal(types.StringType, read.Headers["Instrument"].DataType)
+	assert.Equal(types.IntType, read.Headers["Quantity"].DataType)
+	assert.Equal(types.DoubleType, read.Headers["Price"].DataType)
+	assert.Equal(types.BoolType, read.Headers["Active"].DataType)
+	assert.Equal(types.DateType, read.Headers["Trade Date"].DataType)
+	assert.Equal(types.TimestampType, read.Headers["Created"].DataType)
+
+	for i, r := range read.Rows {
+		for _, c := range r.Columns {
+			expected := ds.Rows[i].GetColumn(c.ColumnName).CellValue
+			if expected.DataType == types.NilType {
+				assert.Equal(types.NilType, c.CellValue.DataType, c.ColumnName)
+			} else {
+				assert.True(c.CellValue.Equals(expected), c.ColumnName)
+			}
+		}
+	}
+
+	// the number format of the column is kept
+	f, err := excelize.OpenReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(err)
+	val, err := f.GetCellValue("Positions", "C2")
+	assert.Nil(err)
+	assert.Equal("12.50", val)
+}
+
+func TestReadRangeWithMergedHeaders(t *testing.T) {
+	assert := assert2.New(t)
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Sheet1"
+	assert.Nil(f.SetCellValue(sheet, "A1", "report title - ignored"))
+	assert.Nil(f.SetCellValue(sheet, "B2", "Account"))
+	assert.Nil(f.MergeCell(sheet, "B2", "B3"))
+	assert.Nil(f.SetCellValue(sheet, "C2", "Q1"))
+	assert.Nil(f.MergeCell(sheet, "C2", "D2"))
+	assert.Nil(f.SetCellValue(sheet, "C3", "Revenue"))
+	assert.Nil(f.SetCellValue(sheet, "D3", "Cost"))
+	assert.Nil(f.SetSheetRow(sheet, "B4", &[]interface{}{"acc-1", 100.25, 40}))
+	assert.Nil(f.SetSheetRow(sheet, "B5", &[]interface{}{"acc-2", 200, 3000000000}))
+	assert.Nil(f.SetSheetRow(sheet, "B6", &[]interface{}{"totals", 300.25, 3000000040}))
+
+	config := DefaultReaderConfiguration()
+	config.Range = "B2:D5"
+	config.HeaderRows = 2
+	ds, err := ReadWorkbook(f, config)
+	assert.Nil(err)
+	assert.Len(ds.Rows, 2)
+	headers := ds.OrderedHeaders()
+	assert.Equal("Account", headers[0].ColumnName)
+	assert.Equal("Q1 Revenue", headers[1].ColumnName)
+	assert.Equal("Q1 Cost", headers[2].ColumnName)
+	assert.Equal(types.DoubleType, ds.Headers["Q1 Revenue"].DataType)
+	assert.Equal(types.LongType, ds.Headers["Q1 Cost"].DataType)
+	assert.Equal(int64(3000000000), ds.Rows[1].GetColumn("Q1 Cost").CellValue.LongValue)
+}
+
+func TestCustomFormatKinds(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal(dateCell, formatKind("dd/mm/yyyy"))
+	assert.Equal(timestampCell, formatKind("yyyy-mm-dd hh:mm"))
+	assert.Equal(timeOfDayCell, formatKind("mm:ss"))
+	assert.Equal(timeOfDayCell, formatKind("[h]:mm:ss"))
+	assert.Equal(numberCell, formatKind("#,##0.00 \"days\""))
+	assert.Equal(numberCell, formatKind("0.00;[Red]-0.00"))
+}
+
+func TestWriteAndReadBackNumberTypes(t *testing.T) {
+	assert := assert2.New(t)
+	ds := dataset.New([]*types.DataRow{
+		dataset.DataRow(nil,
+			dataset.DoubleColumn("Whole", 2),
+			dataset.DoubleColumn("Mixed", 1),
+			dataset.LongColumn("Count", 5),
+			dataset.IntColumn("Small", 1),
+		),
+		dataset.DataRow(nil,
+			dataset.DoubleColumn("Whole", -40),
+			dataset.DoubleColumn("Mixed", 2.75),
+			dataset.LongColumn("Count", 6),
+			dataset.IntColumn("Small", 2),
+		),
+	})
+
+	var buf bytes.Buffer
+	assert.Nil(Write(&buf, ds, nil))
+	read, err := Read(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(err)
+	assert.Equal(types.DoubleType, read.Headers["Whole"].DataType)
+	assert.Equal(types.DoubleType, read.Headers["Mixed"].DataType)
+	assert.Equal(types.LongType, read.Headers["Count"].DataType)
+	assert.Equal(types.IntType, read.Headers["Small"].DataType)
+	for i, r := range read.Rows {
+		for _, c := range r.Columns {
+			expected := ds.Rows[i].GetColumn(c.ColumnName).CellValue
+			assert.Equal(expected.DataType, c.CellValue.DataType, c.ColumnName)
+			assert.True(c.CellValue.Equals(expected), c.ColumnName)
+		}
+	}
+}